@@ -0,0 +1,169 @@
+package dmg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ironpark/zapp/pkg/mactools/dmg"
+	"github.com/ironpark/zapp/pkg/mactools/hdiutil"
+	"github.com/urfave/cli/v2"
+)
+
+// flagFields sets the config field of each flag.
+var flagFields = map[string]func(*dmg.Config){
+	"out":                func(c *dmg.Config) { c.FileName = out },
+	"title":              func(c *dmg.Config) { c.Title = title },
+	"icon":               func(c *dmg.Config) { c.Icon = icon },
+	"background":         func(c *dmg.Config) { c.Background = background },
+	"background-2x":      func(c *dmg.Config) { c.Background2x = background2x },
+	"window-width":       func(c *dmg.Config) { c.WindowWidth = windowWidth },
+	"window-height":      func(c *dmg.Config) { c.WindowHeight = windowHeight },
+	"window-x":           func(c *dmg.Config) { c.WindowX = windowX },
+	"window-y":           func(c *dmg.Config) { c.WindowY = windowY },
+	"show-toolbar":       func(c *dmg.Config) { c.ShowToolbar = showToolbar },
+	"show-sidebar":       func(c *dmg.Config) { c.ShowSidebar = showSidebar },
+	"sidebar-width":      func(c *dmg.Config) { c.SidebarWidth = sidebarWidth },
+	"show-pathbar":       func(c *dmg.Config) { c.ShowPathbar = showPathbar },
+	"show-statusbar":     func(c *dmg.Config) { c.ShowStatusBar = showStatus },
+	"legacy-finder":      func(c *dmg.Config) { c.LegacyFinder = legacyFinder },
+	"label-size":         func(c *dmg.Config) { c.LabelSize = labelSize },
+	"contents-icon-size": func(c *dmg.Config) { c.ContentsIconSize = contentsIconSize },
+	"view-style":         func(c *dmg.Config) { c.ViewStyle = dmg.ViewStyle(viewStyle) },
+	"ds-store":           func(c *dmg.Config) { c.DSStore = dsStore },
+	"format":             func(c *dmg.Config) { c.Format = hdiutil.Format(format) },
+	"zlib-level":         func(c *dmg.Config) { c.ZlibLevel = zlibLevel },
+	"license":            func(c *dmg.Config) { c.Licenses = licenses },
+	"layout":             func(c *dmg.Config) { c.Layout = dmg.Layout(layout) },
+	"reproducible":       func(c *dmg.Config) { c.Reproducible = reproducible },
+	"generate-background": func(c *dmg.Config) {
+		if generateBackground {
+			artwork(c)
+		}
+	},
+	"background-color": func(c *dmg.Config) {
+		if backgroundColor != "" {
+			artwork(c).Color = backgroundColor
+		}
+	},
+	"background-gradient": func(c *dmg.Config) {
+		if backgroundGradient != "" {
+			artwork(c).GradientTo = backgroundGradient
+		}
+	},
+	"background-text": func(c *dmg.Config) {
+		if backgroundText != "" {
+			artwork(c).Text = backgroundText
+		}
+	},
+}
+
+// artwork returns the generated background of c, enabling it if needed.
+func artwork(c *dmg.Config) *dmg.Artwork {
+	if c.Artwork == nil {
+		c.Artwork = &dmg.Artwork{}
+	}
+	return c.Artwork
+}
+
+// loadConfig builds the config from the flags and the --config file. The
+// file takes precedence over flag defaults, flags given on the command line
+// take precedence over the file.
+func loadConfig(c *cli.Context) (dmg.Config, error) {
+	config := dmg.Config{}
+	for _, set := range flagFields {
+		set(&config)
+	}
+	if path := c.String("config"); path != "" {
+		var err error
+		if config, err = dmg.LoadConfig(path, config); err != nil {
+			return config, err
+		}
+		for name, set := range flagFields {
+			if c.IsSet(name) {
+				set(&config)
+			}
+		}
+	}
+
+	if len(config.Contents) == 0 {
+		if appDir == "" {
+			return config, fmt.Errorf("required flag \"app\" not set")
+		}
+		// positioned by the layout preset
+		config.Contents = []dmg.Item{
+			{Type: dmg.Dir, Path: appDir},
+			{Type: dmg.Link, Path: "/Applications"},
+		}
+	} else if appDir != "" {
+		// --app replaces the app bundle of the config, e.g. with a CI build path
+		i := dmg.AppItem(config.Contents)
+		if i < 0 {
+			return config, fmt.Errorf("contents of %s have no app bundle to replace", c.String("config"))
+		}
+		config.Contents[i].Path = appDir
+	}
+
+	for _, arg := range extraItems {
+		item, err := parseItem(arg)
+		if err != nil {
+			return config, err
+		}
+		config.Contents = append(config.Contents, item)
+	}
+	if err := config.ApplyLayout(); err != nil {
+		return config, err
+	}
+	// flags can override any value of the file, so the merged config is checked
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// itemList collects the values of a repeatable flag. Unlike cli.StringSlice
+// it does not split values at commas, which separate the coordinates of --add.
+type itemList []string
+
+func (l *itemList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func (l *itemList) String() string {
+	return strings.Join(*l, " ")
+}
+
+// parseItem parses a path[:x,y] value of --add. Items without coordinates
+// are positioned by the layout preset.
+func parseItem(arg string) (item dmg.Item, err error) {
+	item.Path = arg
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		if x, y, ok := strings.Cut(arg[i+1:], ","); ok {
+			if item.X, err = strconv.Atoi(x); err == nil {
+				item.Y, err = strconv.Atoi(y)
+			}
+			if err != nil {
+				return item, fmt.Errorf("invalid coordinates of --add %s, want path:x,y", arg)
+			}
+			item.Path = arg[:i]
+		}
+	}
+	stat, err := os.Stat(item.Path)
+	if err != nil {
+		return item, fmt.Errorf("error accessing --add path: %v", err)
+	}
+	item.Type = dmg.File
+	if stat.IsDir() {
+		item.Type = dmg.Dir
+	}
+	return item, nil
+}
+
+// appName returns the name of the app bundle without its extension.
+func appName(appPath string) string {
+	name := filepath.Base(appPath)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"github.com/ironpark/zapp/cmd"
 	"github.com/ironpark/zapp/pkg/mactools/dmg"
+	"github.com/ironpark/zapp/pkg/mactools/hdiutil"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	_ "embed"
@@ -23,9 +25,27 @@ var (
 	title                     string
 	icon                      string
 	background                string
+	background2x              string
+	generateBackground        bool
+	backgroundColor           string
+	backgroundGradient        string
+	backgroundText            string
 	windowWidth, windowHeight int
+	windowX, windowY          int
+	showToolbar, showSidebar  bool
+	showPathbar, showStatus   bool
+	sidebarWidth              int
+	legacyFinder              bool
 	labelSize                 int
 	contentsIconSize          int
+	dsStore                   string
+	viewStyle                 string
+	format                    string
+	zlibLevel                 int
+	extraItems                itemList
+	licenses                  map[string]string
+	layout                    string
+	reproducible              bool
 )
 
 var Command = &cli.Command{
@@ -35,8 +55,21 @@ var Command = &cli.Command{
 	Description: "",
 	Args:        true,
 	ArgsUsage:   " <path of app-bundle>",
+	Subcommands: []*cli.Command{
+		inspectCommand,
+		extractCommand,
+		verifyCommand,
+	},
 	Action: func(c *cli.Context) error {
 		logger := cmd.NewAppLogger(c.App)
+		config, err := loadConfig(c)
+		if err != nil {
+			return err
+		}
+		i := dmg.AppItem(config.Contents)
+		if i < 0 && (config.Icon == "" || config.Title == "") {
+			return fmt.Errorf("contents have no app bundle, title and icon are required")
+		}
 		// Create a temporary working directory
 		tempDir, err := os.MkdirTemp("", "*-zapp-dmg")
 		if err != nil {
@@ -44,80 +77,116 @@ var Command = &cli.Command{
 		}
 		defer os.RemoveAll(tempDir)
 
-		logger.Printf("Start Creating DMG file for %s\n", filepath.Base(appDir))
+		if config.Title == "" {
+			config.Title = appName(config.Contents[i].Path)
+		}
+		if config.FileName == "" {
+			config.FileName = config.Title + ".dmg"
+			if i >= 0 {
+				config.FileName = appName(config.Contents[i].Path) + ".dmg"
+			}
+		}
+		logger.Printf("Start Creating DMG file for %s\n", config.Title)
 
-		if icon == "" {
+		if config.Icon == "" {
+			appPath := config.Contents[i].Path
 			logger.Println("Icon file not provided")
 			logger.Println("Create dmg disk file icon using app icon")
 			tempDirForIcon, err := os.MkdirTemp("", "*-zapp-dmg-icon")
 			if err != nil {
 				return fmt.Errorf("error creating temporary directory: %v", err)
 			}
-			defer os.RemoveAll(tempDir)
-			icon = filepath.Join(tempDirForIcon, "icon.icns")
-			err = createIconSet(appDir, icon, !c.Bool("use-original-icon"))
+			defer os.RemoveAll(tempDirForIcon)
+			config.Icon = filepath.Join(tempDirForIcon, "icon.icns")
+			err = createIconSet(appPath, config.Icon, !c.Bool("use-original-icon"))
 			if err != nil {
 				return err
 			}
 		}
-		if out == "" {
-			out = filepath.Base(appDir)
-			out = strings.TrimSuffix(out, filepath.Ext(out))
-			out = out + ".dmg"
+		logger.PrintValue("Title", config.Title)
+		logger.PrintValue("Icon", config.Icon)
+		logger.PrintValue("labelSize", config.LabelSize)
+		logger.PrintValue("Layout", config.Layout)
+		for _, item := range config.Contents {
+			logger.PrintValue("Contents", fmt.Sprintf("%s (%s) at %d, %d", item.Path, item.Type, item.X, item.Y))
 		}
-		if title == "" {
-			title = filepath.Base(appDir)
-			title = strings.TrimSuffix(title, filepath.Ext(title))
+		logger.PrintValue("OutputPath", config.FileName)
+		logger.PrintValue("ContentsIconSize", config.ContentsIconSize)
+		logger.PrintValue("WindowWidth", config.WindowWidth)
+		logger.PrintValue("WindowHeight", config.WindowHeight)
+		logger.PrintValue("WindowPosition", fmt.Sprintf("%d, %d", config.WindowX, config.WindowY))
+		logger.PrintValue("Background", config.Background)
+		if config.Background2x != "" {
+			logger.PrintValue("Background2x", config.Background2x)
 		}
-
-		centerY := int(float64(windowHeight)/2-float64(contentsIconSize)/2) + labelSize
-		defaultConfig := dmg.Config{
-			FileName:         out,
-			Title:            title,
-			Icon:             icon,
-			LabelSize:        labelSize,
-			ContentsIconSize: contentsIconSize,
-			WindowWidth:      windowWidth,
-			WindowHeight:     windowHeight,
-			Background:       background,
-			Contents: []dmg.Item{
-				{X: int(float64(windowWidth)/3*1 - float64(contentsIconSize)/2), Y: centerY, Type: dmg.Dir, Path: appDir},
-				{X: int(float64(windowWidth)/3*2 + float64(contentsIconSize)/2), Y: centerY, Type: dmg.Link, Path: "/Applications"},
-			},
+		if config.Background == "" && config.Artwork != nil {
+			logger.PrintValue("Background", "generated")
+		}
+		logger.PrintValue("DSStore", config.DSStore)
+		logger.PrintValue("ViewStyle", config.ViewStyle)
+		logger.PrintValue("Format", config.Format)
+		if config.Reproducible {
+			logger.PrintValue("Reproducible", config.Reproducible)
+		}
+		for lang, path := range config.Licenses {
+			logger.PrintValue("License", fmt.Sprintf("%s (%s)", path, lang))
 		}
-		logger.PrintValue("Title", title)
-		logger.PrintValue("Icon", icon)
-		logger.PrintValue("labelSize", labelSize)
-		logger.PrintValue("AppPath", appDir)
-		logger.PrintValue("OutputPath", out)
-		logger.PrintValue("ContentsIconSize", contentsIconSize)
-		logger.PrintValue("WindowWidth", windowWidth)
-		logger.PrintValue("WindowHeight", windowHeight)
-		logger.PrintValue("Background", background)
 		logger.Println("Creating DMG file...")
-		err = dmg.CreateDMG(defaultConfig, tempDir)
+		err = dmg.CreateDMG(config, tempDir)
 		if err != nil {
 			return err
 		}
 		logger.Success("DMG file created successfully!")
-		err = cmd.RunSignCmd(c, out)
+		err = cmd.RunSignCmd(c, config.FileName)
 		if err != nil {
 			return fmt.Errorf("failed to sign PKG: %v", err)
 		}
 
-		err = cmd.RunNotarizeCmd(c, out)
+		err = cmd.RunNotarizeCmd(c, config.FileName)
 		if err != nil {
 			return fmt.Errorf("failed to notarize PKG: %v", err)
 		}
 		return nil
 	},
 	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "config",
+			Usage:   "Path to a JSON/YAML file with the whole DMG configuration, flags given on the command line override it",
+			Aliases: []string{"c"},
+		},
 		&cli.StringFlag{
 			Name:        "background",
 			Usage:       "Path to the background image file",
 			Aliases:     []string{"bg"},
 			Destination: &background,
 		},
+		&cli.StringFlag{
+			Name:        "background-2x",
+			Usage:       "Path to the Retina (@2x) background image, bg@2x.png next to bg.png is used by default",
+			Aliases:     []string{"bg2x"},
+			Destination: &background2x,
+		},
+		&cli.BoolFlag{
+			Name:        "generate-background",
+			Usage:       "Generate a background with an arrow from the app to Applications when no background image is given",
+			Aliases:     []string{"gbg"},
+			Destination: &generateBackground,
+		},
+		&cli.StringFlag{
+			Name:        "background-color",
+			Usage:       "Colour of the generated background (#rrggbb)",
+			Destination: &backgroundColor,
+		},
+		&cli.StringFlag{
+			Name:        "background-gradient",
+			Usage:       "Colour at the bottom of the generated background, for a vertical gradient from --background-color (#rrggbb)",
+			Destination: &backgroundGradient,
+		},
+		&cli.StringFlag{
+			Name:        "background-text",
+			Usage:       "Instruction text of the generated background, e.g. \"Drag to Applications to install\"",
+			Destination: &backgroundText,
+		},
 		&cli.StringFlag{
 			Name:        "title",
 			Usage:       "The title displayed when the DMG file is mounted",
@@ -128,7 +197,6 @@ var Command = &cli.Command{
 			Name:        "app",
 			Usage:       "App bundle path",
 			Destination: &appDir,
-			Required:    true,
 			Action: func(c *cli.Context, app string) error {
 				if !strings.HasSuffix(app, ".app") {
 					return fmt.Errorf("not valid app bundle extension")
@@ -144,6 +212,11 @@ var Command = &cli.Command{
 				return nil
 			},
 		},
+		&cli.GenericFlag{
+			Name:  "add",
+			Usage: "Extra file or folder to show in the window, as path[:x,y]. Items without coordinates are placed in rows below the app (repeatable)",
+			Value: &extraItems,
+		},
 		&cli.StringFlag{
 			Name:        "out",
 			Usage:       "The output DMG file name",
@@ -169,6 +242,43 @@ var Command = &cli.Command{
 			Destination: &windowHeight,
 			Value:       480,
 		},
+		&cli.IntFlag{
+			Name:        "window-x",
+			Usage:       "Horizontal position of the Finder window, from the left of the screen",
+			Destination: &windowX,
+			Value:       100,
+		},
+		&cli.IntFlag{
+			Name:        "window-y",
+			Usage:       "Vertical position of the Finder window, from the top of the screen",
+			Destination: &windowY,
+			Value:       100,
+		},
+		&cli.BoolFlag{
+			Name:        "show-toolbar",
+			Usage:       "Show the toolbar of the Finder window",
+			Destination: &showToolbar,
+		},
+		&cli.BoolFlag{
+			Name:        "show-sidebar",
+			Usage:       "Show the sidebar of the Finder window",
+			Destination: &showSidebar,
+		},
+		&cli.IntFlag{
+			Name:        "sidebar-width",
+			Usage:       "Width of the sidebar when it is shown (0 for the Finder default)",
+			Destination: &sidebarWidth,
+		},
+		&cli.BoolFlag{
+			Name:        "show-pathbar",
+			Usage:       "Show the path bar of the Finder window",
+			Destination: &showPathbar,
+		},
+		&cli.BoolFlag{
+			Name:        "show-statusbar",
+			Usage:       "Show the status bar of the Finder window",
+			Destination: &showStatus,
+		},
 		&cli.IntFlag{
 			Name:        "label-size",
 			Usage:       "Size of the label text in the Finder window (10-16)",
@@ -195,6 +305,85 @@ var Command = &cli.Command{
 				return nil
 			},
 		},
+		&cli.StringFlag{
+			Name:        "layout",
+			Usage:       "Layout preset of the items without coordinates (horizontal, vertical, grid, app-with-extras)",
+			Destination: &layout,
+			Value:       string(dmg.AppWithExtrasLayout),
+			Action: func(*cli.Context, string) error {
+				if !slices.Contains(dmg.Layouts, dmg.Layout(layout)) {
+					return fmt.Errorf("layout must be one of horizontal, vertical, grid, app-with-extras")
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:        "view-style",
+			Usage:       "View style of the Finder window when the DMG file is opened (icon, list, column)",
+			Destination: &viewStyle,
+			Value:       "icon",
+			Action: func(*cli.Context, string) error {
+				switch dmg.ViewStyle(viewStyle) {
+				case dmg.IconView, dmg.ListView, dmg.ColumnView:
+					return nil
+				}
+				return fmt.Errorf("view-style must be one of icon, list, column")
+			},
+		},
+		&cli.StringFlag{
+			Name:        "ds-store",
+			Usage:       "Path to a .DS_Store file or a JSON/YAML layout from 'zapp dsstore dump --json'",
+			Destination: &dsStore,
+		},
+		&cli.BoolFlag{
+			Name:        "legacy-finder",
+			Usage:       "Also write the window and background records read by older macOS Finder versions",
+			Destination: &legacyFinder,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Usage:       "Format of the DMG file (UDZO, ULFO, ULMO, UDBZ, UDCO, UDRO)",
+			Destination: &format,
+			Value:       string(hdiutil.UDZO),
+			Action: func(*cli.Context, string) error {
+				if !slices.Contains(dmg.Formats, hdiutil.Format(format)) {
+					return fmt.Errorf("format must be one of UDZO, ULFO, ULMO, UDBZ, UDCO, UDRO")
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:        "zlib-level",
+			Usage:       "zlib compression level of the UDZO format (1-9, 0 for the hdiutil default)",
+			Destination: &zlibLevel,
+			Action: func(*cli.Context, int) error {
+				if zlibLevel < 0 || zlibLevel > 9 {
+					return fmt.Errorf("zlib-level must be between 1 and 9, or 0 for the hdiutil default")
+				}
+				return nil
+			},
+		},
+		&cli.BoolFlag{
+			Name:        "reproducible",
+			Usage:       "Build a bit-identical DMG from the same inputs, dated SOURCE_DATE_EPOCH (without hdiutil)",
+			Destination: &reproducible,
+		},
+		&cli.StringSliceFlag{
+			Name:    "license",
+			Usage:   "License agreement shown when the DMG file is mounted, text or RTF (format: lang:path, e.g., en:en_eula.txt,ko:ko_eula.txt)",
+			Aliases: []string{"eula"},
+			Action: func(c *cli.Context, values []string) error {
+				licenses = map[string]string{}
+				for _, eula := range values {
+					lang, path, ok := strings.Cut(eula, ":")
+					if !ok {
+						return fmt.Errorf("invalid eula arg format: %s", eula)
+					}
+					licenses[lang] = path
+				}
+				return nil
+			},
+		},
 		&cli.BoolFlag{
 			Name:    "use-original-icon ",
 			Aliases: []string{"uoi"},
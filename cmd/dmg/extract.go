@@ -0,0 +1,40 @@
+package dmg
+
+import (
+	"fmt"
+
+	"github.com/ironpark/zapp/cmd"
+	"github.com/ironpark/zapp/pkg/mactools/dmg"
+	"github.com/urfave/cli/v2"
+)
+
+var extractCommand = &cli.Command{
+	Name:      "extract",
+	Usage:     "Extract files from a .dmg without mounting it",
+	ArgsUsage: "<path of .dmg> [path in the volume...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "out",
+			Usage:   "The directory to extract to",
+			Aliases: []string{"o"},
+			Value:   ".",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("path is required")
+		}
+		logger := cmd.NewAppLogger(c.App)
+		img, err := dmg.Open(c.Args().First())
+		if err != nil {
+			return err
+		}
+		defer img.Close()
+		out := c.String("out")
+		if err := img.Extract(out, c.Args().Tail()...); err != nil {
+			return fmt.Errorf("failed to extract: %v", err)
+		}
+		logger.Success("Extracted %s to %s", img.Volume.Name, out)
+		return nil
+	},
+}
@@ -0,0 +1,136 @@
+package dmg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ironpark/zapp/cmd"
+	"github.com/ironpark/zapp/pkg/mactools/dmg"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore"
+	"github.com/ironpark/zapp/pkg/mactools/hfsplus"
+	"github.com/ironpark/zapp/pkg/mactools/udif"
+	"github.com/urfave/cli/v2"
+)
+
+var chunkNames = map[uint32]string{
+	udif.ChunkZero:   "zero",
+	udif.ChunkRaw:    "raw",
+	udif.ChunkIgnore: "ignore",
+	udif.ChunkADC:    "adc",
+	udif.ChunkZlib:   "zlib",
+	udif.ChunkBzip2:  "bzip2",
+	udif.ChunkLZFSE:  "lzfse",
+	udif.ChunkLZMA:   "lzma",
+}
+
+var inspectCommand = &cli.Command{
+	Name:      "inspect",
+	Usage:     "Print the layout and the files of a .dmg without mounting it",
+	ArgsUsage: "<path of .dmg>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("path is required")
+		}
+		img, err := dmg.Open(c.Args().First())
+		if err != nil {
+			return err
+		}
+		defer img.Close()
+		logger := cmd.NewAppLogger(c.App)
+		v := img.Volume
+
+		logger.PrintValue("Partition", img.Partition.Name)
+		logger.PrintValue("Chunks", chunkSummary(img.Partition))
+		logger.PrintValue("Volume", v.Name)
+		logger.PrintValue("Created", v.Created.Format(time.RFC3339))
+		logger.PrintValue("Files", v.Files)
+		logger.PrintValue("Folders", v.Folders)
+		logger.PrintValue("VolumeIcon", volumeIcon(v))
+
+		w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MODE\tSIZE\tRSRC\tTYPE\tPATH")
+		err = v.Walk(func(name string, f *hfsplus.File) error {
+			if f.Mode&fs.ModeSymlink != 0 {
+				target, err := v.Readlink(f)
+				if err != nil {
+					return err
+				}
+				name += " -> " + target
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", f.Mode, f.DataSize, f.RsrcSize, finderType(f), name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		data, err := v.ReadFile(".DS_Store")
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Println("No .DS_Store in the volume")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		store, err := dsstore.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to decode .DS_Store: %v", err)
+		}
+		records, err := store.Records()
+		if err != nil {
+			return err
+		}
+		logger.Println(".DS_Store")
+		fmt.Fprintln(w, "FILENAME\tENTRY\tDATA\tVALUE")
+		for _, r := range records {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Filename, r.EntryType, r.DataType, r.Value)
+		}
+		return w.Flush()
+	},
+}
+
+// chunkSummary counts the chunks of the partition by compression method.
+func chunkSummary(p *udif.Partition) string {
+	counts := map[string]int{}
+	for _, chunk := range p.Table.Chunks {
+		if name, ok := chunkNames[chunk.Type]; ok {
+			counts[name]++
+		}
+	}
+	var parts []string
+	for name, count := range counts {
+		parts = append(parts, fmt.Sprintf("%s %d", name, count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func volumeIcon(v *hfsplus.Volume) string {
+	hasFlag := v.Root().FinderInfo.Flags&hfsplus.HasCustomIcon != 0
+	icon, err := v.Lookup(".VolumeIcon.icns")
+	switch {
+	case err != nil && hasFlag:
+		return "custom icon flag set, but no .VolumeIcon.icns"
+	case err != nil:
+		return "none"
+	case !hasFlag:
+		return fmt.Sprintf(".VolumeIcon.icns (%d bytes), custom icon flag not set", icon.DataSize)
+	}
+	return fmt.Sprintf(".VolumeIcon.icns (%d bytes)", icon.DataSize)
+}
+
+func finderType(f *hfsplus.File) string {
+	if f.FinderInfo.Type == "" && f.FinderInfo.Creator == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%-4s/%-4s", f.FinderInfo.Type, f.FinderInfo.Creator)
+}
@@ -0,0 +1,72 @@
+package dmg
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/ironpark/zapp/cmd"
+	"github.com/ironpark/zapp/pkg/mactools/dmg"
+	"github.com/urfave/cli/v2"
+)
+
+var verifyCommand = &cli.Command{
+	Name:      "verify",
+	Usage:     "Check the checksums, format and Finder layout of a .dmg",
+	ArgsUsage: "<path of .dmg>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the results as JSON",
+		},
+		&cli.BoolFlag{
+			Name:  "hdiutil",
+			Usage: "Also run hdiutil verify when hdiutil is available",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("path is required")
+		}
+		path := c.Args().First()
+		checks, err := dmg.Verify(path, c.Bool("hdiutil"))
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, check := range checks {
+			if !check.Passed {
+				failed++
+			}
+		}
+
+		if c.Bool("json") {
+			encoder := json.NewEncoder(c.App.Writer)
+			encoder.SetIndent("", "  ")
+			err := encoder.Encode(struct {
+				File   string      `json:"file"`
+				Passed bool        `json:"passed"`
+				Checks []dmg.Check `json:"checks"`
+			}{path, failed == 0, checks})
+			if err != nil {
+				return err
+			}
+		} else {
+			logger := cmd.NewAppLogger(c.App)
+			for _, check := range checks {
+				status := color.HiGreenString("PASS")
+				switch {
+				case !check.Passed:
+					status = color.RedString("FAIL")
+				case check.Skipped:
+					status = color.YellowString("SKIP")
+				}
+				logger.PrintValue(check.Name, status+"  "+check.Detail)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,71 @@
+package dsstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ironpark/zapp/pkg/mactools/alias"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+	"github.com/urfave/cli/v2"
+)
+
+// storedAlias is an alias found in a .DS_Store record.
+type storedAlias struct {
+	Filename  string     `json:"filename"`
+	EntryType string     `json:"entryType"`
+	Alias     alias.Info `json:"alias"`
+}
+
+var aliasCommand = &cli.Command{
+	Name:      "alias",
+	Usage:     "Print an alias record as JSON",
+	ArgsUsage: "<path of alias record or .DS_Store>",
+	Description: "Decodes a raw alias record, or every background image alias " +
+		"(icvp and pict records) of a .DS_Store file",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("path is required")
+		}
+		data, err := os.ReadFile(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("failed to read alias: %v", err)
+		}
+		encoder := json.NewEncoder(c.App.Writer)
+		encoder.SetIndent("", "  ")
+
+		if !bytes.HasPrefix(data, []byte("\x00\x00\x00\x01Bud1")) {
+			info, err := alias.Decode(data)
+			if err != nil {
+				return fmt.Errorf("failed to decode alias: %v", err)
+			}
+			return encoder.Encode(info)
+		}
+
+		store, err := dsstore.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to decode .DS_Store: %v", err)
+		}
+		aliases := []storedAlias{}
+		for _, e := range store.Entries {
+			var record []byte
+			switch e := e.(type) {
+			case *entry.IconViewPreferencesEntry:
+				record = e.BackgroundImageAlias
+			case *entry.PictureEntry:
+				record = e.Alias
+			}
+			if record == nil {
+				continue
+			}
+			info, err := alias.Decode(record)
+			if err != nil {
+				return fmt.Errorf("failed to decode %s alias of %q: %v", e.EntryType(), e.Filename(), err)
+			}
+			aliases = append(aliases, storedAlias{Filename: e.Filename(), EntryType: e.EntryType(), Alias: info})
+		}
+		return encoder.Encode(aliases)
+	},
+}
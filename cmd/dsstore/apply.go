@@ -0,0 +1,44 @@
+package dsstore
+
+import (
+	"fmt"
+
+	"github.com/ironpark/zapp/cmd"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore"
+	"github.com/urfave/cli/v2"
+)
+
+var applyCommand = &cli.Command{
+	Name:      "apply",
+	Usage:     "Create a .DS_Store file from a JSON/YAML description",
+	ArgsUsage: "<path of .json/.yaml>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "out",
+			Usage:   "The output .DS_Store path",
+			Aliases: []string{"o"},
+			Value:   ".DS_Store",
+		},
+		&cli.BoolFlag{
+			Name:  "legacy-finder",
+			Usage: "Also write the records read by older macOS Finder versions",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("path is required")
+		}
+		logger := cmd.NewAppLogger(c.App)
+		store, err := dsstore.Open(c.Args().First())
+		if err != nil {
+			return err
+		}
+		store.Legacy = c.Bool("legacy-finder")
+		out := c.String("out")
+		if err := store.Write(out); err != nil {
+			return fmt.Errorf("failed to write .DS_Store: %v", err)
+		}
+		logger.Success("%d records written to %s", len(store.Entries), out)
+		return nil
+	},
+}
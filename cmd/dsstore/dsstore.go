@@ -0,0 +1,17 @@
+package dsstore
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:        "dsstore",
+	Usage:       "Inspect and create .DS_Store files",
+	UsageText:   "zapp dsstore [command] [arguments...]",
+	Description: "Capture Finder window layouts into JSON/YAML and replay them as .DS_Store files",
+	Subcommands: []*cli.Command{
+		dumpCommand,
+		applyCommand,
+		aliasCommand,
+	},
+}
@@ -0,0 +1,53 @@
+package dsstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ironpark/zapp/pkg/mactools/dsstore"
+	"github.com/urfave/cli/v2"
+)
+
+var dumpCommand = &cli.Command{
+	Name:      "dump",
+	Usage:     "Print every record of a .DS_Store file",
+	ArgsUsage: "<path of .DS_Store>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the records as JSON (can be used with apply)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("path is required")
+		}
+		f, err := os.Open(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("failed to open .DS_Store: %v", err)
+		}
+		defer f.Close()
+		store, err := dsstore.Decode(f)
+		if err != nil {
+			return fmt.Errorf("failed to decode .DS_Store: %v", err)
+		}
+		records, err := store.Records()
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("json") {
+			encoder := json.NewEncoder(c.App.Writer)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(records)
+		}
+		w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILENAME\tENTRY\tDATA\tVALUE")
+		for _, r := range records {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Filename, r.EntryType, r.DataType, r.Value)
+		}
+		return w.Flush()
+	},
+}
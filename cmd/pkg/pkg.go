@@ -38,6 +38,7 @@ var Command = &cli.Command{
 			Identifier:      c.String("identifier"),
 			InstallLocation: "/Applications",
 			LicensePaths:    make(map[string]string),
+			Reproducible:    c.Bool("reproducible"),
 		}
 
 		if config.OutputPath == "" {
@@ -60,6 +61,9 @@ var Command = &cli.Command{
 		logger.PrintValue("OutputPath", config.OutputPath)
 		logger.PrintValue("Version", config.Version)
 		logger.PrintValue("Identifier", config.Identifier)
+		if config.Reproducible {
+			logger.PrintValue("Reproducible", config.Reproducible)
+		}
 
 		for _, eula := range c.StringSlice("eula") {
 			parts := strings.SplitN(eula, ":", 2)
@@ -130,5 +134,9 @@ var Command = &cli.Command{
 			Usage:   "Path to the license (EULA) file (format: lang:path, e.g., en:en_eula.txt,ko:ko_eula.txt)",
 			Aliases: []string{"eula"},
 		},
+		&cli.BoolFlag{
+			Name:  "reproducible",
+			Usage: "Normalize dates, owners and permissions to SOURCE_DATE_EPOCH so the same inputs build the same PKG",
+		},
 	}, cmd.CreateSubTaskFlags()...),
 }
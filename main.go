@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/ironpark/zapp/cmd/dep"
 	"github.com/ironpark/zapp/cmd/dmg"
+	"github.com/ironpark/zapp/cmd/dsstore"
 	"github.com/ironpark/zapp/cmd/info"
 	"github.com/ironpark/zapp/cmd/notarize"
 	"github.com/ironpark/zapp/cmd/pkg"
@@ -24,6 +25,7 @@ func main() {
 			plist.Command,
 			notarize.Command,
 			dep.Command,
+			dsstore.Command,
 		},
 		Usage: "Simplify your macOS App deployment",
 		Action: func(ctx *cli.Context) error {
@@ -3,8 +3,11 @@ package fsutil
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 )
 
 func CopyFileAnyway(src, dst string) error {
@@ -36,3 +39,68 @@ func CopyFileAnyway(src, dst string) error {
 	}
 	return dstFile.Sync()
 }
+
+// CopyTree copies the directory tree at src to dst. Symbolic links are
+// copied as links.
+func CopyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		default:
+			return CopyFileAnyway(path, target)
+		}
+	})
+}
+
+// SourceDate returns the date of reproducible builds, SOURCE_DATE_EPOCH
+// seconds since the Unix epoch, or the Unix epoch when it is not set.
+func SourceDate() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q, want seconds since 1970", epoch)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// Normalize sets the dates of everything below root, root included, to t,
+// and the permissions to 0755 for folders and executables and 0644 for
+// other files, so that archives of the tree only depend on its contents.
+// Symbolic links are left as they are.
+func Normalize(root string, t time.Time) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type()&fs.ModeSymlink != 0 {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		perm := fs.FileMode(0644)
+		if d.IsDir() || info.Mode()&0111 != 0 {
+			perm = 0755
+		}
+		if err := os.Chmod(path, perm); err != nil {
+			return err
+		}
+		return os.Chtimes(path, t, t)
+	})
+}
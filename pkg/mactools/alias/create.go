@@ -5,8 +5,11 @@ import (
 	"encoding/binary"
 	"errors"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 	"unicode/utf16"
 )
 
@@ -46,7 +49,13 @@ func utf16be(str string) []byte {
 	return b
 }
 
+// Create creates an alias record for an existing file or directory.
 func Create(targetPath string) ([]byte, error) {
+	return CreateWith(targetPath, DefaultVolumeInfo)
+}
+
+// CreateWith creates an alias record, reading the volume details from volume.
+func CreateWith(targetPath string, volume VolumeInfo) ([]byte, error) {
 	info := Info{Version: 2, Extra: []Extra{}}
 
 	parentPath := filepath.Dir(targetPath)
@@ -54,27 +63,18 @@ func Create(targetPath string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	parentStat, err := os.Stat(parentPath)
-	if err != nil {
-		return nil, err
-	}
 	volumePath, err := findVolume(targetPath, targetStat)
 	if err != nil {
 		return nil, err
 	}
-	volumeStat, err := os.Stat(volumePath)
-	if err != nil {
-		return nil, err
-	}
 
 	if !targetStat.IsDir() && !targetStat.Mode().IsRegular() {
 		return nil, errors.New("target is not a file or directory")
 	}
 
-	targetSys := targetStat.Sys().(*syscall.Stat_t)
-	parentSys := parentStat.Sys().(*syscall.Stat_t)
-
-	info.Target.ID = uint32(targetSys.Ino)
+	if info.Target.ID, err = volume.FileID(targetPath); err != nil {
+		return nil, err
+	}
 	if targetStat.IsDir() {
 		info.Target.Type = "directory"
 	} else {
@@ -83,15 +83,17 @@ func Create(targetPath string) ([]byte, error) {
 	info.Target.Filename = filepath.Base(targetPath)
 	info.Target.Created = targetStat.ModTime()
 
-	info.Parent.ID = uint32(parentSys.Ino)
+	if info.Parent.ID, err = volume.FileID(parentPath); err != nil {
+		return nil, err
+	}
 	info.Parent.Name = filepath.Base(parentPath)
 
-	volumeName, err := GetVolumeName(volumePath)
-	if err != nil {
+	if info.Volume.Name, err = volume.VolumeName(volumePath); err != nil {
+		return nil, err
+	}
+	if info.Volume.Created, err = volume.VolumeCreated(volumePath); err != nil {
 		return nil, err
 	}
-	info.Volume.Name = volumeName
-	info.Volume.Created = volumeStat.ModTime()
 	info.Volume.Signature = "H+"
 	if volumePath == "/" {
 		info.Volume.Type = "local"
@@ -99,11 +101,73 @@ func Create(targetPath string) ([]byte, error) {
 		info.Volume.Type = "other"
 	}
 
+	if !filepath.HasPrefix(targetPath, volumePath) {
+		return nil, errors.New("target path is not within volume path")
+	}
+	addExtras(&info, targetPath[len(volumePath):], volumePath)
+	return Encode(info)
+}
+
+// VirtualTarget describes a file or directory inside a volume that is not
+// mounted, such as a disk image that is still being built.
+type VirtualTarget struct {
+	VolumeName    string
+	VolumeCreated time.Time
+	// Path is relative to the root of the volume, e.g. ".background/background.png".
+	Path        string
+	IsDirectory bool
+	Created     time.Time
+	// TargetID and ParentID are the catalog node IDs of the target and its
+	// parent directory. The root directory of an HFS+ volume is always 2.
+	TargetID uint32
+	ParentID uint32
+}
+
+// CreateVirtual creates an alias record for a target that does not exist on
+// this machine. The volume is expected to be mounted under /Volumes.
+func CreateVirtual(target VirtualTarget) ([]byte, error) {
+	if target.VolumeName == "" {
+		return nil, errors.New("volume name is required")
+	}
+	relPath := strings.Trim(path.Clean("/"+target.Path), "/")
+	if relPath == "" {
+		return nil, errors.New("target path is required")
+	}
+
+	info := Info{Version: 2, Extra: []Extra{}}
+	info.Target.Type = "file"
+	if target.IsDirectory {
+		info.Target.Type = "directory"
+	}
+	info.Target.Filename = path.Base(relPath)
+	info.Target.ID = target.TargetID
+	info.Target.Created = target.Created
+
+	info.Parent.ID = target.ParentID
+	info.Parent.Name = path.Base(path.Dir(relPath))
+	if info.Parent.Name == "." {
+		info.Parent.Name = target.VolumeName
+	}
+
+	info.Volume.Name = target.VolumeName
+	info.Volume.Created = target.VolumeCreated
+	info.Volume.Signature = "H+"
+	info.Volume.Type = "other"
+
+	addExtras(&info, "/"+relPath, path.Join("/Volumes", target.VolumeName))
+	return Encode(info)
+}
+
+// addExtras appends the tagged records that Finder uses to resolve the alias.
+// localPath is the POSIX path of the target relative to the volume, starting
+// with a slash, and volumePath the mount point of the volume.
+func addExtras(info *Info, localPath, volumePath string) {
 	// Add Type 0
+	parentName := legacyName(info.Parent.Name, info.Parent.ID, 31)
 	info.Extra = append(info.Extra, Extra{
 		Type:   0,
-		Length: uint16(len(info.Parent.Name)),
-		Data:   []byte(info.Parent.Name),
+		Length: uint16(len(parentName)),
+		Data:   parentName,
 	})
 
 	// Add Type 1
@@ -118,7 +182,7 @@ func Create(targetPath string) ([]byte, error) {
 	// Add Type 14
 	filenameUTF16 := utf16be(info.Target.Filename)
 	b = make([]byte, 2+len(filenameUTF16))
-	binary.BigEndian.PutUint16(b, uint16(len(info.Target.Filename)))
+	binary.BigEndian.PutUint16(b, uint16(len(filenameUTF16)/2))
 	copy(b[2:], filenameUTF16)
 	info.Extra = append(info.Extra, Extra{
 		Type:   14,
@@ -129,7 +193,7 @@ func Create(targetPath string) ([]byte, error) {
 	// Add Type 15
 	volumeNameUTF16 := utf16be(info.Volume.Name)
 	b = make([]byte, 2+len(volumeNameUTF16))
-	binary.BigEndian.PutUint16(b, uint16(len(info.Volume.Name)))
+	binary.BigEndian.PutUint16(b, uint16(len(volumeNameUTF16)/2))
 	copy(b[2:], volumeNameUTF16)
 	info.Extra = append(info.Extra, Extra{
 		Type:   15,
@@ -138,10 +202,6 @@ func Create(targetPath string) ([]byte, error) {
 	})
 
 	// Add Type 18
-	if !filepath.HasPrefix(targetPath, volumePath) {
-		return nil, errors.New("target path is not within volume path")
-	}
-	localPath := targetPath[len(volumePath):]
 	info.Extra = append(info.Extra, Extra{
 		Type:   18,
 		Length: uint16(len(localPath)),
@@ -154,6 +214,4 @@ func Create(targetPath string) ([]byte, error) {
 		Length: uint16(len(volumePath)),
 		Data:   []byte(volumePath),
 	})
-
-	return Encode(info)
 }
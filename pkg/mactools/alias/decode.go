@@ -0,0 +1,125 @@
+// alias/decode.go
+package alias
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf16"
+)
+
+// FromAppleDate converts seconds since AppleEpoch back into a time.
+func FromAppleDate(value uint32) time.Time {
+	return AppleEpoch.Add(time.Duration(value) * time.Second)
+}
+
+// Decode parses a version 2 alias record, as written by Encode or by macOS.
+func Decode(buf []byte) (Info, error) {
+	var info Info
+	if len(buf) < 150 {
+		return info, errors.New("alias record is too short")
+	}
+	if length := int(binary.BigEndian.Uint16(buf[4:])); length != len(buf) {
+		return info, fmt.Errorf("alias record length mismatch: header says %d, got %d", length, len(buf))
+	}
+	info.Version = int(binary.BigEndian.Uint16(buf[6:]))
+	if info.Version != 2 {
+		return info, errors.New("unsupported version")
+	}
+
+	targetType := int(binary.BigEndian.Uint16(buf[8:]))
+	if targetType >= len(Type) {
+		return info, errors.New("invalid target type")
+	}
+	info.Target.Type = Type[targetType]
+
+	name, err := pascalString(buf[10:38])
+	if err != nil {
+		return info, fmt.Errorf("invalid volume name: %w", err)
+	}
+	info.Volume.Name = name
+	info.Volume.Created = FromAppleDate(binary.BigEndian.Uint32(buf[38:]))
+	info.Volume.Signature = string(buf[42:44])
+	volumeType := int(binary.BigEndian.Uint16(buf[44:]))
+	if volumeType >= len(VolumeType) {
+		return info, errors.New("invalid volume type")
+	}
+	info.Volume.Type = VolumeType[volumeType]
+
+	info.Parent.ID = binary.BigEndian.Uint32(buf[46:])
+	if info.Target.Filename, err = pascalString(buf[50:114]); err != nil {
+		return info, fmt.Errorf("invalid filename: %w", err)
+	}
+	info.Target.ID = binary.BigEndian.Uint32(buf[114:])
+	info.Target.Created = FromAppleDate(binary.BigEndian.Uint32(buf[118:]))
+
+	info.Extra = []Extra{}
+	pos := 150
+	for {
+		if pos+4 > len(buf) {
+			return info, errors.New("missing end of extra records")
+		}
+		extraType := int16(binary.BigEndian.Uint16(buf[pos:]))
+		length := binary.BigEndian.Uint16(buf[pos+2:])
+		pos += 4
+		if extraType == -1 {
+			break
+		}
+		if pos+int(length) > len(buf) {
+			return info, fmt.Errorf("extra record %d is out of range", extraType)
+		}
+		data := buf[pos : pos+int(length)]
+		info.Extra = append(info.Extra, Extra{Type: extraType, Length: length, Data: data})
+		switch extraType {
+		case 0:
+			info.Parent.Name = fromMacRoman(data)
+		case 1:
+			// IDs of the folders from the parent up to the root
+			for i := 0; i+4 <= len(data); i += 4 {
+				info.Parent.IDs = append(info.Parent.IDs, binary.BigEndian.Uint32(data[i:]))
+			}
+		case 14:
+			// full Unicode names replace the shortened MacRoman ones
+			if name, ok := unicodeName(data); ok {
+				info.Target.Filename = name
+			}
+		case 15:
+			if name, ok := unicodeName(data); ok {
+				info.Volume.Name = name
+			}
+		case 18:
+			info.Target.Path = string(data)
+		case 19:
+			info.Volume.Path = string(data)
+		}
+		pos += int(length)
+		if length%2 != 0 {
+			pos++
+		}
+	}
+	if pos != len(buf) {
+		return info, errors.New("trailing data after extra records")
+	}
+	return info, nil
+}
+
+// pascalString reads a length-prefixed string from a fixed size field.
+func pascalString(field []byte) (string, error) {
+	if int(field[0]) >= len(field) {
+		return "", fmt.Errorf("length %d exceeds the field size", field[0])
+	}
+	return fromMacRoman(field[1 : 1+field[0]]), nil
+}
+
+// unicodeName reads a UTF-16 name with a length prefix in characters.
+func unicodeName(data []byte) (string, bool) {
+	if len(data) < 2 || len(data) != 2+int(binary.BigEndian.Uint16(data))*2 {
+		return "", false
+	}
+	u16 := make([]uint16, binary.BigEndian.Uint16(data))
+	for i := range u16 {
+		u16[i] = binary.BigEndian.Uint16(data[2+i*2:])
+	}
+	return string(utf16.Decode(u16)), true
+}
@@ -0,0 +1,114 @@
+package alias
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "background.png")
+	if err := os.WriteFile(target, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := CreateWith(target, NamedVolumeInfo{VolumeInfo: StatVolumeInfo{}, Name: "MyApp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Target.Type != "file" || info.Target.Filename != "background.png" || info.Volume.Name != "MyApp" {
+		t.Errorf("unexpected alias: %+v", info)
+	}
+	if info.Parent.Name != filepath.Base(dir) {
+		t.Errorf("unexpected parent name %q", info.Parent.Name)
+	}
+	types := []int16{}
+	for _, e := range info.Extra {
+		types = append(types, e.Type)
+	}
+	if len(types) != 6 || types[0] != 0 || types[2] != 14 || types[5] != 19 {
+		t.Errorf("unexpected extra records %v", types)
+	}
+
+	encoded, err := Encode(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != string(data) {
+		t.Error("alias changed after decoding and encoding it again")
+	}
+
+	if _, err := Decode(data[:100]); err == nil {
+		t.Error("expected an error for a truncated alias")
+	}
+}
+
+func TestCreateVirtual(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data, err := CreateVirtual(VirtualTarget{
+		VolumeName:    "MyApp",
+		VolumeCreated: created,
+		Path:          ".background/background.png",
+		Created:       created,
+		TargetID:      21,
+		ParentID:      20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Target.Filename != "background.png" || info.Target.ID != 21 || info.Parent.ID != 20 ||
+		info.Parent.Name != ".background" || !info.Target.Created.Equal(created) || !info.Volume.Created.Equal(created) {
+		t.Errorf("unexpected alias: %+v", info)
+	}
+	if info.Target.Path != "/.background/background.png" || info.Volume.Path != "/Volumes/MyApp" {
+		t.Errorf("unexpected paths: %q, %q", info.Target.Path, info.Volume.Path)
+	}
+	if len(info.Parent.IDs) != 1 || info.Parent.IDs[0] != 20 {
+		t.Errorf("unexpected parent IDs %v", info.Parent.IDs)
+	}
+
+	data, err = CreateVirtual(VirtualTarget{VolumeName: "MyApp", Path: "background.png", TargetID: 16, ParentID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ = Decode(data); info.Parent.Name != "MyApp" {
+		t.Errorf("files at the volume root must use the volume name as parent, got %q", info.Parent.Name)
+	}
+}
+
+func TestLongUnicodeNames(t *testing.T) {
+	volumeName := "마이 앱 – A Very Long Localized Product Name"
+	filename := "배경 이미지 with a very long name that does not fit the alias field.png"
+	data, err := CreateVirtual(VirtualTarget{VolumeName: volumeName, Path: ".background/" + filename, TargetID: 0x2a, ParentID: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := int(data[10]); n != 27 {
+		t.Errorf("volume name field is %d bytes, want 27", n)
+	}
+	legacy := fromMacRoman(data[51 : 51+data[50]])
+	if len(data[51:51+data[50]]) > 63 || !strings.HasSuffix(legacy, "#2A.png") || !strings.HasPrefix(legacy, "__ ___ with") {
+		t.Errorf("unexpected legacy filename %q", legacy)
+	}
+	info, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Volume.Name != volumeName || info.Target.Filename != filename {
+		t.Errorf("full names were not kept: %q, %q", info.Volume.Name, info.Target.Filename)
+	}
+
+	if b := legacyName("Café.png", 1, 63); fromMacRoman(b) != "Café.png" || len(b) != 8 {
+		t.Errorf("MacRoman names must be kept as is, got %q", b)
+	}
+}
@@ -15,30 +15,35 @@ func AppleDate(value time.Time) uint32 {
 }
 
 type Extra struct {
-	Type   int16
-	Length uint16
-	Data   []byte
+	Type   int16  `json:"type"`
+	Length uint16 `json:"length"`
+	Data   []byte `json:"data"`
 }
 
+// Info is an alias record. The paths and the parent IDs are read by Decode
+// from the extra records 18, 19 and 1, Encode only writes Extra.
 type Info struct {
-	Version int
+	Version int `json:"version"`
 	Target  struct {
-		Type     string
-		Filename string
-		ID       uint32
-		Created  time.Time
-	}
+		Type     string    `json:"type"`
+		Filename string    `json:"filename"`
+		ID       uint32    `json:"id"`
+		Created  time.Time `json:"created"`
+		Path     string    `json:"path,omitempty"`
+	} `json:"target"`
 	Volume struct {
-		Name      string
-		Created   time.Time
-		Signature string
-		Type      string
-	}
+		Name      string    `json:"name"`
+		Created   time.Time `json:"created"`
+		Signature string    `json:"signature"`
+		Type      string    `json:"type"`
+		Path      string    `json:"path,omitempty"`
+	} `json:"volume"`
 	Parent struct {
-		ID   uint32
-		Name string
-	}
-	Extra []Extra
+		ID   uint32   `json:"id"`
+		Name string   `json:"name"`
+		IDs  []uint32 `json:"ids,omitempty"`
+	} `json:"parent"`
+	Extra []Extra `json:"extra"`
 }
 
 func Encode(info Info) ([]byte, error) {
@@ -76,12 +81,12 @@ func Encode(info Info) ([]byte, error) {
 	}
 	binary.BigEndian.PutUint16(buf[8:], uint16(typeIndex))
 
-	if len(info.Volume.Name) > 27 {
-		return nil, errors.New("volume name too long")
-	}
-	buf[10] = byte(len(info.Volume.Name))
+	// the fixed fields only hold short MacRoman names, the full Unicode
+	// names are kept in the extra records 14 and 15
+	volumeName := legacyVolumeName(info.Volume.Name, 27)
+	buf[10] = byte(len(volumeName))
 	copy(buf[11:38], make([]byte, 27))
-	copy(buf[11:], []byte(info.Volume.Name))
+	copy(buf[11:], volumeName)
 
 	volCreateDate := AppleDate(info.Volume.Created)
 	binary.BigEndian.PutUint32(buf[38:], volCreateDate)
@@ -105,12 +110,10 @@ func Encode(info Info) ([]byte, error) {
 
 	binary.BigEndian.PutUint32(buf[46:], info.Parent.ID)
 
-	if len(info.Target.Filename) > 63 {
-		return nil, errors.New("filename too long")
-	}
-	buf[50] = byte(len(info.Target.Filename))
+	filename := legacyName(info.Target.Filename, info.Target.ID, 63)
+	buf[50] = byte(len(filename))
 	copy(buf[51:114], make([]byte, 63))
-	copy(buf[51:], []byte(info.Target.Filename))
+	copy(buf[51:], filename)
 
 	binary.BigEndian.PutUint32(buf[114:], info.Target.ID)
 
@@ -0,0 +1,62 @@
+package alias
+
+import (
+	"fmt"
+	"path"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// toMacRoman encodes s for the legacy fields of an alias record. Characters
+// that MacRoman cannot represent are replaced with '_'. The second result
+// reports whether the conversion was lossless.
+func toMacRoman(s string) ([]byte, bool) {
+	var b []byte
+	exact := true
+	for _, r := range norm.NFC.String(s) {
+		c, ok := charmap.Macintosh.EncodeRune(r)
+		if !ok {
+			c, exact = '_', false
+		}
+		b = append(b, c)
+	}
+	return b, exact
+}
+
+func fromMacRoman(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = charmap.Macintosh.DecodeByte(c)
+	}
+	return string(runes)
+}
+
+// legacyName returns name in MacRoman, shortened to max bytes. Names that do
+// not fit or cannot be represented are mangled the way the File Manager does
+// for long names, "prefix#ID.ext", so they stay unique within their folder.
+func legacyName(name string, id uint32, max int) []byte {
+	b, exact := toMacRoman(name)
+	if exact && len(b) <= max {
+		return b
+	}
+	ext, _ := toMacRoman(path.Ext(name))
+	if len(ext) > 5 || len(ext) == len(b) {
+		ext = nil
+	}
+	suffix := append([]byte(fmt.Sprintf("#%X", id)), ext...)
+	prefix := b[:len(b)-len(ext)]
+	if len(prefix)+len(suffix) > max {
+		prefix = prefix[:max-len(suffix)]
+	}
+	return append(append([]byte{}, prefix...), suffix...)
+}
+
+// legacyVolumeName returns the volume name in MacRoman, truncated to max bytes.
+func legacyVolumeName(name string, max int) []byte {
+	b, _ := toMacRoman(name)
+	if len(b) > max {
+		b = b[:max]
+	}
+	return b
+}
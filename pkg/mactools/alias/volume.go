@@ -1,87 +1,72 @@
 package alias
 
-import "C"
 import (
 	"errors"
-	"unsafe"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
 )
 
-/*
-#cgo CFLAGS: -x objective-c
-#cgo LDFLAGS: -framework CoreFoundation -framework CoreServices
-#import <CoreFoundation/CoreFoundation.h>
-#import <CoreServices/CoreServices.h>
-
-const char* OSErrDescription(OSErr err) {
-    switch (err) {
-        case nsvErr: return "Volume not found";
-        case ioErr: return "I/O error.";
-        case bdNamErr: return "Bad filename or volume name.";
-        case mFulErr: return "Memory full (open) or file won't fit (load)";
-        case tmfoErr: return "Too many files open.";
-        case fnfErr: return "File or directory not found; incomplete pathname.";
-        case volOffLinErr: return "Volume is offline.";
-        case nsDrvErr: return "No such drive.";
-        case dirNFErr: return "Directory not found or incomplete pathname.";
-        case tmwdoErr: return "Too many working directories open.";
-    }
-    return "Could not get volume name";
+// VolumeInfo provides the details of the volume and the files that are
+// stored in an alias. Callers that build a volume before it is mounted can
+// supply their own implementation.
+type VolumeInfo interface {
+	// VolumeName returns the name of the volume mounted at path.
+	VolumeName(path string) (string, error)
+	// VolumeCreated returns the creation date of the volume mounted at path.
+	VolumeCreated(path string) (time.Time, error)
+	// FileID returns the catalog node ID (inode number) of the file at path.
+	FileID(path string) (uint32, error)
 }
-char* GetVolumeNameV2(const char* path) {
-    CFStringRef volumePath = CFStringCreateWithCString(NULL, path, kCFStringEncodingUTF8);
-    if (volumePath == NULL) {
-        return "Failed to create CFString from path";
-    }
-
-    CFURLRef url = CFURLCreateWithFileSystemPath(NULL, volumePath, kCFURLPOSIXPathStyle, true);
-    CFRelease(volumePath);
-    if (url == NULL) {
-        return "Failed to create CFURL from path";
-    }
-
-    CFStringRef volumeName = NULL;
-    Boolean success = CFURLCopyResourcePropertyForKey(url, kCFURLVolumeNameKey, &volumeName, NULL);
-    CFRelease(url);
-
-    if (!success || volumeName == NULL) {
-        return "Failed to get volume name";
-    }
 
-    CFIndex bufferSize = CFStringGetMaximumSizeForEncoding(CFStringGetLength(volumeName), kCFStringEncodingUTF8) + 1;
-    char* result = (char*)malloc(bufferSize);
-    if (result == NULL) {
-        CFRelease(volumeName);
-        return "Failed to allocate memory for volume name";
-    }
+// DefaultVolumeInfo is used by Create. It is backed by CoreFoundation on
+// macOS builds with cgo and by StatVolumeInfo everywhere else.
+var DefaultVolumeInfo VolumeInfo = StatVolumeInfo{}
 
-    if (CFStringGetCString(volumeName, result, bufferSize, kCFStringEncodingUTF8)) {
-        CFRelease(volumeName);
-        return result;
-    }
+// StatVolumeInfo reads the volume details with stat(2). The volume name is
+// the name of its mount point, which matches how macOS mounts disk images.
+// The root volume has no such name, wrap it in NamedVolumeInfo to give one.
+type StatVolumeInfo struct{}
 
-    free(result);
-    CFRelease(volumeName);
-    return "Failed to convert volume name to string";
+func (StatVolumeInfo) VolumeName(path string) (string, error) {
+	if path == "/" {
+		return "", errors.New("the name of the root volume is unknown, use NamedVolumeInfo to set it")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return filepath.Base(path), nil
 }
-*/
-import "C"
 
-func GetVolumeName(path string) (string, error) {
-	cPath := C.CString(path)
-	defer C.free(unsafe.Pointer(cPath))
+func (StatVolumeInfo) VolumeCreated(path string) (time.Time, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return stat.ModTime(), nil
+}
 
-	cResult := C.GetVolumeNameV2(cPath)
-	defer C.free(unsafe.Pointer(cResult))
+func (StatVolumeInfo) FileID(path string) (uint32, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(stat.Sys().(*syscall.Stat_t).Ino), nil
+}
 
-	result := C.GoString(cResult)
+// NamedVolumeInfo reports a fixed volume name, such as the title of a disk
+// image, and defers everything else to VolumeInfo.
+type NamedVolumeInfo struct {
+	VolumeInfo
+	Name string
+}
 
-	if result == "Failed to create CFString from path" ||
-		result == "Failed to create CFURL from path" ||
-		result == "Failed to get volume name" ||
-		result == "Failed to allocate memory for volume name" ||
-		result == "Failed to convert volume name to string" {
-		return "", errors.New(result)
-	}
+func (n NamedVolumeInfo) VolumeName(string) (string, error) {
+	return n.Name, nil
+}
 
-	return result, nil
+// GetVolumeName returns the name of the volume mounted at path.
+func GetVolumeName(path string) (string, error) {
+	return DefaultVolumeInfo.VolumeName(path)
 }
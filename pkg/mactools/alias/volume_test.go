@@ -1,6 +1,10 @@
 package alias
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestGetVolumeName(t *testing.T) {
 	vn, err := Create("/Users/ironpark/Documents/Project/Personal/zapp/aa/dmg")
@@ -9,3 +13,30 @@ func TestGetVolumeName(t *testing.T) {
 	}
 	t.Log(vn)
 }
+
+func TestCreateWithNamedVolume(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "background.png")
+	if err := os.WriteFile(target, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := CreateWith(target, NamedVolumeInfo{VolumeInfo: StatVolumeInfo{}, Name: "My App"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := string(data[11 : 11+data[10]]); name != "My App" {
+		t.Errorf("unexpected volume name %q", name)
+	}
+	if filename := string(data[51 : 51+data[50]]); filename != "background.png" {
+		t.Errorf("unexpected filename %q", filename)
+	}
+}
+
+func TestStatVolumeInfoRoot(t *testing.T) {
+	if _, err := (StatVolumeInfo{}).VolumeName("/"); err == nil {
+		t.Error("expected an error for the name of the root volume")
+	}
+	name, err := NamedVolumeInfo{VolumeInfo: StatVolumeInfo{}, Name: "Boot"}.VolumeName("/")
+	if err != nil || name != "Boot" {
+		t.Errorf("VolumeName = %q, %v", name, err)
+	}
+}
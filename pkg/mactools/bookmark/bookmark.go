@@ -0,0 +1,74 @@
+// Package bookmark is a package for writing macOS CFURL bookmark data,
+// the successor of Alias records.
+package bookmark
+
+import (
+	"time"
+)
+
+// Keys of the bookmark table of contents.
+const (
+	KeyPath                  = 0x1004
+	KeyCNIDPath              = 0x1005
+	KeyFileProperties        = 0x1010
+	KeyFileName              = 0x1020
+	KeyFileID                = 0x1030
+	KeyFileCreationDate      = 0x1040
+	KeyVolumePath            = 0x2002
+	KeyVolumeURL             = 0x2005
+	KeyVolumeName            = 0x2010
+	KeyVolumeUUID            = 0x2011
+	KeyVolumeSize            = 0x2012
+	KeyVolumeCreationDate    = 0x2013
+	KeyVolumeProperties      = 0x2020
+	KeyVolumeIsRoot          = 0x2030
+	KeyContainingFolderIndex = 0xc001
+	KeyUserName              = 0xc011
+	KeyUID                   = 0xc012
+	KeyWasFileReference      = 0xd001
+	KeyCreationOptions       = 0xd010
+	KeyDisplayName           = 0xf017
+)
+
+// Resource property flags stored in KeyFileProperties.
+const (
+	FileIsRegular   = 0x01
+	FileIsDirectory = 0x02
+	FileIsSymlink   = 0x04
+	FileIsVolume    = 0x08
+	FileIsPackage   = 0x10
+)
+
+// Volume property flags stored in KeyVolumeProperties.
+const (
+	VolumeIsLocal     = 0x001
+	VolumeIsReadOnly  = 0x008
+	VolumeIsEjectable = 0x020
+	VolumeIsRemovable = 0x040
+	VolumeIsInternal  = 0x080
+	VolumeIsExternal  = 0x100
+	VolumeIsDiskImage = 0x200
+)
+
+// CocoaEpoch is the reference date of bookmark dates.
+var CocoaEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type Info struct {
+	// Path holds the components of the absolute target path,
+	// CNIDs the inode numbers of the same components.
+	Path       []string
+	CNIDs      []uint64
+	Properties uint64
+	Created    time.Time
+	Volume     struct {
+		Path       string
+		Name       string
+		UUID       string
+		Size       int64
+		Created    time.Time
+		Properties uint64
+		IsRoot     bool
+	}
+	UserName string
+	UID      uint32
+}
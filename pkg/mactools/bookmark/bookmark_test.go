@@ -0,0 +1,111 @@
+package bookmark
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// items returns the body of the bookmark data and a function returning the
+// type and the value of an item of its TOC.
+func items(t *testing.T, data []byte) ([]byte, func(uint32) (uint32, []byte)) {
+	if string(data[:4]) != "book" || int(binary.LittleEndian.Uint32(data[4:])) != len(data) {
+		t.Fatalf("invalid header: % x", data[:16])
+	}
+	body := data[headerSize:]
+	toc := body[binary.LittleEndian.Uint32(body):]
+	if binary.LittleEndian.Uint32(toc[4:]) != tocMagic {
+		t.Fatal("invalid TOC magic")
+	}
+	items := map[uint32][]byte{}
+	for i := uint32(0); i < binary.LittleEndian.Uint32(toc[16:]); i++ {
+		entry := toc[20+i*12:]
+		offset := binary.LittleEndian.Uint32(entry[4:])
+		items[binary.LittleEndian.Uint32(entry)] = body[offset:]
+	}
+	return body, func(key uint32) (uint32, []byte) {
+		item, ok := items[key]
+		if !ok {
+			t.Fatalf("key 0x%04x is missing", key)
+		}
+		return binary.LittleEndian.Uint32(item[4:]), item[8 : 8+binary.LittleEndian.Uint32(item)]
+	}
+}
+
+func TestEncode(t *testing.T) {
+	info := Info{
+		Path:       []string{"Volumes", "MyApp", ".background", "background.png"},
+		CNIDs:      []uint64{2, 3, 20, 21},
+		Properties: FileIsRegular,
+		Created:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	info.Volume.Path = "/Volumes/MyApp"
+	info.Volume.Name = "MyApp"
+	info.Volume.Properties = VolumeIsLocal | VolumeIsDiskImage
+
+	data, err := Encode(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, value := items(t, data)
+
+	itemType, path := value(KeyPath)
+	if itemType != typeArray || len(path) != 16 {
+		t.Fatalf("unexpected path item: %x % x", itemType, path)
+	}
+	last := body[binary.LittleEndian.Uint32(path[12:]):]
+	if name := string(last[8 : 8+binary.LittleEndian.Uint32(last)]); name != "background.png" {
+		t.Errorf("unexpected last path component %q", name)
+	}
+	if _, url := value(KeyVolumeURL); string(url) != "file:///Volumes/MyApp/" {
+		t.Errorf("unexpected volume URL %q", url)
+	}
+	if _, index := value(KeyContainingFolderIndex); binary.LittleEndian.Uint64(index) != 2 {
+		t.Errorf("unexpected containing folder index %d", binary.LittleEndian.Uint64(index))
+	}
+	if _, props := value(KeyVolumeProperties); binary.LittleEndian.Uint64(props) != VolumeIsLocal|VolumeIsDiskImage {
+		t.Errorf("unexpected volume properties % x", props)
+	}
+}
+
+func TestCreateVirtual(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data, err := CreateVirtual(VirtualTarget{
+		VolumeName:    "MyApp",
+		VolumeCreated: created,
+		Path:          ".background/background.png",
+		Created:       created,
+		CNIDs:         []uint32{20, 21},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, value := items(t, data)
+	_, path := value(KeyPath)
+	_, cnids := value(KeyCNIDPath)
+	if len(path) != 16 || len(cnids) != 16 {
+		t.Fatalf("unexpected path items: % x, % x", path, cnids)
+	}
+	var names []string
+	var ids []uint64
+	for i := 0; i < 4; i++ {
+		item := body[binary.LittleEndian.Uint32(path[i*4:]):]
+		names = append(names, string(item[8:8+binary.LittleEndian.Uint32(item)]))
+		item = body[binary.LittleEndian.Uint32(cnids[i*4:]):]
+		ids = append(ids, binary.LittleEndian.Uint64(item[8:]))
+	}
+	if strings.Join(names, "/") != "Volumes/MyApp/.background/background.png" || ids[1] != 2 || ids[2] != 20 || ids[3] != 21 {
+		t.Errorf("unexpected path %v with IDs %v", names, ids)
+	}
+	if _, url := value(KeyVolumeURL); string(url) != "file:///Volumes/MyApp/" {
+		t.Errorf("unexpected volume URL %q", url)
+	}
+	if _, uid := value(KeyUID); binary.LittleEndian.Uint64(uid) != 0 {
+		t.Errorf("unexpected UID %d", binary.LittleEndian.Uint64(uid))
+	}
+
+	if _, err := CreateVirtual(VirtualTarget{VolumeName: "MyApp", Path: "a/b", CNIDs: []uint32{20}}); err == nil {
+		t.Error("expected an error for missing CNIDs")
+	}
+}
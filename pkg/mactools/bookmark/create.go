@@ -0,0 +1,139 @@
+package bookmark
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ironpark/zapp/pkg/mactools/alias"
+)
+
+// Create creates bookmark data for an existing file or directory.
+// Volumes other than the root volume are assumed to be mounted disk images.
+func Create(targetPath string) ([]byte, error) {
+	return CreateWith(targetPath, alias.DefaultVolumeInfo)
+}
+
+// CreateWith creates bookmark data, reading the volume details from volume.
+func CreateWith(targetPath string, volume alias.VolumeInfo) ([]byte, error) {
+	targetPath, err := filepath.Abs(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	targetStat, err := os.Stat(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	if !targetStat.IsDir() && !targetStat.Mode().IsRegular() {
+		return nil, errors.New("target is not a file or directory")
+	}
+
+	info := Info{Created: targetStat.ModTime()}
+	if targetStat.IsDir() {
+		info.Properties = FileIsDirectory
+	} else {
+		info.Properties = FileIsRegular
+	}
+
+	// the volume is mounted at the first path component on the device of the target
+	targetDev := targetStat.Sys().(*syscall.Stat_t).Dev
+	rootStat, err := os.Stat("/")
+	if err != nil {
+		return nil, err
+	}
+	volumePath := ""
+	if rootStat.Sys().(*syscall.Stat_t).Dev == targetDev {
+		volumePath = "/"
+	}
+	current := "/"
+	for _, name := range strings.Split(strings.TrimPrefix(targetPath, "/"), "/") {
+		current = filepath.Join(current, name)
+		stat, err := os.Stat(current)
+		if err != nil {
+			return nil, err
+		}
+		if volumePath == "" && stat.Sys().(*syscall.Stat_t).Dev == targetDev {
+			volumePath = current
+		}
+		id, err := volume.FileID(current)
+		if err != nil {
+			return nil, err
+		}
+		info.Path = append(info.Path, name)
+		info.CNIDs = append(info.CNIDs, uint64(id))
+	}
+
+	info.Volume.Path = volumePath
+	if info.Volume.Name, err = volume.VolumeName(volumePath); err != nil {
+		return nil, err
+	}
+	if info.Volume.Created, err = volume.VolumeCreated(volumePath); err != nil {
+		return nil, err
+	}
+	info.Volume.IsRoot = volumePath == "/"
+	if info.Volume.IsRoot {
+		info.Volume.Properties = VolumeIsLocal | VolumeIsInternal
+	} else {
+		info.Volume.Properties = VolumeIsLocal | VolumeIsEjectable | VolumeIsRemovable | VolumeIsExternal | VolumeIsDiskImage
+	}
+
+	info.UID = uint32(os.Getuid())
+	if u, err := user.Current(); err == nil {
+		info.UserName = u.Username
+	}
+	return Encode(info)
+}
+
+// VirtualTarget describes a file on a disk image that is not mounted, e.g.
+// one that is still being built.
+type VirtualTarget struct {
+	VolumeName    string
+	VolumeCreated time.Time
+	// Path is relative to the root of the volume, e.g. ".background/background.png".
+	Path        string
+	IsDirectory bool
+	Created     time.Time
+	// CNIDs are the catalog node IDs of the components of Path, the target
+	// last.
+	CNIDs []uint32
+}
+
+// CreateVirtual creates bookmark data for a target that does not exist on
+// this machine. The volume is expected to be mounted under /Volumes, whose
+// ID is unknown and left 0, and the bookmark belongs to no user so that it
+// does not depend on who built it.
+func CreateVirtual(target VirtualTarget) ([]byte, error) {
+	if target.VolumeName == "" {
+		return nil, errors.New("volume name is required")
+	}
+	relPath := strings.Trim(path.Clean("/"+target.Path), "/")
+	if relPath == "" {
+		return nil, errors.New("target path is required")
+	}
+	components := strings.Split(relPath, "/")
+	if len(target.CNIDs) != len(components) {
+		return nil, fmt.Errorf("%d CNIDs for the %d components of %s", len(target.CNIDs), len(components), relPath)
+	}
+
+	info := Info{Created: target.Created, Properties: FileIsRegular}
+	if target.IsDirectory {
+		info.Properties = FileIsDirectory
+	}
+	// the root folder of an HFS+ volume is always 2
+	info.Path = append([]string{"Volumes", target.VolumeName}, components...)
+	info.CNIDs = []uint64{0, 2}
+	for _, id := range target.CNIDs {
+		info.CNIDs = append(info.CNIDs, uint64(id))
+	}
+	info.Volume.Path = path.Join("/Volumes", target.VolumeName)
+	info.Volume.Name = target.VolumeName
+	info.Volume.Created = target.VolumeCreated
+	info.Volume.Properties = VolumeIsLocal | VolumeIsEjectable | VolumeIsRemovable | VolumeIsExternal | VolumeIsDiskImage
+	return Encode(info)
+}
@@ -0,0 +1,163 @@
+package bookmark
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/url"
+	"sort"
+	"time"
+)
+
+const (
+	headerSize = 0x30
+	version    = 0x10040000
+	tocMagic   = 0xfffffffe
+)
+
+// Item types of the data area.
+const (
+	typeString = 0x0101
+	typeData   = 0x0201
+	typeInt64  = 0x0304
+	typeDate   = 0x0400
+	typeFalse  = 0x0500
+	typeTrue   = 0x0501
+	typeArray  = 0x0601
+	typeURL    = 0x0901
+)
+
+// encoder lays out the items of the data area. Offsets are relative to the
+// end of the file header.
+type encoder struct {
+	data []byte
+	toc  map[uint32]uint32
+}
+
+func (e *encoder) item(itemType uint32, value []byte) uint32 {
+	offset := uint32(len(e.data))
+	e.data = binary.LittleEndian.AppendUint32(e.data, uint32(len(value)))
+	e.data = binary.LittleEndian.AppendUint32(e.data, itemType)
+	e.data = append(e.data, value...)
+	for len(e.data)%4 != 0 {
+		e.data = append(e.data, 0)
+	}
+	return offset
+}
+
+func (e *encoder) string(s string) uint32 {
+	return e.item(typeString, []byte(s))
+}
+
+func (e *encoder) int64(v int64) uint32 {
+	return e.item(typeInt64, binary.LittleEndian.AppendUint64(nil, uint64(v)))
+}
+
+func (e *encoder) date(t time.Time) uint32 {
+	seconds := t.Sub(CocoaEpoch).Seconds()
+	return e.item(typeDate, binary.BigEndian.AppendUint64(nil, math.Float64bits(seconds)))
+}
+
+func (e *encoder) bool(v bool) uint32 {
+	if v {
+		return e.item(typeTrue, nil)
+	}
+	return e.item(typeFalse, nil)
+}
+
+func (e *encoder) properties(flags uint64) uint32 {
+	b := binary.LittleEndian.AppendUint64(nil, flags)
+	b = binary.LittleEndian.AppendUint64(b, 0xffffffff)
+	b = binary.LittleEndian.AppendUint64(b, 0)
+	return e.item(typeData, b)
+}
+
+func (e *encoder) array(offsets []uint32) uint32 {
+	var b []byte
+	for _, offset := range offsets {
+		b = binary.LittleEndian.AppendUint32(b, offset)
+	}
+	return e.item(typeArray, b)
+}
+
+// Encode creates bookmark data in the "book" format written by CFURLCreateBookmarkData.
+func Encode(info Info) ([]byte, error) {
+	if len(info.Path) == 0 {
+		return nil, errors.New("empty target path")
+	}
+	if len(info.CNIDs) != len(info.Path) {
+		return nil, errors.New("path and CNID path length mismatch")
+	}
+	if info.Volume.Path == "" {
+		return nil, errors.New("volume path is required")
+	}
+
+	// the first word of the data area points at the table of contents
+	e := &encoder{data: make([]byte, 4), toc: map[uint32]uint32{}}
+
+	components := make([]uint32, len(info.Path))
+	for i, name := range info.Path {
+		components[i] = e.string(name)
+	}
+	e.toc[KeyPath] = e.array(components)
+	cnids := make([]uint32, len(info.CNIDs))
+	for i, id := range info.CNIDs {
+		cnids[i] = e.int64(int64(id))
+	}
+	e.toc[KeyCNIDPath] = e.array(cnids)
+	e.toc[KeyFileProperties] = e.properties(info.Properties)
+	e.toc[KeyFileCreationDate] = e.date(info.Created)
+
+	e.toc[KeyVolumePath] = e.string(info.Volume.Path)
+	volumeURL := url.URL{Scheme: "file", Path: info.Volume.Path}
+	if volumeURL.Path[len(volumeURL.Path)-1] != '/' {
+		volumeURL.Path += "/"
+	}
+	e.toc[KeyVolumeURL] = e.item(typeURL, []byte(volumeURL.String()))
+	e.toc[KeyVolumeName] = e.string(info.Volume.Name)
+	if info.Volume.UUID != "" {
+		e.toc[KeyVolumeUUID] = e.string(info.Volume.UUID)
+	}
+	if info.Volume.Size > 0 {
+		e.toc[KeyVolumeSize] = e.int64(info.Volume.Size)
+	}
+	e.toc[KeyVolumeCreationDate] = e.date(info.Volume.Created)
+	e.toc[KeyVolumeProperties] = e.properties(info.Volume.Properties)
+	e.toc[KeyVolumeIsRoot] = e.bool(info.Volume.IsRoot)
+
+	e.toc[KeyContainingFolderIndex] = e.int64(int64(len(info.Path) - 2))
+	if info.UserName != "" {
+		e.toc[KeyUserName] = e.string(info.UserName)
+	}
+	e.toc[KeyUID] = e.int64(int64(info.UID))
+	e.toc[KeyWasFileReference] = e.bool(true)
+	e.toc[KeyCreationOptions] = e.int64(0x200)
+	e.toc[KeyDisplayName] = e.string(info.Path[len(info.Path)-1])
+
+	keys := make([]uint32, 0, len(e.toc))
+	for key := range e.toc {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	tocOffset := uint32(len(e.data))
+	binary.LittleEndian.PutUint32(e.data, tocOffset)
+	data := e.data
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(keys)*12))
+	data = binary.LittleEndian.AppendUint32(data, tocMagic)
+	data = binary.LittleEndian.AppendUint32(data, 1) // identifier of the TOC
+	data = binary.LittleEndian.AppendUint32(data, 0) // no next TOC
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(keys)))
+	for _, key := range keys {
+		data = binary.LittleEndian.AppendUint32(data, key)
+		data = binary.LittleEndian.AppendUint32(data, e.toc[key])
+		data = binary.LittleEndian.AppendUint32(data, 0)
+	}
+
+	buf := make([]byte, headerSize, headerSize+len(data))
+	copy(buf, "book")
+	binary.LittleEndian.PutUint32(buf[4:], uint32(headerSize+len(data)))
+	binary.LittleEndian.PutUint32(buf[8:], version)
+	binary.LittleEndian.PutUint32(buf[12:], headerSize)
+	return append(buf, data...), nil
+}
@@ -0,0 +1,180 @@
+package dmg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Artwork configures the background that is generated when the config has
+// no background image.
+type Artwork struct {
+	Color      string `json:"color"`      // background colour as #rrggbb, white by default
+	GradientTo string `json:"gradientTo"` // colour at the bottom of a vertical gradient from Color
+	Text       string `json:"text"`       // instruction text below the items, e.g. "Drag to Applications to install"
+	TextColor  string `json:"textColor"`  // colour of the text and of the arrow, grey by default
+	FontSize   int    `json:"fontSize"`   // size of the text in points, 16 by default
+}
+
+// parseColor parses a #rgb or #rrggbb colour, def is returned for "".
+func parseColor(s string, def color.NRGBA) (color.NRGBA, error) {
+	if s == "" {
+		return def, nil
+	}
+	hex, ok := strings.CutPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if !ok || len(hex) != 6 || err != nil {
+		return def, fmt.Errorf("invalid colour %q, want #rrggbb", s)
+	}
+	return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+func (a *Artwork) validate() error {
+	for _, c := range []string{a.Color, a.GradientTo, a.TextColor} {
+		if _, err := parseColor(c, color.NRGBA{}); err != nil {
+			return err
+		}
+	}
+	if a.FontSize < 0 || a.FontSize > 200 {
+		return fmt.Errorf("fontSize must be between 1 and 200, or 0 for the default of 16")
+	}
+	return nil
+}
+
+// renderArtwork renders the background of config and its Retina variant
+// to dir and returns their paths.
+func renderArtwork(config Config, dir string) (string, string, error) {
+	var paths [2]string
+	for i, scale := range []int{1, 2} {
+		img, err := drawArtwork(config, scale)
+		if err != nil {
+			return "", "", err
+		}
+		paths[i] = filepath.Join(dir, "background.png")
+		if scale == 2 {
+			paths[i] = filepath.Join(dir, "background@2x.png")
+		}
+		f, err := os.Create(paths[i])
+		if err != nil {
+			return "", "", err
+		}
+		if err := png.Encode(f, img); err != nil {
+			f.Close()
+			return "", "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", "", err
+		}
+	}
+	return paths[0], paths[1], nil
+}
+
+// drawArtwork draws the background at scale times the window size: the
+// colour or gradient, an arrow from the app to the Applications link and
+// the instruction text below the items.
+func drawArtwork(config Config, scale int) (*image.RGBA, error) {
+	a := config.Artwork
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	top, _ := parseColor(a.Color, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	bottom, _ := parseColor(a.GradientTo, top)
+	ink, _ := parseColor(a.TextColor, color.NRGBA{0x8e, 0x8e, 0x93, 0xff})
+	width, height := config.WindowWidth*scale, config.WindowHeight*scale
+	iconSize := float32(max(config.ContentsIconSize, 16) * scale)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		t := float64(y) / float64(max(height-1, 1))
+		mix := func(a, b uint8) uint8 { return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t)) }
+		c := color.NRGBA{mix(top.R, bottom.R), mix(top.G, bottom.G), mix(top.B, bottom.B), 0xff}
+		draw.Draw(img, image.Rect(0, y, width, y+1), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	from, to := AppItem(config.Contents), -1
+	for i, item := range config.Contents {
+		if item.Type == Link && to < 0 {
+			to = i
+		}
+	}
+	if from >= 0 && to >= 0 {
+		p := func(item Item) (float32, float32) { return float32(item.X * scale), float32(item.Y * scale) }
+		x0, y0 := p(config.Contents[from])
+		x1, y1 := p(config.Contents[to])
+		drawArrow(img, x0, y0, x1, y1, iconSize, ink)
+	}
+
+	if a.Text != "" {
+		size := a.FontSize
+		if size == 0 {
+			size = 16
+		}
+		f, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			return nil, err
+		}
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: float64(size * scale), DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+		defer face.Close()
+		// below the lowest item and its label, but within the window
+		labelSize := max(config.LabelSize, 10) * scale
+		baseline := height / 2
+		for i, item := range config.Contents {
+			y := item.Y*scale + int(iconSize)/2 + 2*labelSize + size*scale
+			if i == 0 || y > baseline {
+				baseline = y
+			}
+		}
+		baseline = min(baseline, height-size*scale/2)
+		d := &font.Drawer{Dst: img, Src: image.NewUniform(ink), Face: face}
+		d.Dot = fixed.P((width-d.MeasureString(a.Text).Round())/2, baseline)
+		d.DrawString(a.Text)
+	}
+	return img, nil
+}
+
+// drawArrow draws an arrow between the icons centered at (x0, y0) and (x1, y1).
+func drawArrow(img *image.RGBA, x0, y0, x1, y1, iconSize float32, c color.NRGBA) {
+	dx, dy := x1-x0, y1-y0
+	length := float32(math.Hypot(float64(dx), float64(dy)))
+	gap := iconSize/2 + iconSize/8
+	stroke := iconSize / 24
+	head := stroke * 4
+	if length < 2*gap+head {
+		return
+	}
+	dx, dy = dx/length, dy/length
+	nx, ny := -dy, dx
+	sx, sy := x0+dx*gap, y0+dy*gap // start of the shaft
+	tx, ty := x1-dx*gap, y1-dy*gap // tip
+	bx, by := tx-dx*head, ty-dy*head
+
+	z := vector.NewRasterizer(img.Bounds().Dx(), img.Bounds().Dy())
+	z.MoveTo(sx+nx*stroke/2, sy+ny*stroke/2)
+	z.LineTo(bx+nx*stroke/2, by+ny*stroke/2)
+	z.LineTo(bx+nx*head*0.8, by+ny*head*0.8)
+	z.LineTo(tx, ty)
+	z.LineTo(bx-nx*head*0.8, by-ny*head*0.8)
+	z.LineTo(bx-nx*stroke/2, by-ny*stroke/2)
+	z.LineTo(sx-nx*stroke/2, sy-ny*stroke/2)
+	z.ClosePath()
+	z.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{})
+}
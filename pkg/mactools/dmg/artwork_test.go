@@ -0,0 +1,55 @@
+package dmg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestDrawArtwork(t *testing.T) {
+	config := Config{
+		WindowWidth:      640,
+		WindowHeight:     480,
+		ContentsIconSize: 128,
+		LabelSize:        14,
+		Contents: []Item{
+			{X: 160, Y: 200, Type: Dir, Path: "App.app"},
+			{X: 480, Y: 200, Type: Link, Path: "/Applications"},
+		},
+		Artwork: &Artwork{Color: "#ffffff", GradientTo: "#000", Text: "Drag to Applications to install", TextColor: "#ff0000"},
+	}
+	for _, scale := range []int{1, 2} {
+		img, err := drawArtwork(config, scale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := img.Bounds().Size(); size.X != 640*scale || size.Y != 480*scale {
+			t.Fatalf("size = %v", size)
+		}
+		if got := img.RGBAAt(0, 0); got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+			t.Errorf("top = %v", got)
+		}
+		if got := img.RGBAAt(0, 480*scale-1); got != (color.RGBA{0, 0, 0, 0xff}) {
+			t.Errorf("bottom = %v", got)
+		}
+		// the arrow is in the middle between the items, the text below them
+		if got := img.RGBAAt(320*scale, 200*scale); got.R != 0xff || got.G > 0x10 {
+			t.Errorf("arrow = %v", got)
+		}
+		red := 0
+		for y := 300 * scale; y < 400*scale; y++ {
+			for x := 0; x < 640*scale; x++ {
+				if c := img.RGBAAt(x, y); c.R > 0xc0 && c.G < 0x40 {
+					red++
+				}
+			}
+		}
+		if red < 100*scale*scale {
+			t.Errorf("text has %d red pixels", red)
+		}
+	}
+
+	config.Artwork = &Artwork{Color: "white"}
+	if _, err := drawArtwork(config, 1); err == nil {
+		t.Error("invalid colour accepted")
+	}
+}
@@ -0,0 +1,173 @@
+package dmg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backgroundPath returns the path of the background image in the volume.
+// Retina backgrounds are combined into a TIFF with one page per resolution.
+func backgroundPath(config Config) string {
+	if config.Background2x != "" {
+		return ".background/background.tiff"
+	}
+	return ".background/background.png"
+}
+
+// retinaBackground returns the @2x variant next to the background image,
+// e.g. bg@2x.png for bg.png, or "" if there is none.
+func retinaBackground(path string) string {
+	ext := filepath.Ext(path)
+	retina := strings.TrimSuffix(path, ext) + "@2x" + ext
+	if stat, err := os.Stat(retina); err != nil || stat.IsDir() {
+		return ""
+	}
+	return retina
+}
+
+// writeBackground writes the background image to path. With a Retina image
+// both are checked against the window size and written as a TIFF.
+func writeBackground(config Config, path string) error {
+	if config.Background2x == "" {
+		return copyFile(config.Background, path)
+	}
+	img, err := decodeImage(config.Background)
+	if err != nil {
+		return err
+	}
+	img2x, err := decodeImage(config.Background2x)
+	if err != nil {
+		return err
+	}
+	size, size2x := img.Bounds().Size(), img2x.Bounds().Size()
+	if size.X != config.WindowWidth || size.Y != config.WindowHeight {
+		return fmt.Errorf("background %s is %dx%d, the window is %dx%d", config.Background, size.X, size.Y, config.WindowWidth, config.WindowHeight)
+	}
+	if size2x != size.Mul(2) {
+		return fmt.Errorf("background %s is %dx%d, want %dx%d", config.Background2x, size2x.X, size2x.Y, size.X*2, size.Y*2)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encodeTIFF(f, img, img2x); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write background: %w", err)
+	}
+	return f.Close()
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// TIFF tags and field types used by encodeTIFF.
+const (
+	tiffShort    = 3
+	tiffLong     = 4
+	tiffRational = 5
+
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagPhotometric     = 262
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagXResolution     = 282
+	tagYResolution     = 283
+	tagPlanarConfig    = 284
+	tagResolutionUnit  = 296
+	tagExtraSamples    = 338
+)
+
+// encodeTIFF writes the images as the pages of a deflate compressed RGBA
+// TIFF. The resolution of every page is 72 dpi scaled by its width relative
+// to the first page, which is how AppKit picks the page for a display.
+func encodeTIFF(w io.Writer, pages ...image.Image) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("II*\x00")
+	next := buf.Len() // offset of the pointer to the next IFD
+	buf.Write(make([]byte, 4))
+
+	baseWidth := pages[0].Bounds().Dx()
+	for _, page := range pages {
+		bounds := page.Bounds()
+		rgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Rect, page, bounds.Min, draw.Src)
+
+		stripOffset := buf.Len()
+		zw := zlib.NewWriter(buf)
+		if _, err := zw.Write(rgba.Pix); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		stripLength := buf.Len() - stripOffset
+
+		// values that do not fit in their entry, word aligned
+		if buf.Len()%2 != 0 {
+			buf.WriteByte(0)
+		}
+		bitsOffset := buf.Len()
+		buf.Write([]byte{8, 0, 8, 0, 8, 0, 8, 0})
+		resolutionOffset := buf.Len()
+		dpi := uint32(72 * bounds.Dx() / baseWidth)
+		binary.Write(buf, binary.LittleEndian, []uint32{dpi, 1})
+
+		entries := [][4]uint32{
+			{tagImageWidth, tiffLong, 1, uint32(bounds.Dx())},
+			{tagImageLength, tiffLong, 1, uint32(bounds.Dy())},
+			{tagBitsPerSample, tiffShort, 4, uint32(bitsOffset)},
+			{tagCompression, tiffShort, 1, 8}, // deflate
+			{tagPhotometric, tiffShort, 1, 2}, // RGB
+			{tagStripOffsets, tiffLong, 1, uint32(stripOffset)},
+			{tagSamplesPerPixel, tiffShort, 1, 4},
+			{tagRowsPerStrip, tiffLong, 1, uint32(bounds.Dy())},
+			{tagStripByteCounts, tiffLong, 1, uint32(stripLength)},
+			{tagXResolution, tiffRational, 1, uint32(resolutionOffset)},
+			{tagYResolution, tiffRational, 1, uint32(resolutionOffset)},
+			{tagPlanarConfig, tiffShort, 1, 1},   // chunky
+			{tagResolutionUnit, tiffShort, 1, 2}, // inch
+			{tagExtraSamples, tiffShort, 1, 2},   // unassociated alpha
+		}
+		binary.LittleEndian.PutUint32(buf.Bytes()[next:], uint32(buf.Len()))
+		binary.Write(buf, binary.LittleEndian, uint16(len(entries)))
+		for _, e := range entries {
+			binary.Write(buf, binary.LittleEndian, uint16(e[0]))
+			binary.Write(buf, binary.LittleEndian, uint16(e[1]))
+			binary.Write(buf, binary.LittleEndian, e[2])
+			// SHORT values are left aligned in the value field
+			binary.Write(buf, binary.LittleEndian, e[3])
+		}
+		next = buf.Len()
+		buf.Write(make([]byte, 4))
+	}
+	if buf.Len() > 1<<32-1 {
+		return fmt.Errorf("image is too large for TIFF")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
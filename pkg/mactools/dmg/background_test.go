@@ -0,0 +1,101 @@
+package dmg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteBackground(t *testing.T) {
+	dir := t.TempDir()
+	writePNG := func(name string, w, h int) string {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for i := range img.Pix {
+			img.Pix[i] = byte(i)
+		}
+		img.Set(0, 0, color.NRGBA{1, 2, 3, 4})
+		buf := &bytes.Buffer{}
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	bg := writePNG("bg.png", 64, 48)
+	bg2x := writePNG("bg@2x.png", 128, 96)
+	if got := retinaBackground(bg); got != bg2x {
+		t.Errorf("retina background = %q, want %q", got, bg2x)
+	}
+	if got := retinaBackground(bg2x); got != "" {
+		t.Errorf("retina background of %s = %q", bg2x, got)
+	}
+
+	config := Config{Background: bg, Background2x: bg2x, WindowWidth: 64, WindowHeight: 48}
+	if backgroundPath(config) != ".background/background.tiff" {
+		t.Errorf("background path = %s", backgroundPath(config))
+	}
+	out := filepath.Join(dir, "background.tiff")
+	if err := writeBackground(config, out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data[:4]) != "II*\x00" {
+		t.Fatalf("header = %q", data[:4])
+	}
+	// walk the IFDs and check the size, the resolution and the pixels of every page
+	le := binary.LittleEndian
+	offset := le.Uint32(data[4:])
+	var pages []string
+	for offset != 0 {
+		ifd := data[offset:]
+		tags := map[uint16]uint32{}
+		for i := 0; i < int(le.Uint16(ifd)); i++ {
+			e := ifd[2+12*i:]
+			tags[le.Uint16(e)] = le.Uint32(e[8:])
+		}
+		width, height := tags[tagImageWidth], tags[tagImageLength]
+		dpi := le.Uint32(data[tags[tagXResolution]:]) / le.Uint32(data[tags[tagXResolution]+4:])
+		strip := data[tags[tagStripOffsets]:][:tags[tagStripByteCounts]]
+		zr, err := zlib.NewReader(bytes.NewReader(strip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pix, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pix) != int(width*height*4) || !bytes.Equal(pix[:4], []byte{1, 2, 3, 4}) || pix[5] != 5 {
+			t.Errorf("page %dx%d has unexpected pixels", width, height)
+		}
+		pages = append(pages, fmt.Sprintf("%dx%d@%d", width, height, dpi))
+		offset = le.Uint32(ifd[2+12*le.Uint16(ifd):])
+	}
+	if strings.Join(pages, " ") != "64x48@72 128x96@144" {
+		t.Errorf("pages = %q", pages)
+	}
+
+	config.WindowWidth = 640
+	if err := writeBackground(config, out); err == nil || !strings.Contains(err.Error(), "the window is 640x48") {
+		t.Errorf("error = %v", err)
+	}
+	config.WindowWidth = 64
+	config.Background2x = bg
+	if err := writeBackground(config, out); err == nil || !strings.Contains(err.Error(), "want 128x96") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+package dmg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ironpark/zapp/pkg/mactools/hdiutil"
+	"gopkg.in/yaml.v3"
+)
+
+// fieldError is a validation error of a config field, named by its JSON
+// path such as "contents[1].type".
+type fieldError struct {
+	field string
+	err   error
+}
+
+// LoadConfig reads a JSON or YAML config file over base, so fields missing
+// from the file keep their base values. Paths in the file are relative to
+// the file. Errors refer to the line of the offending field.
+func LoadConfig(path string, base Config) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return base, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return base, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return base, fmt.Errorf("%s: empty config", path)
+	}
+	lines := map[string]int{}
+	if err := checkNode(doc.Content[0], reflect.TypeOf(Config{}), "", lines); err != nil {
+		return base, fmt.Errorf("%s:%w", path, err)
+	}
+
+	// YAML is converted to JSON so that the JSON field names apply
+	var v any
+	if err := doc.Decode(&v); err != nil {
+		return base, fmt.Errorf("%s: %w", path, err)
+	}
+	if data, err = json.Marshal(v); err != nil {
+		return base, fmt.Errorf("%s: %w", path, err)
+	}
+	config := base
+	config.Contents = nil
+	config.Licenses = nil
+	if err := json.Unmarshal(data, &config); err != nil {
+		return base, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, ok := lines["contents"]; !ok {
+		config.Contents = base.Contents
+	}
+	if _, ok := lines["licenses"]; !ok {
+		config.Licenses = base.Licenses
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(field string, p *string) {
+		if _, ok := lines[field]; ok && *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	resolve("fileName", &config.FileName)
+	resolve("icon", &config.Icon)
+	resolve("background", &config.Background)
+	resolve("background2x", &config.Background2x)
+	resolve("dsStore", &config.DSStore)
+	for i := range config.Contents {
+		// link targets are resolved on the machine that mounts the image
+		if config.Contents[i].Type != Link {
+			resolve(fmt.Sprintf("contents[%d].path", i), &config.Contents[i].Path)
+		}
+	}
+	for lang, license := range config.Licenses {
+		resolve("licenses."+lang, &license)
+		config.Licenses[lang] = license
+	}
+
+	if errs := config.validate(); len(errs) > 0 {
+		e := errs[0]
+		if line, ok := lines[e.field]; ok {
+			return base, fmt.Errorf("%s:%d: %s: %w", path, line, e.field, e.err)
+		}
+		return base, fmt.Errorf("%s: %s: %w", path, e.field, e.err)
+	}
+	return config, nil
+}
+
+// checkNode checks that node matches the JSON fields of t and records the
+// line of every field by its path.
+func checkNode(node *yaml.Node, t reflect.Type, path string, lines map[string]int) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if path != "" {
+		lines[path] = node.Line
+	}
+	if node.Tag == "!!null" {
+		return nil
+	}
+	fail := func(format string, args ...any) error {
+		name := path
+		if name == "" {
+			name = "config"
+		}
+		return fmt.Errorf("%d: %s: %s", node.Line, name, fmt.Sprintf(format, args...))
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return fail("must be a mapping")
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldPath := key.Value
+			if path != "" {
+				fieldPath = path + "." + key.Value
+			}
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				return fmt.Errorf("%d: unknown field %q", key.Line, fieldPath)
+			}
+			if err := checkNode(value, fieldType, fieldPath, lines); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		return checkNode(node, t.Elem(), path, lines)
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return fail("must be a mapping")
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := checkNode(node.Content[i+1], t.Elem(), path+"."+node.Content[i].Value, lines); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return fail("must be a list")
+		}
+		for i, item := range node.Content {
+			if err := checkNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), lines); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+			return fail("must be a string")
+		}
+	case reflect.Int:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			return fail("must be an integer")
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			return fail("must be true or false")
+		}
+	}
+	return nil
+}
+
+// Validate checks the values of the config and that the files it refers to exist.
+func (c Config) Validate() error {
+	if errs := c.validate(); len(errs) > 0 {
+		return fmt.Errorf("%s: %w", errs[0].field, errs[0].err)
+	}
+	return nil
+}
+
+func (c Config) validate() []fieldError {
+	var errs []fieldError
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, fieldError{field, err})
+		}
+	}
+	between := func(value, min, max int) error {
+		if value != 0 && (value < min || value > max) {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return nil
+	}
+	check("labelSize", between(c.LabelSize, 10, 16))
+	check("iconSize", between(c.ContentsIconSize, 16, 512))
+	check("windowWidth", between(c.WindowWidth, 1, 10000))
+	check("windowHeight", between(c.WindowHeight, 1, 10000))
+	check("icon", fileExists(c.Icon, false))
+	check("background", fileExists(c.Background, false))
+	check("background2x", fileExists(c.Background2x, false))
+	if c.Background2x != "" && c.Background == "" {
+		check("background2x", fmt.Errorf("needs a background"))
+	}
+	if c.Artwork != nil {
+		check("artwork", c.Artwork.validate())
+	}
+	if c.Layout != "" && !slices.Contains(Layouts, c.Layout) {
+		check("layout", fmt.Errorf("must be one of horizontal, vertical, grid, app-with-extras"))
+	}
+	check("dsStore", fileExists(c.DSStore, false))
+	check("viewStyle", validViewStyle(c.ViewStyle))
+	if c.Format != "" && !slices.Contains(Formats, c.Format) {
+		check("format", fmt.Errorf("must be one of %s", joinFormats(Formats)))
+	} else if c.ZlibLevel != 0 {
+		_, err := imageKeys(Config{Format: c.Format, ZlibLevel: c.ZlibLevel})
+		check("zlibLevel", err)
+	}
+	for _, lang := range sortedKeys(c.Licenses) {
+		check("licenses."+lang, checkLicense(lang, c.Licenses[lang]))
+	}
+
+	names := map[string]bool{}
+	for i, item := range c.Contents {
+		field := fmt.Sprintf("contents[%d]", i)
+		if item.Path == "" {
+			check(field+".path", fmt.Errorf("is required"))
+			continue
+		}
+		switch item.Type {
+		case Dir, File:
+			check(field+".path", fileExists(item.Path, item.Type == Dir))
+		case Link:
+		default:
+			check(field+".type", fmt.Errorf("must be one of dir, file, link"))
+		}
+		if item.ViewStyle != "" && item.Type != Dir {
+			check(field+".viewStyle", fmt.Errorf("can only be set for directories"))
+		}
+		check(field+".viewStyle", validViewStyle(item.ViewStyle))
+		name := filepath.Base(item.Path)
+		if names[name] {
+			check(field+".path", fmt.Errorf("another item is also named %q", name))
+		}
+		names[name] = true
+	}
+	return errs
+}
+
+func fileExists(path string, isDir bool) error {
+	if path == "" {
+		return nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if isDir && !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	if !isDir && stat.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return nil
+}
+
+func validViewStyle(style ViewStyle) error {
+	if _, ok := viewStyles[style]; style != "" && !ok {
+		return fmt.Errorf("must be one of icon, list, column")
+	}
+	return nil
+}
+
+func joinFormats(formats []hdiutil.Format) string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
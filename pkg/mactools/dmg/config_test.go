@@ -0,0 +1,68 @@
+package dmg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "build", "My App.app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bg.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "dmg.yaml")
+	err := os.WriteFile(path, []byte(`title: My App
+background: bg.png
+windowWidth: 800
+contents:
+  - {x: 200, y: 240, type: dir, path: "build/My App.app"}
+  - {x: 600, y: 240, type: link, path: /Applications}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path, Config{WindowWidth: 640, WindowHeight: 480, LabelSize: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Title != "My App" || config.WindowWidth != 800 || config.WindowHeight != 480 || config.LabelSize != 14 {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if config.Background != filepath.Join(dir, "bg.png") {
+		t.Errorf("background = %s", config.Background)
+	}
+	if len(config.Contents) != 2 || config.Contents[0].Path != filepath.Join(dir, "build", "My App.app") || config.Contents[1].Path != "/Applications" {
+		t.Errorf("contents = %+v", config.Contents)
+	}
+
+	tests := []struct {
+		yaml string
+		want string
+	}{
+		{"title: x\nwindowWidht: 10\n", "dmg.yaml:2: unknown field \"windowWidht\""},
+		{"title: x\nwindowWidth: wide\n", "dmg.yaml:2: windowWidth: must be an integer"},
+		{"contents:\n  - path: /Applications\n    type: alias\n", "dmg.yaml:3: contents[0].type: must be one of dir, file, link"},
+		{"contents:\n  - path: missing.app\n    type: dir\n", "dmg.yaml:2: contents[0].path:"},
+		{"labelSize: 30\n", "dmg.yaml:1: labelSize: must be between 10 and 16"},
+		{"format: UDRW\n", "dmg.yaml:1: format: must be one of"},
+		{"title: x\nlicenses:\n  xx: eula.txt\n", "dmg.yaml:3: licenses.xx: invalid language code: xx"},
+		{"licenses: eula.txt\n", "dmg.yaml:1: licenses: must be a mapping"},
+		{"layout: diagonal\n", "dmg.yaml:1: layout: must be one of horizontal, vertical, grid, app-with-extras"},
+		{"artwork:\n  fontsize: 12\n", "dmg.yaml:2: unknown field \"artwork.fontsize\""},
+		{"artwork:\n  color: blue\n", "dmg.yaml:2: artwork: invalid colour \"blue\""},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadConfig(path, Config{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadConfig(%q) = %v, want %q", tt.yaml, err, tt.want)
+		}
+	}
+}
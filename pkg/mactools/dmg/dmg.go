@@ -7,25 +7,54 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/ironpark/zapp/pkg/fsutil"
+	"github.com/ironpark/zapp/pkg/mactools/alias"
 	"github.com/ironpark/zapp/pkg/mactools/dsstore"
 	"github.com/ironpark/zapp/pkg/mactools/hdiutil"
+	"github.com/ironpark/zapp/pkg/mactools/rsrc"
+	"github.com/ironpark/zapp/pkg/mactools/udif"
 )
 
 // Config represents the configuration for the DMG file.
 type Config struct {
-	FileName         string `json:"fileName"`
-	Title            string `json:"title"`
-	Icon             string `json:"icon"`
-	LabelSize        int    `json:"labelSize"`
-	ContentsIconSize int    `json:"iconSize"`
-	WindowWidth      int    `json:"windowWidth"`
-	WindowHeight     int    `json:"windowHeight"`
-	Background       string `json:"background"`
-	Contents         []Item `json:"contents"`
-	LogWriter        io.Writer
+	FileName         string    `json:"fileName"`
+	Title            string    `json:"title"`
+	Icon             string    `json:"icon"`
+	LabelSize        int       `json:"labelSize"`
+	ContentsIconSize int       `json:"iconSize"`
+	WindowWidth      int       `json:"windowWidth"`
+	WindowHeight     int       `json:"windowHeight"`
+	WindowX          int       `json:"windowX"` // from the left of the screen
+	WindowY          int       `json:"windowY"` // from the top of the screen
+	ShowToolbar      bool      `json:"showToolbar"`
+	ShowSidebar      bool      `json:"showSidebar"`
+	SidebarWidth     int       `json:"sidebarWidth"`
+	ShowPathbar      bool      `json:"showPathbar"`
+	ShowStatusBar    bool      `json:"showStatusBar"`
+	LegacyFinder     bool      `json:"legacyFinder"` // also write the records read by old Finder versions
+	Background       string    `json:"background"`
+	Background2x     string    `json:"background2x"` // Retina variant of the background, bg@2x.png next to bg.png by default
+	Artwork          *Artwork  `json:"artwork"`      // generates the background when there is no background image
+	Contents         []Item    `json:"contents"`
+	Layout           Layout    `json:"layout"`      // positions the items without coordinates, app-with-extras by default
+	ViewStyle        ViewStyle `json:"viewStyle"`   // icon (default), list or column
+	ListColumns      []string  `json:"listColumns"` // visible list view columns, e.g. name, dateModified, size, kind
+	SortBy           string    `json:"sortBy"`      // list view sort column
+	DSStore          string    `json:"dsStore"`     // .DS_Store or JSON/YAML layout whose records replace the generated ones
+	LogWriter        io.Writer `json:"-"`
+
+	Format    hdiutil.Format `json:"format"`    // format of the final image, UDZO when empty
+	ZlibLevel int            `json:"zlibLevel"` // zlib compression level of UDZO images (1-9), 0 for the default
+
+	Licenses map[string]string `json:"licenses"` // license agreement files (text or RTF) by ISO 639-1 language code
+
+	// Reproducible writes the image without hdiutil, dated SOURCE_DATE_EPOCH
+	// and with normalized permissions, so the same inputs give the same bytes.
+	Reproducible bool `json:"reproducible"`
 }
 
 type ItemType string
@@ -38,14 +67,69 @@ const (
 
 // Item represents an item in the DMG file.
 type Item struct {
-	X    int      `json:"x"`
-	Y    int      `json:"y"`
-	Type ItemType `json:"type"`
-	Path string   `json:"path"`
+	X         int       `json:"x"`
+	Y         int       `json:"y"`
+	Type      ItemType  `json:"type"`
+	Path      string    `json:"path"`
+	ViewStyle ViewStyle `json:"viewStyle,omitempty"` // view style of the folder window (dir only)
+}
+
+// Formats lists the formats the final image can be converted to.
+var Formats = []hdiutil.Format{hdiutil.UDRO, hdiutil.UDCO, hdiutil.UDZO, hdiutil.UDBZ, hdiutil.ULFO, hdiutil.ULMO}
+
+// imageKeys validates the format and the compression level of config and
+// returns the matching -imagekey options of hdiutil convert.
+func imageKeys(config Config) ([]string, error) {
+	if !slices.Contains(Formats, config.Format) {
+		return nil, fmt.Errorf("unsupported format: %s", config.Format)
+	}
+	if config.ZlibLevel == 0 {
+		return nil, nil
+	}
+	if config.Format != hdiutil.UDZO {
+		return nil, fmt.Errorf("zlib level only applies to the %s format", hdiutil.UDZO)
+	}
+	if config.ZlibLevel < 1 || config.ZlibLevel > 9 {
+		return nil, fmt.Errorf("zlib level must be between 1 and 9, or 0 for the hdiutil default")
+	}
+	return []string{fmt.Sprintf("zlib-level=%d", config.ZlibLevel)}, nil
 }
 
 // CreateDMG creates a DMG file with the specified configuration.
 func CreateDMG(config Config, sourceDir string) error {
+	if config.Format == "" {
+		config.Format = hdiutil.UDZO
+	}
+	keys, err := imageKeys(config)
+	if err != nil {
+		return err
+	}
+	licenses, err := licenseResources(config.Licenses)
+	if err != nil {
+		return err
+	}
+	var sourceDate time.Time
+	if config.Reproducible {
+		if sourceDate, err = fsutil.SourceDate(); err != nil {
+			return err
+		}
+	}
+	if err := config.ApplyLayout(); err != nil {
+		return err
+	}
+	if config.Background == "" && config.Artwork != nil {
+		dir, err := os.MkdirTemp("", "*-zapp-dmg-background")
+		if err != nil {
+			return fmt.Errorf("error creating temporary directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		if config.Background, config.Background2x, err = renderArtwork(config, dir); err != nil {
+			return fmt.Errorf("failed to generate background: %w", err)
+		}
+	}
+	if config.Background != "" && config.Background2x == "" {
+		config.Background2x = retinaBackground(config.Background)
+	}
 	// Create the source directory if it doesn't exist
 	if err := os.MkdirAll(sourceDir, 0755); err != nil {
 		return fmt.Errorf("failed to create source directory: %w", err)
@@ -58,15 +142,32 @@ func CreateDMG(config Config, sourceDir string) error {
 		config.LogWriter = os.Stdout
 	}
 	store := dsstore.NewDSStore()
+	store.Legacy = config.LegacyFinder
+	// the image is mounted under its title, whatever its mount point is
+	store.Volume = alias.NamedVolumeInfo{VolumeInfo: alias.DefaultVolumeInfo, Name: config.Title}
 	store.SetIconSize(float64(config.ContentsIconSize))
-	store.SetWindow(config.WindowWidth, config.WindowHeight, 0, 0)
+	store.SetWindow(config.WindowWidth, config.WindowHeight, config.WindowX, config.WindowY)
+	store.SetToolbarVisible(config.ShowToolbar)
+	store.SetSidebar(config.ShowSidebar, config.SidebarWidth)
+	store.SetPathbarVisible(config.ShowPathbar)
+	store.SetStatusBarVisible(config.ShowStatusBar)
 	store.SetLabelSize(float64(config.LabelSize))
 	store.SetLabelPlaceToBottom(true)
 	store.SetBgToDefault()
 	for _, content := range config.Contents {
 		store.SetIconPos(filepath.Base(content.Path), uint32(content.X), uint32(content.Y))
 	}
-	err := store.Write(filepath.Join(sourceDir, ".DS_Store"))
+	if err := setViewStyles(store, config); err != nil {
+		return err
+	}
+	if config.DSStore != "" {
+		layout, err := dsstore.Open(config.DSStore)
+		if err != nil {
+			return fmt.Errorf("failed to read .DS_Store layout: %w", err)
+		}
+		store.Merge(layout)
+	}
+	err = store.Write(filepath.Join(sourceDir, ".DS_Store"))
 	if err != nil {
 		return fmt.Errorf("failed to write .DS_Store: %w", err)
 	}
@@ -79,6 +180,18 @@ func CreateDMG(config Config, sourceDir string) error {
 	if !strings.HasSuffix(config.FileName, ".dmg") {
 		config.FileName += ".dmg"
 	}
+	// hdiutil stamps images with the current time and random IDs
+	if _, err := exec.LookPath("hdiutil"); err != nil || config.Reproducible {
+		if err := createNative(config, sourceDir, store, licenses, sourceDate); err != nil {
+			return err
+		}
+		if config.Icon != "" {
+			if err := setFileIcon(config.FileName, config.Icon); err != nil {
+				return fmt.Errorf("failed to set file icon: %w", err)
+			}
+		}
+		return nil
+	}
 	ctx := context.Background()
 	// Create the DMG file using hdiutil
 	if err := hdiutil.Create(ctx, config.Title, sourceDir, hdiutil.UDRW, config.FileName); err != nil {
@@ -94,16 +207,21 @@ func CreateDMG(config Config, sourceDir string) error {
 				}
 			}
 			if config.Background != "" {
-				store.SetBackgroundImage(filepath.Join(mountPoint, ".background", "background.png"))
+				if err := store.SetBackgroundImage(filepath.Join(mountPoint, filepath.FromSlash(backgroundPath(config)))); err != nil {
+					return fmt.Errorf("failed to set background image: %w", err)
+				}
 				if err := store.Write(filepath.Join(mountPoint, ".DS_Store")); err != nil {
 					return fmt.Errorf("failed to write .DS_Store: %w", err)
 				}
 			}
 			return nil
 		})
+		if err != nil {
+			return fmt.Errorf("failed to customize DMG: %w", err)
+		}
 	}
 
-	// Convert the DMG to read-only
+	// Convert the DMG to the final, read-only format
 	tempFileName := "temp_" + config.FileName
 	dir, file := filepath.Split(config.FileName)
 	tempFileName = filepath.Join(dir, fmt.Sprintf("temp_%d_%s.dmg", time.Now().UnixNano(), file))
@@ -111,51 +229,29 @@ func CreateDMG(config Config, sourceDir string) error {
 		return fmt.Errorf("failed to rename DMG file: %w", err)
 	}
 	defer os.Remove(tempFileName) // Ensure cleanup of temp file
-	if err := hdiutil.Convert(ctx, tempFileName, hdiutil.UDRO, config.FileName); err != nil {
+	if err := hdiutil.Convert(ctx, tempFileName, config.Format, config.FileName, keys...); err != nil {
 		return fmt.Errorf("failed to convert DMG: %w", err)
 	}
+	if len(licenses) > 0 {
+		if err := udif.SetResources(config.FileName, licenses); err != nil {
+			return fmt.Errorf("failed to add license agreement: %w", err)
+		}
+	}
 	if config.Icon != "" {
-		setFileIcon(config.FileName, config.Icon)
+		if err := setFileIcon(config.FileName, config.Icon); err != nil {
+			return fmt.Errorf("failed to set file icon: %w", err)
+		}
 	}
 	return nil
 }
 
+// setFileIcon gives the dmg file the icon in Finder.
 func setFileIcon(dmgPath, iconPath string) error {
-	// Create temporary mount point
-	tempDir, err := os.MkdirTemp("", "*-zapp-dmg")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary directory: %w", err)
-	}
-	defer os.RemoveAll(tempDir) // Ensure cleanup of temp directory
-
-	tempIconPath := filepath.Join(tempDir, "icon.icns")
-	err = copyFile(iconPath, tempIconPath)
+	icns, err := os.ReadFile(iconPath)
 	if err != nil {
-		return fmt.Errorf("failed to copy icon: %w", err)
-	}
-
-	cmd := exec.Command("sips", "-i", tempIconPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set icon: %s, output: %s", err, string(output))
-	}
-	cmd = exec.Command("DeRez", "-only", "icns", tempIconPath)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to DeRez icon: %s, output: %s", err, string(output))
-	}
-	rsrcPath := filepath.Join(tempDir, "icns.rsrc")
-	if err := os.WriteFile(rsrcPath, output, 0644); err != nil {
-		return fmt.Errorf("failed to write icns.rsrc: %w", err)
-	}
-	cmd = exec.Command("Rez", "-append", rsrcPath, "-o", dmgPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to append icns.rsrc: %s, output: %s", err, string(output))
+		return fmt.Errorf("failed to read icon: %w", err)
 	}
-	cmd = exec.Command("SetFile", "-a", "C", dmgPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set icon: %s, output: %s", err, string(output))
-	}
-	return nil
+	return rsrc.SetCustomIcon(dmgPath, icns)
 }
 
 func tmpMount(dmgPath string, process func(dmgFilePath string, mountPoint string) error) error {
@@ -185,19 +281,11 @@ func setDMGIcon(mountPoint, iconPath string) error {
 		return fmt.Errorf("failed to copy icon to mount point: %w", err)
 	}
 
-	// Set the icon
-	cmd := exec.Command("SetFile", "-c", "icnC", iconFile)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set icon: %s, output: %s", err, string(output))
-	}
-
-	// Tell the volume that it has a special file attribute
-	cmd = exec.Command("SetFile", "-a", "C", mountPoint)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set icon: %s, output: %s", err, string(output))
+	if err := rsrc.SetCreator(iconFile, "icnC"); err != nil {
+		return err
 	}
-
-	return nil
+	// Tell the volume that it has a custom icon
+	return rsrc.SetFlags(mountPoint, rsrc.HasCustomIcon)
 }
 
 // setupSourceDirectory sets up the source directory with the necessary files.
@@ -233,7 +321,7 @@ func setupSourceDirectory(config Config, sourceDir string) error {
 		if err := os.MkdirAll(backgroundDir, 0755); err != nil {
 			return fmt.Errorf("failed to create .background directory: %w", err)
 		}
-		if err := copyFile(config.Background, filepath.Join(backgroundDir, "background.png")); err != nil {
+		if err := writeBackground(config, filepath.Join(sourceDir, filepath.FromSlash(backgroundPath(config)))); err != nil {
 			return fmt.Errorf("failed to copy background: %s", err)
 		}
 	}
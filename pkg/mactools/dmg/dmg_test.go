@@ -1,8 +1,15 @@
 package dmg
 
 import (
+	"bytes"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/ironpark/zapp/pkg/mactools/hdiutil"
 )
 
 func TestCreateDMG(t *testing.T) {
@@ -27,3 +34,67 @@ func TestCreateDMG(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestImageKeys(t *testing.T) {
+	tests := []struct {
+		config  Config
+		keys    []string
+		wantErr bool
+	}{
+		{Config{Format: hdiutil.UDZO}, nil, false},
+		{Config{Format: hdiutil.UDZO, ZlibLevel: 9}, []string{"zlib-level=9"}, false},
+		{Config{Format: hdiutil.ULFO}, nil, false},
+		{Config{Format: hdiutil.ULFO, ZlibLevel: 9}, nil, true},
+		{Config{Format: hdiutil.UDZO, ZlibLevel: 10}, nil, true},
+		{Config{Format: hdiutil.UDRW}, nil, true},
+	}
+	for _, tt := range tests {
+		keys, err := imageKeys(tt.config)
+		if (err != nil) != tt.wantErr || !slices.Equal(keys, tt.keys) {
+			t.Errorf("imageKeys(%s, %d) = %q, %v", tt.config.Format, tt.config.ZlibLevel, keys, err)
+		}
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	var images [2][]byte
+	for i := range images {
+		dir := t.TempDir()
+		app := filepath.Join(dir, "App.app")
+		if err := os.MkdirAll(filepath.Join(app, "Contents"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		plist := filepath.Join(app, "Contents", "Info.plist")
+		if err := os.WriteFile(plist, []byte("<plist/>"), os.FileMode(0600+i*0044)); err != nil {
+			t.Fatal(err)
+		}
+		modified := time.Now().Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(plist, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Join(dir, "App.dmg")
+		err := CreateDMG(Config{
+			FileName:     name,
+			Title:        "App",
+			WindowWidth:  640,
+			WindowHeight: 480,
+			Artwork:      &Artwork{Text: "Drag to install"},
+			Reproducible: true,
+			LogWriter:    io.Discard,
+			Contents: []Item{
+				{Type: Dir, Path: app},
+				{Type: Link, Path: "/Applications"},
+			},
+		}, filepath.Join(dir, "source"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if images[i], err = os.ReadFile(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(images[0], images[1]) {
+		t.Error("images differ")
+	}
+}
@@ -0,0 +1,166 @@
+package dmg
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ironpark/zapp/pkg/mactools/hfsplus"
+	"github.com/ironpark/zapp/pkg/mactools/udif"
+)
+
+// Image is a DMG file opened for reading, without mounting it.
+type Image struct {
+	*udif.Image
+	// Partition is the partition holding the HFS+ volume.
+	Partition *udif.Partition
+	Volume    *hfsplus.Volume
+	file      *os.File
+}
+
+// Open reads the UDIF structures of the DMG file at name and the catalog of
+// its HFS+ volume.
+func Open(name string) (*Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	img, err := openImage(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	return img, nil
+}
+
+func openImage(f *os.File) (*Image, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	udifImage, err := udif.Open(f, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	return newImage(f, udifImage)
+}
+
+// newImage finds the HFS+ partition of the UDIF image in f and opens its volume.
+func newImage(f *os.File, udifImage *udif.Image) (*Image, error) {
+	var err error
+	img := &Image{Image: udifImage, file: f}
+	for i := range udifImage.Partitions {
+		p := &udifImage.Partitions[i]
+		if strings.Contains(p.Name, "Apple_HFS") {
+			img.Partition = p
+			break
+		}
+	}
+	if img.Partition == nil {
+		if len(udifImage.Partitions) != 1 {
+			return nil, fmt.Errorf("no Apple_HFS partition found")
+		}
+		img.Partition = &udifImage.Partitions[0]
+	}
+	if img.Volume, err = hfsplus.Open(udifImage.Open(img.Partition)); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func (img *Image) Close() error {
+	return img.file.Close()
+}
+
+// Extract copies the files at names, relative to the root of the volume, and
+// everything below them into dest. The whole volume is extracted when no
+// names are given. Resource forks are not extracted.
+func (img *Image) Extract(dest string, names ...string) error {
+	if len(names) == 0 {
+		names = []string{"."}
+	}
+	for _, name := range names {
+		f, err := img.Volume.Lookup(name)
+		if err != nil {
+			return err
+		}
+		// the contents of the root go directly into dest, which keeps its mode
+		files := []*hfsplus.File{f}
+		if f == img.Volume.Root() {
+			if err := os.MkdirAll(dest, 0o755); err != nil {
+				return err
+			}
+			files = f.Children
+		}
+		for _, f := range files {
+			target, err := childPath(dest, f.Name)
+			if err != nil {
+				return err
+			}
+			if err := img.extract(f, target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// childPath joins dir and name, refusing names that would leave dir.
+func childPath(dir, name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func (img *Image) extract(f *hfsplus.File, target string) error {
+	switch {
+	case f.IsDir():
+		// keep the folder writable until its children are extracted
+		if err := os.MkdirAll(target, f.Mode.Perm()|0o700); err != nil {
+			return err
+		}
+		for _, child := range f.Children {
+			childTarget, err := childPath(target, child.Name)
+			if err != nil {
+				return err
+			}
+			if err := img.extract(child, childTarget); err != nil {
+				return err
+			}
+		}
+		if err := os.Chmod(target, f.Mode.Perm()); err != nil {
+			return err
+		}
+	case f.Mode&fs.ModeSymlink != 0:
+		link, err := img.Volume.Readlink(f)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	default:
+		if err := img.extractFile(f, target); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+		}
+	}
+	return os.Chtimes(target, f.Modified, f.Modified)
+}
+
+func (img *Image) extractFile(f *hfsplus.File, target string) error {
+	r, err := img.Volume.Data(f)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,171 @@
+package dmg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type Layout string
+
+const (
+	HorizontalLayout    Layout = "horizontal"      // one row
+	VerticalLayout      Layout = "vertical"        // one column
+	GridLayout          Layout = "grid"            // rows as wide as the window allows
+	AppWithExtrasLayout Layout = "app-with-extras" // the app and Applications, the other items in rows below
+)
+
+// Layouts lists the layout presets.
+var Layouts = []Layout{HorizontalLayout, VerticalLayout, GridLayout, AppWithExtrasLayout}
+
+// AppItem returns the index of the first app bundle in contents, or -1.
+func AppItem(contents []Item) int {
+	for i, item := range contents {
+		if item.Type == Dir && strings.HasSuffix(item.Path, ".app") {
+			return i
+		}
+	}
+	return -1
+}
+
+// cell returns the icon and label size and the space an item takes in a
+// layout. Icon positions are the centers of the icons, the label takes up
+// to two lines below the icon.
+func (c *Config) cell() (iconSize, labelSize, width, height int) {
+	iconSize, labelSize = c.ContentsIconSize, c.LabelSize
+	if iconSize == 0 {
+		iconSize = 128
+	}
+	if labelSize == 0 {
+		labelSize = 14
+	}
+	return iconSize, labelSize, iconSize + 4*labelSize, iconSize + 3*labelSize
+}
+
+// PlaceItems appends items to the contents and positions them in rows below
+// the existing contents, each row centered in the window. The window grows to
+// fit the rows unless it has a background image, whose size it must match.
+func (c *Config) PlaceItems(items ...Item) {
+	_, _, cellWidth, rowHeight := c.cell()
+	perRow := max(1, c.WindowWidth/cellWidth)
+
+	y := rowHeight / 2
+	for i, item := range c.Contents {
+		if i == 0 || item.Y+rowHeight > y {
+			y = item.Y + rowHeight
+		}
+	}
+	for start := 0; start < len(items); start += perRow {
+		row := items[start:min(start+perRow, len(items))]
+		for i, item := range row {
+			item.X = c.WindowWidth * (2*i + 1) / (2 * len(row))
+			item.Y = y
+			c.Contents = append(c.Contents, item)
+		}
+		y += rowHeight
+	}
+	if bottom := y - rowHeight/2; c.Background == "" && len(items) > 0 && bottom > c.WindowHeight {
+		c.WindowHeight = bottom
+	}
+}
+
+// ApplyLayout positions the items without coordinates with the layout preset,
+// app-with-extras if none is set, and checks that no item overlaps another
+// or falls outside the window. Items with coordinates keep them.
+func (c *Config) ApplyLayout() error {
+	var placed, unplaced []Item
+	for _, item := range c.Contents {
+		if item.X == 0 && item.Y == 0 {
+			unplaced = append(unplaced, item)
+		} else {
+			placed = append(placed, item)
+		}
+	}
+	if len(unplaced) == 0 {
+		return c.checkLayout()
+	}
+	layout := c.Layout
+	if layout == "" {
+		layout = AppWithExtrasLayout
+	}
+	_, _, cellWidth, _ := c.cell()
+	switch layout {
+	case HorizontalLayout:
+		c.Contents = append(placed, grid(unplaced, c.WindowWidth, c.WindowHeight, len(unplaced))...)
+	case VerticalLayout:
+		c.Contents = append(placed, grid(unplaced, c.WindowWidth, c.WindowHeight, 1)...)
+	case GridLayout:
+		c.Contents = append(placed, grid(unplaced, c.WindowWidth, c.WindowHeight, max(1, c.WindowWidth/cellWidth))...)
+	case AppWithExtrasLayout:
+		c.appWithExtras(placed, unplaced)
+	default:
+		return fmt.Errorf("unknown layout: %s", layout)
+	}
+	return c.checkLayout()
+}
+
+// grid spreads items evenly over the window in rows of up to perRow items.
+func grid(items []Item, width, height, perRow int) []Item {
+	rows := (len(items) + perRow - 1) / perRow
+	for r := 0; r < rows; r++ {
+		row := items[r*perRow : min((r+1)*perRow, len(items))]
+		for i := range row {
+			row[i].X = width * (2*i + 1) / (2 * len(row))
+			row[i].Y = height * (2*r + 1) / (2 * rows)
+		}
+	}
+	return items
+}
+
+// appWithExtras puts the app and the Applications link side by side and the
+// other items in rows below them, the whole block centered vertically.
+func (c *Config) appWithExtras(placed, unplaced []Item) {
+	var main, extras []Item
+	app, link := AppItem(unplaced), -1
+	for i, item := range unplaced {
+		if item.Type == Link && link < 0 {
+			link = i
+		}
+	}
+	for i, item := range unplaced {
+		if i == app || i == link {
+			main = append(main, item)
+		} else {
+			extras = append(extras, item)
+		}
+	}
+	_, _, cellWidth, rowHeight := c.cell()
+	perRow := max(1, c.WindowWidth/cellWidth)
+	rows := (len(extras) + perRow - 1) / perRow
+	y := max(rowHeight/2, c.WindowHeight/2-rows*rowHeight/2)
+	for i := range main {
+		main[i].X = c.WindowWidth * (i + 1) / (len(main) + 1)
+		main[i].Y = y
+	}
+	c.Contents = append(placed, main...)
+	c.PlaceItems(extras...)
+}
+
+// checkLayout checks that the icons and labels of the items do not overlap
+// and are within the window.
+func (c *Config) checkLayout() error {
+	iconSize, labelSize, _, _ := c.cell()
+	type rect struct{ x0, y0, x1, y1 int }
+	bounds := func(item Item) rect {
+		w := iconSize + 2*labelSize
+		return rect{item.X - w/2, item.Y - iconSize/2, item.X + w/2, item.Y + iconSize/2 + 2*labelSize}
+	}
+	for i, a := range c.Contents {
+		ra := bounds(a)
+		if ra.x0 < 0 || ra.y0 < 0 || ra.x1 > c.WindowWidth || ra.y1 > c.WindowHeight {
+			return fmt.Errorf("%s at %d, %d does not fit in the %dx%d window", filepath.Base(a.Path), a.X, a.Y, c.WindowWidth, c.WindowHeight)
+		}
+		for _, b := range c.Contents[:i] {
+			rb := bounds(b)
+			if ra.x0 < rb.x1 && rb.x0 < ra.x1 && ra.y0 < rb.y1 && rb.y0 < ra.y1 {
+				return fmt.Errorf("%s at %d, %d overlaps %s at %d, %d", filepath.Base(a.Path), a.X, a.Y, filepath.Base(b.Path), b.X, b.Y)
+			}
+		}
+	}
+	return nil
+}
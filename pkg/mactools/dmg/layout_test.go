@@ -0,0 +1,110 @@
+package dmg
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlaceItems(t *testing.T) {
+	config := Config{
+		WindowWidth:      640,
+		WindowHeight:     480,
+		ContentsIconSize: 128,
+		LabelSize:        14,
+		Contents: []Item{
+			{X: 149, Y: 190, Type: Dir, Path: "App.app"},
+			{X: 490, Y: 190, Type: Link, Path: "/Applications"},
+		},
+	}
+	config.PlaceItems(
+		Item{Type: File, Path: "README.pdf"},
+		Item{Type: File, Path: "LICENSE"},
+		Item{Type: Dir, Path: "Uninstall.app"},
+		Item{Type: File, Path: "CHANGELOG.md"},
+	)
+	want := []Item{
+		{X: 106, Y: 360, Type: File, Path: "README.pdf"},
+		{X: 320, Y: 360, Type: File, Path: "LICENSE"},
+		{X: 533, Y: 360, Type: Dir, Path: "Uninstall.app"},
+		{X: 320, Y: 530, Type: File, Path: "CHANGELOG.md"},
+	}
+	if len(config.Contents) != 6 {
+		t.Fatalf("contents = %v", config.Contents)
+	}
+	for i, item := range config.Contents[2:] {
+		if item != want[i] {
+			t.Errorf("item %d = %+v, want %+v", i, item, want[i])
+		}
+	}
+	if config.WindowHeight != 615 {
+		t.Errorf("window height = %d, want 615", config.WindowHeight)
+	}
+
+	config = Config{WindowWidth: 640, WindowHeight: 480, Background: "bg.png"}
+	config.PlaceItems(Item{Type: File, Path: "a"}, Item{Type: File, Path: "b"})
+	if config.Contents[0].Y != 85 || config.Contents[1].X != 480 || config.WindowHeight != 480 {
+		t.Errorf("contents = %+v, window height = %d", config.Contents, config.WindowHeight)
+	}
+}
+
+func TestApplyLayout(t *testing.T) {
+	items := func() []Item {
+		return []Item{
+			{Type: File, Path: "README.pdf"},
+			{Type: Dir, Path: "App.app"},
+			{Type: Link, Path: "/Applications"},
+		}
+	}
+	tests := []struct {
+		layout        Layout
+		width, height int
+		want          map[string][2]int
+	}{
+		{HorizontalLayout, 800, 600, map[string][2]int{"README.pdf": {133, 300}, "App.app": {400, 300}, "Applications": {666, 300}}},
+		{VerticalLayout, 800, 600, map[string][2]int{"README.pdf": {400, 100}, "App.app": {400, 300}, "Applications": {400, 500}}},
+		{GridLayout, 400, 600, map[string][2]int{"README.pdf": {100, 150}, "App.app": {300, 150}, "Applications": {200, 450}}},
+		{AppWithExtrasLayout, 800, 600, map[string][2]int{"App.app": {266, 215}, "Applications": {533, 215}, "README.pdf": {400, 385}}},
+	}
+	for _, tt := range tests {
+		config := Config{Layout: tt.layout, WindowWidth: tt.width, WindowHeight: tt.height, ContentsIconSize: 128, LabelSize: 14, Contents: items()}
+		if err := config.ApplyLayout(); err != nil {
+			t.Errorf("%s: %v", tt.layout, err)
+			continue
+		}
+		for _, item := range config.Contents {
+			if got := [2]int{item.X, item.Y}; got != tt.want[filepath.Base(item.Path)] {
+				t.Errorf("%s: %s at %v, want %v", tt.layout, item.Path, got, tt.want[filepath.Base(item.Path)])
+			}
+		}
+	}
+
+	// items with coordinates keep them, the extras go below them
+	config := Config{WindowWidth: 800, WindowHeight: 600, ContentsIconSize: 128, LabelSize: 14,
+		Contents: append(items(), Item{X: 700, Y: 260, Type: File, Path: "LICENSE"})}
+	if err := config.ApplyLayout(); err != nil {
+		t.Fatal(err)
+	}
+	if config.Contents[0].Path != "LICENSE" || config.Contents[0].X != 700 || config.Contents[3].Y != 430 {
+		t.Errorf("contents = %+v", config.Contents)
+	}
+
+	config = Config{WindowWidth: 800, WindowHeight: 600, ContentsIconSize: 128, LabelSize: 14,
+		Contents: append(items(), Item{X: 300, Y: 250, Type: File, Path: "LICENSE"})}
+	if err := config.ApplyLayout(); err == nil || !strings.Contains(err.Error(), "App.app at 266, 215 overlaps LICENSE") {
+		t.Errorf("error = %v", err)
+	}
+	// items that all have coordinates are checked too
+	config = Config{WindowWidth: 800, WindowHeight: 600, ContentsIconSize: 128, LabelSize: 14,
+		Contents: []Item{
+			{X: 200, Y: 250, Type: Dir, Path: "App.app"},
+			{X: 300, Y: 250, Type: Link, Path: "/Applications"},
+		}}
+	if err := config.ApplyLayout(); err == nil || !strings.Contains(err.Error(), "Applications at 300, 250 overlaps App.app at 200, 250") {
+		t.Errorf("error = %v", err)
+	}
+	config = Config{Layout: VerticalLayout, WindowWidth: 400, WindowHeight: 300, ContentsIconSize: 128, LabelSize: 14, Contents: items()}
+	if err := config.ApplyLayout(); err == nil || !strings.Contains(err.Error(), "does not fit in the 400x300 window") {
+		t.Errorf("error = %v", err)
+	}
+}
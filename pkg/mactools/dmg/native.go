@@ -0,0 +1,124 @@
+package dmg
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ironpark/zapp/pkg/mactools/alias"
+	"github.com/ironpark/zapp/pkg/mactools/bookmark"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore"
+	"github.com/ironpark/zapp/pkg/mactools/hdiutil"
+	"github.com/ironpark/zapp/pkg/mactools/hfsplus"
+	"github.com/ironpark/zapp/pkg/mactools/udif"
+)
+
+// createNative builds the image without hdiutil by writing the HFS+ volume
+// and the UDIF container directly, so it also works on other platforms.
+// Reproducible images are dated sourceDate.
+func createNative(config Config, sourceDir string, store *dsstore.DSStore, licenses map[string][]udif.Resource, sourceDate time.Time) error {
+	var format udif.Format
+	switch config.Format {
+	case hdiutil.UDRO:
+		format = udif.UDRO
+	case hdiutil.UDZO:
+		format = udif.UDZO
+	default:
+		return fmt.Errorf("format %s needs hdiutil, only %s and %s can be written without it", config.Format, hdiutil.UDRO, hdiutil.UDZO)
+	}
+	if config.Icon != "" {
+		if err := copyFile(config.Icon, filepath.Join(sourceDir, ".VolumeIcon.icns")); err != nil {
+			return fmt.Errorf("failed to copy icon: %w", err)
+		}
+	}
+	opts := hfsplus.Options{VolumeName: config.Title}
+	var segmentID [16]byte
+	if config.Reproducible {
+		// IDs derived from the title and the date instead of random ones
+		id := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", config.Title, sourceDate.Unix())))
+		copy(opts.VolumeUUID[:], id[:8])
+		copy(segmentID[:], id[8:24])
+		opts.Created, opts.ModTime, opts.NormalizePermissions = sourceDate, sourceDate, true
+	}
+	volume, err := hfsplus.NewBuilder(sourceDir, opts)
+	if err != nil {
+		return fmt.Errorf("failed to read source directory: %w", err)
+	}
+	if config.Icon != "" {
+		if err := volume.SetFinderInfo(".VolumeIcon.icns", hfsplus.FinderInfo{Creator: "icnC"}); err != nil {
+			return err
+		}
+		if err := volume.SetFinderInfo(".", hfsplus.FinderInfo{Flags: hfsplus.HasCustomIcon}); err != nil {
+			return err
+		}
+	}
+	if config.Background != "" {
+		if err := setNativeBackground(volume, sourceDir, backgroundPath(config), config.Title, store); err != nil {
+			return err
+		}
+	}
+
+	out, err := os.Create(config.FileName)
+	if err != nil {
+		return fmt.Errorf("failed to create dmg: %w", err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := volume.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+	if err := udif.Write(out, pr, udif.Options{Format: format, Level: config.ZlibLevel, Resources: licenses, SegmentID: segmentID}); err != nil {
+		pr.CloseWithError(err)
+		out.Close()
+		os.Remove(config.FileName)
+		return fmt.Errorf("failed to create dmg: %w", err)
+	}
+	return out.Close()
+}
+
+// setNativeBackground refers to the background image with an alias and
+// bookmark data, by the catalog node IDs it gets in the image, and rewrites
+// the .DS_Store of the volume.
+func setNativeBackground(volume *hfsplus.Builder, sourceDir, background, title string, store *dsstore.DSStore) error {
+	targetID, err := volume.FileID(background)
+	if err != nil {
+		return err
+	}
+	parentID, err := volume.FileID(".background")
+	if err != nil {
+		return err
+	}
+	modified, err := volume.ModTime(background)
+	if err != nil {
+		return err
+	}
+	aliasData, err := alias.CreateVirtual(alias.VirtualTarget{
+		VolumeName:    title,
+		VolumeCreated: volume.Created(),
+		Path:          background,
+		Created:       modified,
+		TargetID:      targetID,
+		ParentID:      parentID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to refer to background image: %w", err)
+	}
+	bookmarkData, err := bookmark.CreateVirtual(bookmark.VirtualTarget{
+		VolumeName:    title,
+		VolumeCreated: volume.Created(),
+		Path:          background,
+		Created:       modified,
+		CNIDs:         []uint32{parentID, targetID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to refer to background image: %w", err)
+	}
+	store.SetBackgroundImageData(aliasData, bookmarkData)
+	if err := store.Write(filepath.Join(sourceDir, ".DS_Store")); err != nil {
+		return fmt.Errorf("failed to write .DS_Store: %w", err)
+	}
+	return nil
+}
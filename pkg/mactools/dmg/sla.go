@@ -0,0 +1,202 @@
+package dmg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ironpark/zapp/pkg/mactools/pkg"
+	"github.com/ironpark/zapp/pkg/mactools/udif"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"github.com/samber/lo"
+)
+
+// slaBaseID is the resource ID of the LPic resource. The resources of the
+// n-th language have the ID slaBaseID+n.
+const slaBaseID = 5000
+
+// slaLanguage is what the license dialog needs to show a language.
+type slaLanguage struct {
+	region    uint16 // Mac OS region code
+	encoding  encoding.Encoding
+	multibyte bool
+	// language name, the Agree, Disagree, Print and Save buttons and the message above the license
+	strings [6]string
+}
+
+var slaLanguages = map[string]slaLanguage{
+	"en": {0, charmap.Macintosh, false, [6]string{
+		"English", "Agree", "Disagree", "Print", "Save...",
+		`If you agree with the terms of this license, press "Agree" to install the software. If you do not agree, press "Disagree".`,
+	}},
+	"fr": {1, charmap.Macintosh, false, [6]string{
+		"Français", "Accepter", "Refuser", "Imprimer", "Enregistrer...",
+		`Si vous acceptez les termes de la présente licence, cliquez sur "Accepter" afin d'installer le logiciel. Si vous n'êtes pas d'accord avec les termes de la licence, cliquez sur "Refuser".`,
+	}},
+	"de": {3, charmap.Macintosh, false, [6]string{
+		"Deutsch", "Akzeptieren", "Ablehnen", "Drucken", "Sichern...",
+		`Klicken Sie auf "Akzeptieren", wenn Sie mit den Bestimmungen des Lizenzvertrags einverstanden sind, um die Software zu installieren. Falls nicht, klicken Sie auf "Ablehnen".`,
+	}},
+	"it": {4, charmap.Macintosh, false, [6]string{
+		"Italiano", "Accetto", "Rifiuto", "Stampa", "Registra...",
+		`Se accetti le condizioni di questa licenza, fai clic su "Accetto" per installare il software. Altrimenti fai clic su "Rifiuto".`,
+	}},
+	"nl": {5, charmap.Macintosh, false, [6]string{
+		"Nederlands", "Ja", "Nee", "Print", "Bewaar...",
+		`Indien u akkoord gaat met de voorwaarden van deze licentie, kunt u op "Ja" klikken om de programmatuur te installeren. Indien u niet akkoord gaat, klikt u op "Nee".`,
+	}},
+	"sv": {7, charmap.Macintosh, false, [6]string{
+		"Svenska", "Godkänns", "Avböjes", "Skriv ut", "Spara...",
+		`Om du godkänner licensvillkoren klickar du på "Godkänns" för att installera programvaran. Om du inte godkänner licensvillkoren klickar du på "Avböjes".`,
+	}},
+	"es": {8, charmap.Macintosh, false, [6]string{
+		"Español", "Aceptar", "No aceptar", "Imprimir", "Guardar...",
+		`Si está de acuerdo con los términos de esta licencia, pulse "Aceptar" para instalar el software. Si no está de acuerdo, pulse "No aceptar".`,
+	}},
+	"da": {9, charmap.Macintosh, false, [6]string{
+		"Dansk", "Enig", "Uenig", "Udskriv", "Arkiver...",
+		`Hvis du accepterer betingelserne i licensaftalen, skal du klikke på "Enig" for at installere softwaren. Klik på "Uenig" for at annullere installeringen.`,
+	}},
+	"pt": {10, charmap.Macintosh, false, [6]string{
+		"Português", "Concordar", "Discordar", "Imprimir", "Salvar...",
+		`Se está de acordo com os termos desta licença, pressione "Concordar" para instalar o software. Se não está de acordo, pressione "Discordar".`,
+	}},
+	"no": {12, charmap.Macintosh, false, [6]string{
+		"Norsk", "Enig", "Ikke enig", "Skriv ut", "Arkiver...",
+		`Hvis du er enig i bestemmelsene i denne lisensavtalen, klikker du på "Enig" for å installere programvaren. Hvis du ikke er enig, klikker du på "Ikke enig".`,
+	}},
+	"ja": {14, japanese.ShiftJIS, true, [6]string{
+		"日本語", "同意します", "同意しません", "印刷する", "保存...",
+		"本ソフトウェア使用許諾契約の条件に同意される場合には、ソフトウェアをインストールするために「同意します」を押してください。同意されない場合には、「同意しません」を押してください。",
+	}},
+	"fi": {17, charmap.Macintosh, false, [6]string{
+		"Suomi", "Hyväksyn", "En hyväksy", "Tulosta", "Tallenna...",
+		`Hyväksy lisenssisopimuksen ehdot osoittamalla "Hyväksyn". Jos et hyväksy sopimuksen ehtoja, osoita "En hyväksy".`,
+	}},
+	"ru": {49, charmap.MacintoshCyrillic, false, [6]string{
+		"Русский", "Принимаю", "Не принимаю", "Напечатать", "Сохранить...",
+		`Если Вы принимаете условия этой лицензии, нажмите "Принимаю", чтобы установить программное обеспечение. Если Вы не согласны, нажмите "Не принимаю".`,
+	}},
+	"ko": {51, korean.EUCKR, true, [6]string{
+		"한국어", "동의", "동의 안함", "프린트", "저장...",
+		`사용 계약서의 내용에 동의하면, "동의" 버튼을 눌러 소프트웨어를 설치하십시오. 동의하지 않는다면, "동의 안함" 버튼을 누르십시오.`,
+	}},
+	"zh": {52, simplifiedchinese.GBK, true, [6]string{
+		"简体中文", "同意", "不同意", "打印", "存储...",
+		"如果您同意本许可协议的条款，请按“同意”来安装此软件。如果您不同意本许可协议的条款，请按“不同意”。",
+	}},
+}
+
+// checkLicense checks the language code and the file of a license.
+func checkLicense(lang, path string) error {
+	if !pkg.IsValidLanguageCode(lang) {
+		return fmt.Errorf("invalid language code: %s", lang)
+	}
+	if _, ok := slaLanguages[strings.ToLower(lang)]; !ok {
+		return fmt.Errorf("no license dialog for language %s, supported are %s", lang, strings.Join(sortedKeys(slaLanguages), ", "))
+	}
+	return fileExists(path, false)
+}
+
+// licenseResources builds the resources of the license agreement shown when
+// the image is mounted. licenses maps language codes to plain text or RTF
+// files, English is the default language when it is present.
+func licenseResources(licenses map[string]string) (map[string][]udif.Resource, error) {
+	if len(licenses) == 0 {
+		return nil, nil
+	}
+	langs := sortedKeys(licenses)
+	defaultLang := langs[0]
+	for _, lang := range langs {
+		if strings.EqualFold(lang, "en") {
+			defaultLang = lang
+		}
+	}
+
+	resources := map[string][]udif.Resource{}
+	add := func(typ string, id int, name string, data []byte) {
+		resources[typ] = append(resources[typ], udif.Resource{
+			Attributes: "0x0000",
+			Data:       data,
+			ID:         strconv.Itoa(id),
+			Name:       name,
+		})
+	}
+	for i, lang := range langs {
+		if err := checkLicense(lang, licenses[lang]); err != nil {
+			return nil, fmt.Errorf("license %s: %w", lang, err)
+		}
+		l := slaLanguages[strings.ToLower(lang)]
+		id := slaBaseID + i
+		name := l.strings[0]
+
+		buttons := binary.BigEndian.AppendUint16(nil, uint16(len(l.strings)))
+		for _, s := range l.strings {
+			b, err := l.encoding.NewEncoder().String(s)
+			if err != nil || len(b) > 255 {
+				return nil, fmt.Errorf("license %s: cannot encode %q", lang, s)
+			}
+			buttons = append(append(buttons, byte(len(b))), b...)
+		}
+		add("STR#", id, name, buttons)
+
+		text, err := os.ReadFile(licenses[lang])
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(filepath.Ext(licenses[lang]), ".rtf") {
+			add("RTF ", id, name, text)
+		} else {
+			text = bytes.TrimPrefix(text, []byte("\xef\xbb\xbf"))
+			text = bytes.ReplaceAll(bytes.ReplaceAll(text, []byte("\r\n"), []byte("\r")), []byte("\n"), []byte("\r"))
+			if text, err = l.encoding.NewEncoder().Bytes(text); err != nil {
+				return nil, fmt.Errorf("license %s: %s cannot be encoded for the license dialog, use an RTF file instead: %w", lang, licenses[lang], err)
+			}
+			add("TEXT", id, name, text)
+			add("styl", id, name, licenseStyle)
+		}
+	}
+	lpic := binary.BigEndian.AppendUint16(nil, slaLanguages[strings.ToLower(defaultLang)].region)
+	lpic = binary.BigEndian.AppendUint16(lpic, uint16(len(langs)))
+	for i, lang := range langs {
+		l := slaLanguages[strings.ToLower(lang)]
+		multibyte := uint16(0)
+		if l.multibyte {
+			multibyte = 1
+		}
+		lpic = binary.BigEndian.AppendUint16(lpic, l.region)
+		lpic = binary.BigEndian.AppendUint16(lpic, uint16(i))
+		lpic = binary.BigEndian.AppendUint16(lpic, multibyte)
+	}
+	add("LPic", slaBaseID, "", lpic)
+	return resources, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := lo.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
+
+// licenseStyle is the styl resource of the TEXT licenses, a single run of
+// 12 point black text in the system font.
+var licenseStyle = []byte{
+	0, 1, // number of runs
+	0, 0, 0, 0, // start offset
+	0, 16, // line height
+	0, 12, // ascent
+	0, 0, // font family
+	0, 0, // face and padding
+	0, 12, // size
+	0, 0, 0, 0, 0, 0, // color
+}
@@ -0,0 +1,68 @@
+package dmg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLicenseResources(t *testing.T) {
+	dir := t.TempDir()
+	licenses := map[string]string{}
+	for lang := range slaLanguages {
+		path := filepath.Join(dir, lang+".txt")
+		if err := os.WriteFile(path, []byte("License\n"+slaLanguages[lang].strings[0]+"\r\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		licenses[lang] = path
+	}
+	rtf := filepath.Join(dir, "de.rtf")
+	if err := os.WriteFile(rtf, []byte(`{\rtf1 Lizenz}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	licenses["de"] = rtf
+
+	resources, err := licenseResources(licenses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(slaLanguages)
+	if len(resources["STR#"]) != n || len(resources["TEXT"]) != n-1 || len(resources["styl"]) != n-1 || len(resources["RTF "]) != 1 {
+		t.Fatalf("unexpected resource counts: %d STR#, %d TEXT, %d styl, %d RTF", len(resources["STR#"]), len(resources["TEXT"]), len(resources["styl"]), len(resources["RTF "]))
+	}
+	if got := resources["RTF "][0]; got.ID != "5001" || string(got.Data) != `{\rtf1 Lizenz}` {
+		t.Errorf("RTF resource = %+v", got)
+	}
+	// languages are sorted, da is the first one
+	if got := resources["TEXT"][0]; got.ID != "5000" || got.Name != "Dansk" || string(got.Data) != "License\rDansk\r" {
+		t.Errorf("TEXT resource = %+v", got)
+	}
+	if got := resources["STR#"][0].Data; got[0] != 0 || got[1] != 6 || string(got[3:3+got[2]]) != "Dansk" {
+		t.Errorf("STR# resource = %q", got)
+	}
+	// English is the default language, ja is the seventh language and needs two bytes
+	lpic := resources["LPic"][0].Data
+	if len(lpic) != 4+6*n || !bytes.Equal(lpic[:4], []byte{0, 0, 0, byte(n)}) {
+		t.Fatalf("LPic = %v", lpic)
+	}
+	if ja := lpic[4+6*7:][:6]; !bytes.Equal(ja, []byte{0, 14, 0, 7, 0, 1}) {
+		t.Errorf("LPic entry of ja = %v", ja)
+	}
+
+	for lang, want := range map[string]string{
+		"xx": "invalid language code",
+		"cy": "no license dialog for language cy",
+	} {
+		if _, err := licenseResources(map[string]string{lang: licenses["en"]}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want %q", lang, err, want)
+		}
+	}
+	if err := os.WriteFile(licenses["en"], []byte("日本語"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := licenseResources(map[string]string{"en": licenses["en"]}); err == nil || !strings.Contains(err.Error(), "use an RTF file") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package dmg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ironpark/zapp/pkg/mactools/alias"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+	"github.com/ironpark/zapp/pkg/mactools/hdiutil"
+	"github.com/ironpark/zapp/pkg/mactools/hfsplus"
+	"github.com/ironpark/zapp/pkg/mactools/udif"
+
+	"github.com/samber/lo"
+)
+
+// Check is the result of one of the checks of Verify.
+type Check struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// Verify checks the DMG file at name as it is distributed: the UDIF trailer
+// and its CRC32 checksums, that the image is read-only, and that the
+// .DS_Store of the volume positions every item and refers to a background
+// image inside the volume. With runHdiutil, hdiutil verify is run as well
+// where hdiutil is available. Only failing to read the file is an error.
+func Verify(name string, runHdiutil bool) ([]Check, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []Check
+	add := func(name string, err error, detail string) bool {
+		c := Check{Name: name, Passed: err == nil, Detail: detail}
+		if err != nil {
+			c.Detail = err.Error()
+		}
+		checks = append(checks, c)
+		return err == nil
+	}
+	skip := func(name, detail string) {
+		checks = append(checks, Check{Name: name, Passed: true, Skipped: true, Detail: detail})
+	}
+
+	udifImage, err := udif.Open(f, stat.Size())
+	if err != nil {
+		add("UDIF trailer", err, "")
+		return checks, nil
+	}
+	add("UDIF trailer", nil, fmt.Sprintf("%d partitions, %d sectors", len(udifImage.Partitions), udifImage.Trailer.SectorCount))
+	dataForkErr := udifImage.VerifyDataFork()
+	add("data fork checksum", dataForkErr, "CRC32 matches")
+	var partitionErr error
+	for i := range udifImage.Partitions {
+		if err := udifImage.VerifyPartition(&udifImage.Partitions[i]); err != nil && partitionErr == nil {
+			partitionErr = err
+		}
+	}
+	add("partition checksums", partitionErr, "CRC32 matches")
+	add("master checksum", udifImage.VerifyMaster(), "CRC32 matches")
+	switch {
+	case udifImage.Compressed():
+		add("read-only", nil, "compressed")
+	case errors.Is(dataForkErr, udif.ErrNoChecksum):
+		add("read-only", errors.New("image has no checksums and is uncompressed, it is still a read/write (UDRW) image"), "")
+	default:
+		add("read-only", nil, "uncompressed")
+	}
+
+	img, err := newImage(f, udifImage)
+	if err != nil {
+		add("HFS+ volume", err, "")
+		return checks, nil
+	}
+	v := img.Volume
+	add("HFS+ volume", nil, fmt.Sprintf("%q, %d files, %d folders", v.Name, v.Files, v.Folders))
+
+	store, err := readStore(v)
+	if add(".DS_Store", err, fmt.Sprintf("%d records", len(store.Entries))) {
+		add("icon positions", checkIconPositions(v, store), "every item is positioned")
+		e, _ := lo.Find(store.Entries, func(e entry.Entry) bool {
+			return e.Filename() == "." && e.EntryType() == entry.TypeIconViewPreferences
+		})
+		if ivp, ok := e.(*entry.IconViewPreferencesEntry); ok && ivp.BackgroundType == 2 {
+			path, err := checkBackgroundAlias(v, ivp.BackgroundImageAlias)
+			add("background", err, path)
+		} else {
+			skip("background", "no background image")
+		}
+	}
+
+	if runHdiutil {
+		if _, err := exec.LookPath("hdiutil"); err != nil {
+			skip("hdiutil verify", "hdiutil not found")
+		} else {
+			add("hdiutil verify", hdiutil.Verify(context.Background(), name), "")
+		}
+	}
+	return checks, nil
+}
+
+func readStore(v *hfsplus.Volume) (*dsstore.DSStore, error) {
+	data, err := v.ReadFile(".DS_Store")
+	if err != nil {
+		return dsstore.NewDSStore(), err
+	}
+	store, err := dsstore.Decode(bytes.NewReader(data))
+	if err != nil {
+		return dsstore.NewDSStore(), fmt.Errorf("failed to decode .DS_Store: %w", err)
+	}
+	return store, nil
+}
+
+// checkIconPositions checks that every visible item at the top of the
+// volume has an Iloc record, without which Finder places it freely.
+func checkIconPositions(v *hfsplus.Volume, store *dsstore.DSStore) error {
+	var missing []string
+	for _, f := range v.Root().Children {
+		if strings.HasPrefix(f.Name, ".") {
+			continue
+		}
+		found := false
+		for _, e := range store.Entries {
+			if e.EntryType() == entry.TypeIconLocation && hfsplus.FastUnicodeCompare(e.Filename(), f.Name) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, f.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no Iloc record for %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkBackgroundAlias checks that the alias of the background image refers
+// to a file of the volume, and returns its path.
+func checkBackgroundAlias(v *hfsplus.Volume, data []byte) (string, error) {
+	if data == nil {
+		return "", errors.New("background image has no alias")
+	}
+	info, err := alias.Decode(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid background alias: %w", err)
+	}
+	if info.Volume.Name != v.Name {
+		return "", fmt.Errorf("background alias points to volume %q, not %q", info.Volume.Name, v.Name)
+	}
+	path := info.Target.Path
+	if path == "" {
+		return "", errors.New("background alias has no path")
+	}
+	f, err := v.Lookup(path)
+	if err != nil {
+		return "", fmt.Errorf("background alias points to %s, which is not in the volume", path)
+	}
+	if f.ID != info.Target.ID {
+		return "", fmt.Errorf("background alias points to %s with ID %d, the file has ID %d", path, info.Target.ID, f.ID)
+	}
+	return path, nil
+}
@@ -0,0 +1,103 @@
+package dmg
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+	"github.com/ironpark/zapp/pkg/mactools/hdiutil"
+	"github.com/ironpark/zapp/pkg/mactools/rsrc"
+
+	"github.com/samber/lo"
+)
+
+func TestVerify(t *testing.T) {
+	if _, err := exec.LookPath("hdiutil"); err == nil {
+		t.Skip("CreateDMG uses hdiutil")
+	}
+	dir := t.TempDir()
+	app := filepath.Join(dir, "App.app")
+	if err := os.MkdirAll(filepath.Join(app, "Contents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(app, "Contents", "Info.plist"), []byte("<plist/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	icon := filepath.Join(dir, "icon.icns")
+	if err := os.WriteFile(icon, []byte("icns\x00\x00\x00\x08"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "App.dmg")
+	err := CreateDMG(Config{
+		FileName:     name,
+		Title:        "App",
+		Icon:         icon,
+		WindowWidth:  640,
+		WindowHeight: 480,
+		Format:       hdiutil.UDZO,
+		Artwork:      &Artwork{},
+		Contents: []Item{
+			{Type: Dir, Path: app},
+			{Type: Link, Path: "/Applications"},
+		},
+	}, filepath.Join(dir, "source"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "darwin" {
+		if _, err := os.Stat(rsrc.AppleDoublePath(name)); err != nil {
+			t.Errorf("dmg file has no custom icon: %v", err)
+		}
+	}
+
+	img, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := readStore(img.Volume)
+	img.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbbk, _ := lo.Find(store.Entries, func(e entry.Entry) bool { return e.EntryType() == entry.TypePictureBookmark })
+	if pbbk == nil || !bytes.HasPrefix(pbbk.(*entry.PictureBookmarkEntry).Bookmark, []byte("book")) {
+		t.Error("background image has no bookmark data")
+	}
+
+	checks, err := Verify(name, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passed := map[string]bool{}
+	for _, c := range checks {
+		if !c.Passed || (c.Skipped && c.Name != "hdiutil verify") {
+			t.Errorf("%s: %s", c.Name, c.Detail)
+		}
+		passed[c.Name] = c.Passed
+	}
+	for _, name := range []string{"data fork checksum", "partition checksums", "master checksum", "read-only", "icon positions", "background"} {
+		if !passed[name] {
+			t.Errorf("check %q missing", name)
+		}
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[100] ^= 0xff
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	checks, err = Verify(name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checks[1].Name != "data fork checksum" || checks[1].Passed {
+		t.Errorf("corrupted image: %+v", checks[1])
+	}
+}
@@ -0,0 +1,103 @@
+package dmg
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ironpark/zapp/pkg/mactools/dsstore"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+	"github.com/samber/lo"
+)
+
+type ViewStyle string
+
+const (
+	IconView   ViewStyle = "icon"
+	ListView   ViewStyle = "list"
+	ColumnView ViewStyle = "column"
+)
+
+var viewStyles = map[ViewStyle]string{
+	IconView:   entry.ViewStyleIcon,
+	ListView:   entry.ViewStyleList,
+	ColumnView: entry.ViewStyleColumn,
+}
+
+// setViewStyles writes the view style of the DMG window and of every folder
+// item that asks for one.
+func setViewStyles(store *dsstore.DSStore, config Config) error {
+	if err := setViewStyle(store, ".", config.ViewStyle, config); err != nil {
+		return err
+	}
+	for _, item := range config.Contents {
+		if item.ViewStyle == "" {
+			continue
+		}
+		if item.Type != Dir {
+			return fmt.Errorf("view style can only be set for directories: %s", item.Path)
+		}
+		if err := setViewStyle(store, filepath.Base(item.Path), item.ViewStyle, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setViewStyle(store *dsstore.DSStore, name string, style ViewStyle, config Config) error {
+	if style == "" {
+		style = IconView
+	}
+	code, ok := viewStyles[style]
+	if !ok {
+		return fmt.Errorf("unsupported view style: %s", style)
+	}
+	if style == IconView && name == "." {
+		// Finder opens the window in icon view by default
+		return nil
+	}
+	store.SetViewStyle(name, code)
+	if style == ListView {
+		columns, err := listViewColumns(config.ListColumns)
+		if err != nil {
+			return err
+		}
+		sortBy := config.SortBy
+		if sortBy == "" {
+			sortBy = "name"
+		}
+		if !lo.ContainsBy(columns, func(c entry.ListViewColumn) bool { return c.Identifier == sortBy }) {
+			return fmt.Errorf("unknown sort column: %s", sortBy)
+		}
+		store.SetListViewColumns(name, sortBy, columns)
+		if config.LabelSize != 0 {
+			store.SetListViewIconSize(name, 16, float64(config.LabelSize))
+		}
+	}
+	return nil
+}
+
+// listViewColumns returns the Finder columns with the named ones visible, in
+// the given order. The name column is always shown first.
+func listViewColumns(names []string) ([]entry.ListViewColumn, error) {
+	defaults := entry.DefaultListViewColumns()
+	if len(names) == 0 {
+		return defaults, nil
+	}
+	names = append([]string{"name"}, lo.Without(names, "name")...)
+	columns := make([]entry.ListViewColumn, 0, len(defaults))
+	for _, name := range lo.Uniq(names) {
+		c, ok := lo.Find(defaults, func(c entry.ListViewColumn) bool { return c.Identifier == name })
+		if !ok {
+			return nil, fmt.Errorf("unknown list view column: %s", name)
+		}
+		c.Visible = true
+		columns = append(columns, c)
+	}
+	for _, c := range defaults {
+		if !lo.Contains(names, c.Identifier) {
+			c.Visible = false
+			columns = append(columns, c)
+		}
+	}
+	return columns, nil
+}
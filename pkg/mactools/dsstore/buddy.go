@@ -0,0 +1,252 @@
+package dsstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// allocator is the buddy allocator that manages the blocks of a .DS_Store file.
+// Every block address packs the offset (relative to the 4 byte file prefix)
+// together with log2 of the block size in its lowest 5 bits.
+type allocator struct {
+	data    []byte
+	offsets []uint32
+	toc     map[string]uint32
+	free    [32][]uint32
+}
+
+// readAllocator parses the buddy allocator header and its root block.
+func readAllocator(file []byte) (*allocator, error) {
+	if len(file) < 36 || binary.BigEndian.Uint32(file) != 1 || string(file[4:8]) != "Bud1" {
+		return nil, errors.New("not a .DS_Store file")
+	}
+	a := &allocator{data: file[4:], toc: map[string]uint32{}}
+	header := &blockReader{buf: a.data[4:]}
+	rootOffset := header.uint32()
+	rootSize := header.uint32()
+	if header.uint32() != rootOffset {
+		return nil, errors.New("corrupted allocator header")
+	}
+	root, err := a.slice(rootOffset, rootSize)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &blockReader{buf: root}
+	count := r.uint32()
+	r.skip(4)
+	if count > uint32(len(root))/4 {
+		return nil, fmt.Errorf("invalid block count: %d", count)
+	}
+	a.offsets = make([]uint32, count)
+	for i := range a.offsets {
+		a.offsets[i] = r.uint32()
+	}
+	// the address table is padded to a multiple of 256 entries
+	r.skip(int(roundUp(count, 256)-count) * 4)
+
+	dirCount := r.uint32()
+	for i := uint32(0); i < dirCount && r.err == nil; i++ {
+		name := string(r.bytes(int(r.uint8())))
+		a.toc[name] = r.uint32()
+	}
+	for i := range a.free {
+		n := r.uint32()
+		for j := uint32(0); j < n && r.err == nil; j++ {
+			a.free[i] = append(a.free[i], r.uint32())
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to read allocator root block: %w", r.err)
+	}
+	return a, nil
+}
+
+// newAllocator creates an empty allocator whose first 32 bytes are reserved
+// for the file header.
+func newAllocator() *allocator {
+	a := &allocator{toc: map[string]uint32{}}
+	a.free[31] = []uint32{0}
+	// cannot fail, the whole address space is free
+	_, _ = a.alloc(32)
+	return a
+}
+
+// alloc reserves a block of at least size bytes and returns its offset.
+func (a *allocator) alloc(size uint32) (uint32, error) {
+	width := uint32(5)
+	for 1<<width < size {
+		width++
+	}
+	k := width
+	for k < 32 && len(a.free[k]) == 0 {
+		k++
+	}
+	if k == 32 {
+		return 0, fmt.Errorf("no free block of %d bytes left", size)
+	}
+	offset := a.free[k][0]
+	a.free[k] = a.free[k][1:]
+	// split the block, returning the upper halves to the free lists
+	for k > width {
+		k--
+		a.free[k] = insertSorted(a.free[k], offset+1<<k)
+	}
+	return offset | width, nil
+}
+
+// allocBlock reserves a block of at least size bytes and returns its number.
+func (a *allocator) allocBlock(size uint32) (uint32, error) {
+	offset, err := a.alloc(size)
+	if err != nil {
+		return 0, err
+	}
+	a.offsets = append(a.offsets, offset)
+	return uint32(len(a.offsets) - 1), nil
+}
+
+// rootSize returns the size of the root block once n blocks are allocated.
+// The free lists can only grow by one entry per level for every allocation,
+// so the estimate is an upper bound.
+func rootSize(n uint32) uint32 {
+	size := 8 + roundUp(n, 256)*4
+	size += 4 + (1 + 4 + 4)
+	size += 32*4 + (32+n)*4
+	block := uint32(2048)
+	for block < size {
+		block <<= 1
+	}
+	return block
+}
+
+// writeRoot encodes the block table, the directory and the free lists.
+func (a *allocator) writeRoot(buf []byte) {
+	w := &blockWriter{buf: buf}
+	w.uint32(uint32(len(a.offsets)))
+	w.uint32(0)
+	for _, offset := range a.offsets {
+		w.uint32(offset)
+	}
+	w.pos += int(roundUp(uint32(len(a.offsets)), 256)-uint32(len(a.offsets))) * 4
+
+	names := make([]string, 0, len(a.toc))
+	for name := range a.toc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.uint32(uint32(len(names)))
+	for _, name := range names {
+		w.buf[w.pos] = byte(len(name))
+		w.pos++
+		w.bytes([]byte(name))
+		w.uint32(a.toc[name])
+	}
+	for _, list := range a.free {
+		w.uint32(uint32(len(list)))
+		for _, offset := range list {
+			w.uint32(offset)
+		}
+	}
+}
+
+// end returns the offset just past the last allocated block.
+func (a *allocator) end() uint32 {
+	end := uint32(32)
+	for _, addr := range a.offsets {
+		if e := addr&^0x1f + 1<<(addr&0x1f); e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+func insertSorted(list []uint32, v uint32) []uint32 {
+	i := sort.Search(len(list), func(i int) bool { return list[i] >= v })
+	list = append(list, 0)
+	copy(list[i+1:], list[i:])
+	list[i] = v
+	return list
+}
+
+// block returns the contents of the block with the given number.
+func (a *allocator) block(id uint32) ([]byte, error) {
+	if int(id) >= len(a.offsets) {
+		return nil, fmt.Errorf("block %d does not exist", id)
+	}
+	addr := a.offsets[id]
+	return a.slice(addr&^0x1f, 1<<(addr&0x1f))
+}
+
+func (a *allocator) slice(offset, size uint32) ([]byte, error) {
+	end := uint64(offset) + uint64(size)
+	if end > uint64(len(a.data)) {
+		return nil, fmt.Errorf("block at 0x%x (size %d) is out of range", offset, size)
+	}
+	return a.data[offset:end], nil
+}
+
+// blockReader reads big-endian values from a block and remembers the first error.
+type blockReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *blockReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = errors.New("unexpected end of block")
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *blockReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *blockReader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *blockReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *blockReader) peekUint32() uint32 {
+	if r.err != nil || r.pos+4 > len(r.buf) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(r.buf[r.pos:])
+}
+
+// blockWriter writes big-endian values into a preallocated block.
+type blockWriter struct {
+	buf []byte
+	pos int
+}
+
+func (w *blockWriter) bytes(b []byte) {
+	w.pos += copy(w.buf[w.pos:], b)
+}
+
+func (w *blockWriter) uint32(v uint32) {
+	binary.BigEndian.PutUint32(w.buf[w.pos:], v)
+	w.pos += 4
+}
+
+func roundUp(n, multiple uint32) uint32 {
+	return (n + multiple - 1) / multiple * multiple
+}
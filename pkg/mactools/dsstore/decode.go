@@ -0,0 +1,122 @@
+package dsstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Decode reads a .DS_Store file and returns its records as entries.
+func Decode(r io.Reader) (*DSStore, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	a, err := readAllocator(data)
+	if err != nil {
+		return nil, err
+	}
+	dsdb, ok := a.toc["DSDB"]
+	if !ok {
+		return nil, errors.New("DSDB directory not found")
+	}
+	header, err := a.block(dsdb)
+	if err != nil {
+		return nil, err
+	}
+	hr := &blockReader{buf: header}
+	rootNode := hr.uint32()
+	levels := hr.uint32()
+	if hr.err != nil {
+		return nil, fmt.Errorf("failed to read B-tree header: %w", hr.err)
+	}
+
+	ds := NewDSStore()
+	err = a.walk(rootNode, int(levels), func(e entry.Entry) {
+		ds.AddEntry(e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// walk visits the records of a B-tree node and its children in key order.
+func (a *allocator) walk(id uint32, depth int, visit func(entry.Entry)) error {
+	if depth < 0 {
+		return errors.New("B-tree is deeper than its header claims")
+	}
+	node, err := a.block(id)
+	if err != nil {
+		return err
+	}
+	r := &blockReader{buf: node}
+	next := r.uint32()
+	count := r.uint32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		if next != 0 {
+			if err := a.walk(r.uint32(), depth-1, visit); err != nil {
+				return err
+			}
+		}
+		e, err := readRecord(r)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", id, err)
+		}
+		visit(e)
+	}
+	if r.err != nil {
+		return fmt.Errorf("block %d: %w", id, r.err)
+	}
+	if next != 0 {
+		return a.walk(next, depth-1, visit)
+	}
+	return nil
+}
+
+func readRecord(r *blockReader) (entry.Entry, error) {
+	nameLength := r.uint32()
+	name := r.bytes(int(nameLength) * 2)
+	entryType := string(r.bytes(4))
+	dataType := string(r.bytes(4))
+
+	var size int
+	switch dataType {
+	case "bool":
+		size = 1
+	case "long", "shor", "type":
+		size = 4
+	case "comp", "dutc":
+		size = 8
+	case "blob":
+		size = 4 + int(r.peekUint32())
+	case "ustr":
+		size = 4 + int(r.peekUint32())*2
+	default:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, fmt.Errorf("unknown data type %q", dataType)
+	}
+	data := r.bytes(size)
+	if r.err != nil {
+		return nil, r.err
+	}
+	filename := norm.NFC.String(utf16beDecode(name))
+	return entry.Decode(filename, entryType, dataType, data)
+}
+
+// utf16beDecode converts a big-endian UTF-16 byte slice to a string.
+func utf16beDecode(b []byte) string {
+	u16 := make([]uint16, len(b)/2)
+	for i := range u16 {
+		u16[i] = binary.BigEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u16))
+}
@@ -1,25 +1,21 @@
-// Package dsstore is a package for writing .DS_Store files on macOS.
+// Package dsstore is a package for reading and writing .DS_Store files on macOS.
 // Original code from https://github.com/LinusU/node-ds-store (MIT)
 package dsstore
 
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"os"
-	"sort"
 	"unicode/utf16"
 
+	"github.com/ironpark/zapp/pkg/mactools/alias"
 	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+	"github.com/ironpark/zapp/pkg/mactools/hfsplus"
 
 	"github.com/samber/lo"
-	"golang.org/x/text/unicode/norm"
-
-	_ "embed"
 )
 
-//go:embed clean
-var DSStoreClean []byte
-
 type Entries []entry.Entry
 
 func (e Entries) Len() int {
@@ -31,17 +27,24 @@ func (e Entries) Swap(i, j int) {
 }
 
 func (e Entries) Less(i, j int) bool {
-	if result := hfsPlusFastUnicodeCompare(e[i].Filename(), e[j].Filename()); result != 0 {
+	if result := hfsplus.FastUnicodeCompare(e[i].Filename(), e[j].Filename()); result != 0 {
 		return result < 0
 	}
-	if result := hfsPlusFastUnicodeCompare(e[i].EntryType(), e[j].EntryType()); result != 0 {
+	if result := hfsplus.FastUnicodeCompare(e[i].EntryType(), e[j].EntryType()); result != 0 {
 		return result < 0
 	}
-	return false
+	// lsvp and lsvP only differ in case
+	return e[i].EntryType() < e[j].EntryType()
 }
 
 type DSStore struct {
 	Entries []entry.Entry
+	// Legacy makes Encode also write the fwi0, icvo, BKGD, pict and vSrn
+	// records that Finder versions predating bwsp and icvp read.
+	Legacy bool
+	// Volume provides the volume details stored in aliases and bookmarks.
+	// alias.DefaultVolumeInfo is used when it is nil.
+	Volume alias.VolumeInfo
 }
 
 func NewDSStore() *DSStore {
@@ -75,30 +78,90 @@ func (ds *DSStore) SetIconSize(size float64) {
 
 func (ds *DSStore) SetBgColor(r, g, b float64) {
 	ds.getIconViewPreferences().SetBgColor(r, g, b)
+	ds.remove(".", entry.TypePictureBookmark)
+}
+
+// SetBackgroundImage refers to the image with an alias in icvp and bookmark
+// data in both icvp and the pBBk record.
+func (ds *DSStore) SetBackgroundImage(path string) error {
+	ivp := ds.getIconViewPreferences()
+	volume := ds.Volume
+	if volume == nil {
+		volume = alias.DefaultVolumeInfo
+	}
+	if err := ivp.SetBgImage(path, volume); err != nil {
+		return fmt.Errorf("failed to refer to background image: %w", err)
+	}
+	ds.remove(".", entry.TypePictureBookmark)
+	ds.AddEntry(entry.NewPictureBookmarkEntry(".", ivp.BackgroundImageBookmark))
+	return nil
 }
 
-func (ds *DSStore) SetBackgroundImage(path string) {
+// SetBackgroundImageData refers to the image with an alias and bookmark data
+// created beforehand, e.g. by alias.CreateVirtual and bookmark.CreateVirtual.
+func (ds *DSStore) SetBackgroundImageData(aliasData, bookmarkData []byte) {
 	ivp := ds.getIconViewPreferences()
-	ivp.SetBgImage(path)
+	ivp.SetBgImageData(aliasData, bookmarkData)
+	ds.remove(".", entry.TypePictureBookmark)
+	ds.AddEntry(entry.NewPictureBookmarkEntry(".", bookmarkData))
+}
+
+// SetBackgroundImageAlias refers to the image with an alias created
+// beforehand, e.g. by alias.CreateVirtual. Finder falls back to the alias
+// when there is no bookmark data.
+func (ds *DSStore) SetBackgroundImageAlias(aliasData []byte) {
+	ds.getIconViewPreferences().SetBgImageAlias(aliasData)
+	ds.remove(".", entry.TypePictureBookmark)
 }
 
 func (ds *DSStore) SetBgToDefault() {
 	ds.getIconViewPreferences().SetBgToDefault()
+	ds.remove(".", entry.TypePictureBookmark)
 }
 
-func (ds *DSStore) SetWindow(width, height, x, y int) {
-	e, ok := lo.Find(ds.Entries, func(e entry.Entry) bool {
-		return e.EntryType() == entry.TypeWorkspaceSettings
-	})
+func (ds *DSStore) getWorkspaceSettings() *entry.WorkspaceSettingsEntry {
+	e, ok := ds.find(".", entry.TypeWorkspaceSettings)
 	if ok {
-		e.(*entry.WorkspaceSettingsEntry).Width = width
-		e.(*entry.WorkspaceSettingsEntry).Height = height
-		e.(*entry.WorkspaceSettingsEntry).X = x
-		e.(*entry.WorkspaceSettingsEntry).Y = y
-	} else {
-		newEntry := entry.NewWorkspaceSettingsEntry(x, y, width, height)
-		ds.AddEntry(newEntry)
+		return e.(*entry.WorkspaceSettingsEntry)
 	}
+	newEntry := entry.NewWorkspaceSettingsEntry(0, 0, 0, 0)
+	ds.AddEntry(newEntry)
+	return newEntry
+}
+
+// SetWindow sets the size and the screen position of the window. Finder
+// measures the position from the top left corner of the screen, in both the
+// bwsp and the legacy fwi0 records.
+func (ds *DSStore) SetWindow(width, height, x, y int) {
+	w := ds.getWorkspaceSettings()
+	w.Width = width
+	w.Height = height
+	w.X = x
+	w.Y = y
+}
+
+func (ds *DSStore) SetToolbarVisible(visible bool) {
+	ds.getWorkspaceSettings().ShowToolbar = visible
+}
+
+// SetSidebar shows or hides the sidebar. A width of 0 keeps the Finder default.
+func (ds *DSStore) SetSidebar(visible bool, width int) {
+	w := ds.getWorkspaceSettings()
+	w.ShowSidebar = visible
+	w.ContainerShowSidebar = visible
+	w.SidebarWidth = width
+}
+
+func (ds *DSStore) SetPathbarVisible(visible bool) {
+	ds.getWorkspaceSettings().ShowPathbar = visible
+}
+
+func (ds *DSStore) SetStatusBarVisible(visible bool) {
+	ds.getWorkspaceSettings().ShowStatusBar = visible
+}
+
+func (ds *DSStore) SetTabViewVisible(visible bool) {
+	ds.getWorkspaceSettings().ShowTabView = visible
 }
 
 func (ds *DSStore) SetIconPos(name string, x, y uint32) {
@@ -114,46 +177,102 @@ func (ds *DSStore) SetIconPos(name string, x, y uint32) {
 	}
 }
 
+// SetViewStyle sets the view style (entry.ViewStyleIcon, entry.ViewStyleList, ...)
+// of the window opened for name. The name "." refers to the folder that
+// contains the .DS_Store, other names to its subfolders.
+func (ds *DSStore) SetViewStyle(name, style string) {
+	if e, ok := ds.find(name, entry.TypeViewStyle); ok {
+		e.(*entry.ViewStyleEntry).Style = style
+	} else {
+		ds.AddEntry(entry.NewViewStyleEntry(name, style))
+	}
+}
+
+// getListViewPreferences returns the lsvp and lsvP entries of name, creating them if needed.
+func (ds *DSStore) getListViewPreferences(name string) []*entry.ListViewPreferencesEntry {
+	var entries []*entry.ListViewPreferencesEntry
+	for _, create := range []func(string) *entry.ListViewPreferencesEntry{
+		entry.NewListViewPreferencesEntry,
+		entry.NewListViewPropertiesEntry,
+	} {
+		l := create(name)
+		if e, ok := ds.find(name, l.EntryType()); ok {
+			l = e.(*entry.ListViewPreferencesEntry)
+		} else {
+			ds.AddEntry(l)
+		}
+		entries = append(entries, l)
+	}
+	return entries
+}
+
+// SetListViewColumns sets the columns, in display order, and the sort column of
+// the list view of name. Columns that are not listed stay hidden.
+func (ds *DSStore) SetListViewColumns(name, sortColumn string, columns []entry.ListViewColumn) {
+	for _, l := range ds.getListViewPreferences(name) {
+		l.SortColumn = sortColumn
+		l.Columns = append([]entry.ListViewColumn(nil), columns...)
+	}
+}
+
+func (ds *DSStore) SetListViewIconSize(name string, iconSize, textSize float64) {
+	for _, l := range ds.getListViewPreferences(name) {
+		l.IconSize = iconSize
+		l.TextSize = textSize
+	}
+}
+
+// SetIconViewOptions sets the legacy icon view record read by old Finder versions.
+func (ds *DSStore) SetIconViewOptions(name string, iconSize uint16, arrangeBy string, labelOnBottom bool) {
+	e, ok := ds.find(name, entry.TypeIconViewOptions)
+	if !ok {
+		e = entry.NewIconViewOptionsEntry(name, iconSize)
+		ds.AddEntry(e)
+	}
+	icvo := e.(*entry.IconViewOptionsEntry)
+	icvo.IconSize = iconSize
+	icvo.ArrangeBy = arrangeBy
+	icvo.LabelOnBottom = labelOnBottom
+}
+
+func (ds *DSStore) find(name, entryType string) (entry.Entry, bool) {
+	return lo.Find(ds.Entries, func(e entry.Entry) bool {
+		return e.Filename() == name && e.EntryType() == entryType
+	})
+}
+
+func (ds *DSStore) remove(name, entryType string) {
+	ds.Entries = lo.Reject(ds.Entries, func(e entry.Entry, _ int) bool {
+		return e.Filename() == name && e.EntryType() == entryType
+	})
+}
+
 func (ds *DSStore) AddEntry(entry entry.Entry) {
 	ds.Entries = append(ds.Entries, entry)
 }
 
+// Merge copies the entries of other into ds, replacing entries with the same
+// filename and entry type.
+func (ds *DSStore) Merge(other *DSStore) {
+	for _, e := range other.Entries {
+		_, i, ok := lo.FindIndexOf(ds.Entries, func(item entry.Entry) bool {
+			return item.Filename() == e.Filename() && item.EntryType() == e.EntryType()
+		})
+		if ok {
+			ds.Entries[i] = e
+		} else {
+			ds.AddEntry(e)
+		}
+	}
+}
+
+// Write writes the entries to a .DS_Store file at filePath.
 func (ds *DSStore) Write(filePath string) error {
-	sort.Sort(Entries(ds.Entries))
-
-	buf := bytes.Clone(DSStoreClean)
-	modified := make([]byte, 3840)
-	currentPos := 0
-	P := uint32(0)
-	count := uint32(len(ds.Entries))
-
-	binary.BigEndian.PutUint32(modified[currentPos:], P)
-	binary.BigEndian.PutUint32(modified[currentPos+4:], count)
-	currentPos += 8
-
-	for _, entry := range ds.Entries {
-		blob := entryBuild(entry)
-		copy(modified[currentPos:], blob)
-		currentPos += len(blob)
-	}
-
-	binary.BigEndian.PutUint32(buf[76:], count)
-	copy(buf[4100:], modified)
-	return os.WriteFile(filePath, buf, 0644)
-}
-
-func entryBuild(entry entry.Entry) []byte {
-	filename := norm.NFD.String(entry.Filename())
-	filenameLength := len(filename)
-	filenameBytes := filenameLength * 2
-	blob := entry.Bytes()
-	buffer := make([]byte, 4+filenameBytes+4+4+len(blob))
-	binary.BigEndian.PutUint32(buffer[0:], uint32(filenameLength))
-	copy(buffer[4:], utf16be(filename))
-	copy(buffer[4+filenameBytes:], entry.EntryType())
-	copy(buffer[8+filenameBytes:], entry.DataType())
-	copy(buffer[12+filenameBytes:], blob)
-	return buffer
+	buf := &bytes.Buffer{}
+	if err := ds.Encode(buf); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, buf.Bytes(), 0644)
 }
 
 // utf16be converts a string to a big-endian UTF-16 byte slice.
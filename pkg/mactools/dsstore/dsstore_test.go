@@ -0,0 +1,369 @@
+package dsstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ironpark/zapp/pkg/mactools/alias"
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+)
+
+func TestDecodeRoundTrip(t *testing.T) {
+	store := NewDSStore()
+	store.SetIconSize(128)
+	store.SetWindow(640, 480, 200, 150)
+	store.SetSidebar(false, 0)
+	store.SetPathbarVisible(true)
+	store.SetStatusBarVisible(true)
+	store.SetLabelSize(14)
+	store.SetLabelPlaceToBottom(true)
+	store.SetBgColor(0.5, 0.25, 1)
+	store.SetIconPos("Applications", 420, 240)
+	store.SetIconPos("MyApp.app", 180, 240)
+	store.AddEntry(entry.NewEntry("MyApp.app", "cmmt", "ustr", []byte{0, 0, 0, 1, 0, 'x'}))
+
+	path := filepath.Join(t.TempDir(), ".DS_Store")
+	if err := store.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Entries) != len(store.Entries) {
+		t.Fatalf("expected %d entries, got %d", len(store.Entries), len(decoded.Entries))
+	}
+	for i, e := range decoded.Entries {
+		want := store.Entries[i]
+		if e.Filename() != want.Filename() || e.EntryType() != want.EntryType() || e.DataType() != want.DataType() {
+			t.Errorf("entry %d: got %s/%s/%s, want %s/%s/%s", i,
+				e.Filename(), e.EntryType(), e.DataType(), want.Filename(), want.EntryType(), want.DataType())
+		}
+	}
+
+	iloc, ok := find(decoded, "Applications", entry.TypeIconLocation).(*entry.IconLocationEntry)
+	if !ok || iloc.X != 420 || iloc.Y != 240 {
+		t.Errorf("unexpected icon location: %#v", iloc)
+	}
+	ivp := decoded.getIconViewPreferences()
+	if ivp.IconSize != 128 || ivp.TextSize != 14 || !ivp.LabelOnBottom || ivp.BackgroundType != 1 || ivp.BackgroundColorGreen != 0.25 {
+		t.Errorf("unexpected icon view preferences: %#v", ivp)
+	}
+	bwsp := decoded.getWorkspaceSettings()
+	if bwsp.X != 200 || bwsp.Y != 150 || bwsp.Width != 640 || bwsp.ShowSidebar || bwsp.ContainerShowSidebar ||
+		!bwsp.ShowPathbar || !bwsp.ShowStatusBar || bwsp.ShowToolbar {
+		t.Errorf("unexpected workspace settings: %#v", bwsp)
+	}
+	raw, ok := find(decoded, "MyApp.app", "cmmt").(*entry.EntryItem)
+	if !ok || !bytes.Equal(raw.Bytes(), []byte{0, 0, 0, 1, 0, 'x'}) {
+		t.Errorf("unexpected raw entry: %#v", raw)
+	}
+}
+
+func find(ds *DSStore, filename, entryType string) entry.Entry {
+	for _, e := range ds.Entries {
+		if e.Filename() == filename && e.EntryType() == entryType {
+			return e
+		}
+	}
+	return nil
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("not a ds store"))); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestEncodeManyEntries(t *testing.T) {
+	store := NewDSStore()
+	store.SetWindow(640, 480, 0, 0)
+	for i := 0; i < 3000; i++ {
+		store.SetIconPos(fmt.Sprintf("file-%04d.txt", i), uint32(i), uint32(i*2))
+	}
+	buf := &bytes.Buffer{}
+	if err := store.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	a, err := readAllocator(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := a.block(a.toc["DSDB"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels := binary.BigEndian.Uint32(header[4:]); levels == 0 {
+		t.Fatal("expected internal B-tree nodes")
+	}
+	if records := binary.BigEndian.Uint32(header[8:]); records != 3001 {
+		t.Fatalf("expected 3001 records, got %d", records)
+	}
+
+	decoded, err := Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Entries) != 3001 {
+		t.Fatalf("expected 3001 entries, got %d", len(decoded.Entries))
+	}
+	for i, e := range decoded.Entries[1:] {
+		iloc, ok := e.(*entry.IconLocationEntry)
+		if !ok || iloc.Filename() != fmt.Sprintf("file-%04d.txt", i) || iloc.X != uint32(i) {
+			t.Fatalf("entry %d out of order: %#v", i, e)
+		}
+	}
+}
+
+func TestRecordsRoundTrip(t *testing.T) {
+	store := NewDSStore()
+	store.SetIconSize(96)
+	store.SetWindow(800, 600, 10, 20)
+	store.SetIconPos("MyApp.app", 180, 240)
+	store.AddEntry(entry.NewEntry(".", "vSrn", "long", []byte{0, 0, 0, 1}))
+
+	data, err := json.Marshal(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range decoded.Entries {
+		if !bytes.Equal(e.Bytes(), store.Entries[i].Bytes()) {
+			t.Errorf("entry %s %q changed after round trip", e.EntryType(), e.Filename())
+		}
+	}
+
+	yamlData := []byte(`
+- filename: MyApp.app
+  entryType: Iloc
+  dataType: blob
+  value: {x: 100, y: 200}
+- filename: "."
+  entryType: vSrn
+  dataType: long
+  value: 1
+`)
+	decoded, err = Unmarshal(yamlData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iloc, ok := find(decoded, "MyApp.app", entry.TypeIconLocation).(*entry.IconLocationEntry)
+	if !ok || iloc.X != 100 || iloc.Y != 200 {
+		t.Errorf("unexpected icon location: %#v", iloc)
+	}
+	if v := find(decoded, ".", "vSrn"); v == nil || !bytes.Equal(v.Bytes(), []byte{0, 0, 0, 1}) {
+		t.Errorf("unexpected version entry: %#v", v)
+	}
+}
+
+func TestRecordsKeepPlistTypes(t *testing.T) {
+	store := NewDSStore()
+	ivp := entry.NewIconViewPreferencesEntry(64)
+	ivp.Extra = entry.PlistDict{
+		"scrollPosition": 1.0,
+		"fraction":       0.5,
+		"count":          3,
+		"token":          []byte{1, 2, 3},
+		"modified":       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		"nested":         map[string]any{"real": "not a tag", "list": []any{2.0, []byte{4}}},
+	}
+	store.AddEntry(ivp)
+	buf := &bytes.Buffer{}
+	if err := store.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := Unmarshal(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := json.Marshal(applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dump, again) {
+		t.Errorf("records changed after dump and apply:\n%s\n%s", dump, again)
+	}
+	for i, e := range applied.Entries {
+		if !bytes.Equal(e.Bytes(), decoded.Entries[i].Bytes()) {
+			t.Errorf("entry %s %q changed after dump and apply", e.EntryType(), e.Filename())
+		}
+	}
+	extra := find(applied, ".", entry.TypeIconViewPreferences).(*entry.IconViewPreferencesEntry).Extra
+	if v, ok := extra["scrollPosition"].(float64); !ok || v != 1 {
+		t.Errorf("scrollPosition = %#v, want the real 1.0", extra["scrollPosition"])
+	}
+	if v, ok := extra["token"].([]byte); !ok || !bytes.Equal(v, []byte{1, 2, 3}) {
+		t.Errorf("token = %#v, want data", extra["token"])
+	}
+	if v, ok := extra["nested"].(map[string]any); !ok || v["real"] != "not a tag" {
+		t.Errorf("nested = %#v", extra["nested"])
+	}
+}
+
+func TestViewEntriesRoundTrip(t *testing.T) {
+	store := NewDSStore()
+	store.SetViewStyle("Docs", entry.ViewStyleList)
+	columns := []entry.ListViewColumn{
+		{Identifier: "name", Width: 250, Visible: true, Ascending: true},
+		{Identifier: "size", Width: 90, Visible: true},
+		{Identifier: "kind", Width: 100},
+	}
+	store.SetListViewColumns("Docs", "size", columns)
+	store.SetIconViewOptions(".", 128, "grid", true)
+
+	buf := &bytes.Buffer{}
+	if err := store.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := find(decoded, "Docs", entry.TypeViewStyle).(*entry.ViewStyleEntry); !ok || v.Style != entry.ViewStyleList {
+		t.Errorf("unexpected view style: %#v", v)
+	}
+	for _, entryType := range []string{entry.TypeListViewPreferences, entry.TypeListViewProperties} {
+		l, ok := find(decoded, "Docs", entryType).(*entry.ListViewPreferencesEntry)
+		if !ok {
+			t.Fatalf("%s entry not found", entryType)
+		}
+		if l.SortColumn != "size" || len(l.Columns) != len(columns) {
+			t.Fatalf("unexpected %s entry: %#v", entryType, l)
+		}
+		for i, c := range l.Columns {
+			if c != columns[i] {
+				t.Errorf("%s column %d: got %#v, want %#v", entryType, i, c, columns[i])
+			}
+		}
+	}
+	icvo, ok := find(decoded, ".", entry.TypeIconViewOptions).(*entry.IconViewOptionsEntry)
+	if !ok || icvo.IconSize != 128 || icvo.ArrangeBy != "grid" || !icvo.LabelOnBottom {
+		t.Errorf("unexpected icon view options: %#v", icvo)
+	}
+}
+
+func TestLegacyEntries(t *testing.T) {
+	store := NewDSStore()
+	store.Legacy = true
+	store.SetWindow(640, 480, 100, 50)
+	store.SetIconSize(128)
+	store.SetLabelPlaceToBottom(true)
+	ivp := store.getIconViewPreferences()
+	ivp.BackgroundType = 2
+	ivp.BackgroundImageAlias = []byte("alias record")
+
+	buf := &bytes.Buffer{}
+	if err := store.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Entries) != 2 {
+		t.Fatalf("legacy records must not be added to the store, got %d entries", len(store.Entries))
+	}
+	decoded, err := Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fwi0, ok := find(decoded, ".", entry.TypeFinderWindowInfo).(*entry.FinderWindowInfoEntry)
+	if !ok || fwi0.Top != 50 || fwi0.Left != 100 || fwi0.Bottom != 530 || fwi0.Right != 740 || fwi0.View != entry.ViewStyleIcon {
+		t.Errorf("unexpected window info: %#v", fwi0)
+	}
+	icvo, ok := find(decoded, ".", entry.TypeIconViewOptions).(*entry.IconViewOptionsEntry)
+	if !ok || icvo.IconSize != 128 || !icvo.LabelOnBottom {
+		t.Errorf("unexpected icon view options: %#v", icvo)
+	}
+	bkgd, ok := find(decoded, ".", entry.TypeBackground).(*entry.BackgroundEntry)
+	if !ok || bkgd.Kind != entry.BackgroundPicture || bkgd.PictureSize != uint32(len(ivp.BackgroundImageAlias)) {
+		t.Errorf("unexpected background: %#v", bkgd)
+	}
+	pict, ok := find(decoded, ".", entry.TypePicture).(*entry.PictureEntry)
+	if !ok || !bytes.Equal(pict.Alias, ivp.BackgroundImageAlias) {
+		t.Errorf("unexpected picture: %#v", pict)
+	}
+	if v, ok := find(decoded, ".", entry.TypeVersion).(*entry.VersionEntry); !ok || v.Version != 1 {
+		t.Errorf("unexpected version: %#v", v)
+	}
+
+	store.SetBgColor(1, 0.5, 0)
+	legacy := store.legacyEntries()
+	for _, e := range legacy {
+		if b, ok := e.(*entry.BackgroundEntry); ok {
+			if b.Kind != entry.BackgroundColor || b.Red != 0xffff || b.Green != 0x8000 || b.Blue != 0 {
+				t.Errorf("unexpected color background: %#v", b)
+			}
+		}
+		if e.EntryType() == entry.TypePicture {
+			t.Error("color background must not write a picture record")
+		}
+	}
+}
+
+func TestBackgroundImage(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "background.png")
+	if err := os.WriteFile(image, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewDSStore()
+	// the temporary directory may be on the root volume, which has no name
+	store.Volume = alias.NamedVolumeInfo{VolumeInfo: alias.DefaultVolumeInfo, Name: "MyApp"}
+	if err := store.SetBackgroundImage(image); err != nil {
+		t.Fatal(err)
+	}
+	ivp := store.getIconViewPreferences()
+	if ivp.BackgroundImageAlias == nil || !bytes.HasPrefix(ivp.BackgroundImageBookmark, []byte("book")) {
+		t.Fatal("expected both an alias and bookmark data")
+	}
+	pbbk, ok := find(store, ".", entry.TypePictureBookmark).(*entry.PictureBookmarkEntry)
+	if !ok || !bytes.Equal(pbbk.Bookmark, ivp.BackgroundImageBookmark) {
+		t.Fatalf("unexpected picture bookmark: %#v", pbbk)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := store.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.getIconViewPreferences().BackgroundImageBookmark, ivp.BackgroundImageBookmark) {
+		t.Error("bookmark data changed after round trip")
+	}
+
+	store.SetBgColor(1, 1, 1)
+	if find(store, ".", entry.TypePictureBookmark) != nil {
+		t.Error("picture bookmark must be removed with the background image")
+	}
+}
+
+func TestAllocatorOutOfSpace(t *testing.T) {
+	a := newAllocator()
+	if _, err := a.alloc(1 << 31); err == nil {
+		t.Error("expected an error when no block is large enough")
+	}
+	if _, err := a.allocBlock(pageSize); err != nil {
+		t.Errorf("failed to allocate a page after the error: %v", err)
+	}
+}
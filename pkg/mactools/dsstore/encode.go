@@ -0,0 +1,212 @@
+package dsstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// pageSize is the size of a B-tree node, as used by Finder.
+const pageSize = 0x1000
+
+// node is a B-tree node before it is written to its block.
+// Internal nodes have a child on the left of every record and a rightmost child in next.
+type node struct {
+	id       uint32
+	next     uint32
+	children []uint32
+	records  [][]byte
+}
+
+// Encode writes the entries as a .DS_Store file.
+func (ds *DSStore) Encode(w io.Writer) error {
+	sort.Stable(Entries(ds.Entries))
+	entries := ds.Entries
+	if ds.Legacy {
+		entries = append(ds.legacyEntries(), entries...)
+		sort.Stable(Entries(entries))
+	}
+	records := make([][]byte, len(entries))
+	for i, e := range entries {
+		records[i] = entryBuild(e)
+	}
+
+	a := newAllocator()
+	// block 0 holds the allocator itself and block 1 the B-tree header, the
+	// root block is sized once the number of nodes is known
+	a.offsets = []uint32{0, 0}
+	levels, err := buildTree(records)
+	if err != nil {
+		return err
+	}
+	nodeCount := 0
+	for _, level := range levels {
+		nodeCount += len(level)
+	}
+	if a.offsets[0], err = a.alloc(rootSize(uint32(2 + nodeCount))); err != nil {
+		return fmt.Errorf("failed to allocate the root block: %w", err)
+	}
+	if a.offsets[1], err = a.alloc(20); err != nil {
+		return fmt.Errorf("failed to allocate the B-tree header: %w", err)
+	}
+	a.toc["DSDB"] = 1
+
+	// assign block numbers from the leaves up, wiring each level to its children
+	for i, level := range levels {
+		for _, n := range level {
+			if n.id, err = a.allocBlock(pageSize); err != nil {
+				return fmt.Errorf("failed to allocate a B-tree node: %w", err)
+			}
+		}
+		if i == 0 {
+			continue
+		}
+		children := levels[i-1]
+		c := 0
+		for _, n := range level {
+			for j := range n.records {
+				n.children[j] = children[c].id
+				c++
+			}
+			n.next = children[c].id
+			c++
+		}
+	}
+	root := levels[len(levels)-1][0]
+
+	buf := make([]byte, 4+a.end())
+	binary.BigEndian.PutUint32(buf[0:], 1)
+	copy(buf[4:], "Bud1")
+	space := buf[4:]
+	rootAddr := a.offsets[0]
+	binary.BigEndian.PutUint32(space[4:], rootAddr&^0x1f)
+	binary.BigEndian.PutUint32(space[8:], 1<<(rootAddr&0x1f))
+	binary.BigEndian.PutUint32(space[12:], rootAddr&^0x1f)
+	binary.BigEndian.PutUint32(space[16:], 0x100c)
+
+	header := blockAt(space, a.offsets[1])
+	binary.BigEndian.PutUint32(header[0:], root.id)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(levels)-1))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(records)))
+	binary.BigEndian.PutUint32(header[12:], uint32(nodeCount))
+	binary.BigEndian.PutUint32(header[16:], pageSize)
+
+	for _, level := range levels {
+		for _, n := range level {
+			n.write(blockAt(space, a.offsets[n.id]))
+		}
+	}
+	a.writeRoot(blockAt(space, a.offsets[0]))
+
+	_, err = w.Write(buf)
+	return err
+}
+
+func blockAt(space []byte, addr uint32) []byte {
+	offset := addr &^ 0x1f
+	return space[offset : offset+1<<(addr&0x1f)]
+}
+
+func (n *node) write(buf []byte) {
+	w := &blockWriter{buf: buf}
+	w.uint32(n.next)
+	w.uint32(uint32(len(n.records)))
+	for i, record := range n.records {
+		if n.next != 0 {
+			w.uint32(n.children[i])
+		}
+		w.bytes(record)
+	}
+}
+
+// buildTree packs the sorted records into B-tree levels, from the leaves up to
+// a single root node. Every node except the last of a level is followed by a
+// record that moves up to separate it from its right sibling.
+func buildTree(records [][]byte) ([][]*node, error) {
+	sizes := make([]int, len(records))
+	for i, record := range records {
+		sizes[i] = len(record)
+	}
+	groups, err := split(sizes)
+	if err != nil {
+		return nil, err
+	}
+	var level []*node
+	var separators [][]byte
+	for i, g := range groups {
+		level = append(level, &node{records: records[g[0]:g[1]]})
+		if i < len(groups)-1 {
+			separators = append(separators, records[g[1]])
+		}
+	}
+	levels := [][]*node{level}
+
+	for len(separators) > 0 {
+		sizes = sizes[:0]
+		for _, record := range separators {
+			sizes = append(sizes, 4+len(record))
+		}
+		if groups, err = split(sizes); err != nil {
+			return nil, err
+		}
+		records, separators = separators, nil
+		level = nil
+		for i, g := range groups {
+			n := &node{records: records[g[0]:g[1]]}
+			n.children = make([]uint32, len(n.records))
+			level = append(level, n)
+			if i < len(groups)-1 {
+				separators = append(separators, records[g[1]])
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// split partitions consecutive items into groups that fit in a node. The item
+// between two groups is left out so it can be promoted to the parent level.
+func split(sizes []int) ([][2]int, error) {
+	const capacity = pageSize - 8
+	var groups [][2]int
+	start, used := 0, 0
+	for i, size := range sizes {
+		if size > capacity {
+			return nil, fmt.Errorf("record of %d bytes does not fit in a B-tree node", size)
+		}
+		if used+size <= capacity {
+			used += size
+			continue
+		}
+		groups = append(groups, [2]int{start, i})
+		start, used = i+1, 0
+	}
+	if start == len(sizes) && len(groups) > 0 {
+		// the last item was promoted, so take the separator from the previous group instead
+		prev := &groups[len(groups)-1]
+		if prev[1]-prev[0] < 2 {
+			return nil, errors.New("records are too large to build a B-tree")
+		}
+		prev[1]--
+		start = prev[1] + 1
+	}
+	return append(groups, [2]int{start, len(sizes)}), nil
+}
+
+func entryBuild(entry entry.Entry) []byte {
+	filename := utf16be(norm.NFD.String(entry.Filename()))
+	blob := entry.Bytes()
+	buffer := make([]byte, 4+len(filename)+4+4+len(blob))
+	binary.BigEndian.PutUint32(buffer[0:], uint32(len(filename)/2))
+	copy(buffer[4:], filename)
+	copy(buffer[4+len(filename):], entry.EntryType())
+	copy(buffer[8+len(filename):], entry.DataType())
+	copy(buffer[12+len(filename):], blob)
+	return buffer
+}
@@ -1,17 +1,124 @@
 package entry
 
-// NewBackgroundEntry creates a new background entry.
-//func NewBackgroundEntry(filename string, opts map[string]interface{}) (*EntryItem, error) {
-//	blob := make([]byte, 12+4)
-//	binary.BigEndian.PutUint32(blob[0:], uint32(len(blob)-4))
-//	if opts["color"] != nil {
-//		copy(blob[4:], "ClrB")
-//		return nil, fmt.Errorf("not implemented")
-//	} else if opts["pictureByteLength"] != nil {
-//		copy(blob[4:], "PctB")
-//		binary.BigEndian.PutUint32(blob[8:], opts["pictureByteLength"].(uint32))
-//	} else {
-//		copy(blob[4:], "DefB")
-//	}
-//	return NewEntry(filename, TypeBackground, "blob", blob), nil
-//}
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Background kinds stored in BKGD records.
+const (
+	BackgroundDefault = "DefB"
+	BackgroundColor   = "ClrB"
+	BackgroundPicture = "PctB"
+)
+
+// BackgroundEntry is the background record read by Finder versions that
+// predate the icvp property list. A picture background refers to the alias
+// stored in the pict record of the same folder.
+type BackgroundEntry struct {
+	Kind        string `json:"kind"`
+	Red         uint16 `json:"red,omitempty"`
+	Green       uint16 `json:"green,omitempty"`
+	Blue        uint16 `json:"blue,omitempty"`
+	PictureSize uint32 `json:"pictureSize,omitempty"` // length of the pict record
+	filename    string
+}
+
+func (b *BackgroundEntry) Bytes() []byte {
+	blob := make([]byte, 12+4)
+	binary.BigEndian.PutUint32(blob[0:], uint32(len(blob)-4))
+	switch b.Kind {
+	case BackgroundColor:
+		copy(blob[4:], BackgroundColor)
+		binary.BigEndian.PutUint16(blob[8:], b.Red)
+		binary.BigEndian.PutUint16(blob[10:], b.Green)
+		binary.BigEndian.PutUint16(blob[12:], b.Blue)
+	case BackgroundPicture:
+		copy(blob[4:], BackgroundPicture)
+		binary.BigEndian.PutUint32(blob[8:], b.PictureSize)
+	default:
+		copy(blob[4:], BackgroundDefault)
+	}
+	return blob
+}
+
+func (b *BackgroundEntry) Filename() string {
+	return b.filename
+}
+
+func (b *BackgroundEntry) EntryType() string {
+	return TypeBackground
+}
+
+func (b *BackgroundEntry) DataType() string {
+	return "blob"
+}
+
+// NewBackgroundEntry creates a new legacy background entry using the default background.
+func NewBackgroundEntry(filename string) *BackgroundEntry {
+	return &BackgroundEntry{
+		Kind:     BackgroundDefault,
+		filename: filename,
+	}
+}
+
+func decodeBackground(filename string, data []byte) (*BackgroundEntry, error) {
+	if len(data) != 16 || binary.BigEndian.Uint32(data) != 12 {
+		return nil, fmt.Errorf("invalid %s record for %q", TypeBackground, filename)
+	}
+	b := NewBackgroundEntry(filename)
+	b.Kind = string(data[4:8])
+	switch b.Kind {
+	case BackgroundColor:
+		b.Red = binary.BigEndian.Uint16(data[8:])
+		b.Green = binary.BigEndian.Uint16(data[10:])
+		b.Blue = binary.BigEndian.Uint16(data[12:])
+	case BackgroundPicture:
+		b.PictureSize = binary.BigEndian.Uint32(data[8:])
+	case BackgroundDefault:
+	default:
+		return nil, fmt.Errorf("unknown background kind %q for %q", b.Kind, filename)
+	}
+	return b, nil
+}
+
+// PictureEntry holds the alias of the background picture referenced by a
+// BKGD record.
+type PictureEntry struct {
+	Alias    []byte `json:"alias"`
+	filename string
+}
+
+func (p *PictureEntry) Bytes() []byte {
+	blob := make([]byte, 4+len(p.Alias))
+	binary.BigEndian.PutUint32(blob[0:], uint32(len(p.Alias)))
+	copy(blob[4:], p.Alias)
+	return blob
+}
+
+func (p *PictureEntry) Filename() string {
+	return p.filename
+}
+
+func (p *PictureEntry) EntryType() string {
+	return TypePicture
+}
+
+func (p *PictureEntry) DataType() string {
+	return "blob"
+}
+
+// NewPictureEntry creates a new picture entry from an alias record.
+func NewPictureEntry(filename string, alias []byte) *PictureEntry {
+	return &PictureEntry{
+		Alias:    alias,
+		filename: filename,
+	}
+}
+
+func decodePicture(filename string, data []byte) (*PictureEntry, error) {
+	if len(data) < 4 || int(binary.BigEndian.Uint32(data)) != len(data)-4 {
+		return nil, fmt.Errorf("invalid %s record for %q", TypePicture, filename)
+	}
+	return NewPictureEntry(filename, data[4:]), nil
+}
@@ -7,36 +7,43 @@ import (
 )
 
 type WorkspaceSettingsEntry struct {
-	ContainerShowSidebar bool
-	ShowPathbar          bool
-	ShowSidebar          bool
-	ShowStatusBar        bool
-	ShowTabView          bool
-	ShowToolbar          bool
-	SidebarWidth         int
-	X                    int
-	Y                    int
-	Width                int
-	Height               int
+	ContainerShowSidebar bool `json:"containerShowSidebar"`
+	ShowPathbar          bool `json:"showPathbar"`
+	ShowSidebar          bool `json:"showSidebar"`
+	ShowStatusBar        bool `json:"showStatusBar"`
+	ShowTabView          bool `json:"showTabView"`
+	ShowToolbar          bool `json:"showToolbar"`
+	SidebarWidth         int  `json:"sidebarWidth"`
+	X                    int  `json:"x"`
+	Y                    int  `json:"y"`
+	Width                int  `json:"width"`
+	Height               int  `json:"height"`
+	// Extra holds keys that have no dedicated field so that decoded
+	// settings are written back unchanged.
+	Extra    PlistDict `json:"extra,omitempty"`
+	filename string
 }
 
 func (w *WorkspaceSettingsEntry) Bytes() []byte {
 	buffer := &bytes.Buffer{}
-	_ = plist.NewBinaryEncoder(buffer).Encode(map[string]any{
-		"ContainerShowSidebar": true,
-		"ShowPathbar":          false,
-		"ShowSidebar":          true,
-		"ShowStatusBar":        false,
-		"ShowTabView":          false,
-		"ShowToolbar":          false,
-		"SidebarWidth":         0,
+	_ = plist.NewBinaryEncoder(buffer).Encode(mergeExtra(map[string]any{
+		"ContainerShowSidebar": w.ContainerShowSidebar,
+		"ShowPathbar":          w.ShowPathbar,
+		"ShowSidebar":          w.ShowSidebar,
+		"ShowStatusBar":        w.ShowStatusBar,
+		"ShowTabView":          w.ShowTabView,
+		"ShowToolbar":          w.ShowToolbar,
+		"SidebarWidth":         w.SidebarWidth,
 		"WindowBounds":         fmt.Sprintf("{{%d, %d}, {%d, %d}}", w.X, w.Y, w.Width, w.Height),
-	})
+	}, w.Extra))
 	return plistWrap(buffer.Bytes())
 }
 
 func (w *WorkspaceSettingsEntry) Filename() string {
-	return "."
+	if w.filename == "" {
+		return "."
+	}
+	return w.filename
 }
 
 func (w *WorkspaceSettingsEntry) EntryType() string {
@@ -63,3 +70,26 @@ func NewWorkspaceSettingsEntry(x, y, width, height int) *WorkspaceSettingsEntry
 		SidebarWidth:         0,
 	}
 }
+
+func decodeWorkspaceSettings(filename string, data []byte) (*WorkspaceSettingsEntry, error) {
+	values, err := plistUnwrap(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s record for %q: %w", TypeWorkspaceSettings, filename, err)
+	}
+	w := &WorkspaceSettingsEntry{filename: filename}
+	w.ContainerShowSidebar = popBool(values, "ContainerShowSidebar", true)
+	w.ShowPathbar = popBool(values, "ShowPathbar", false)
+	w.ShowSidebar = popBool(values, "ShowSidebar", true)
+	w.ShowStatusBar = popBool(values, "ShowStatusBar", false)
+	w.ShowTabView = popBool(values, "ShowTabView", false)
+	w.ShowToolbar = popBool(values, "ShowToolbar", false)
+	w.SidebarWidth = popInt(values, "SidebarWidth", 0)
+	bounds := popString(values, "WindowBounds", "{{0, 0}, {0, 0}}")
+	if _, err := fmt.Sscanf(bounds, "{{%d, %d}, {%d, %d}}", &w.X, &w.Y, &w.Width, &w.Height); err != nil {
+		return nil, fmt.Errorf("invalid WindowBounds %q: %w", bounds, err)
+	}
+	if len(values) > 0 {
+		w.Extra = values
+	}
+	return w, nil
+}
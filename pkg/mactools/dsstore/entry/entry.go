@@ -2,6 +2,9 @@ package entry
 
 import (
 	"encoding/binary"
+	"errors"
+
+	"howett.net/plist"
 )
 
 const (
@@ -13,12 +16,22 @@ const (
 	TypeFinderWindowInfo = "fwi0"
 	// TypePicture represents the picture entry type
 	TypePicture = "pict"
+	// TypePictureBookmark represents the background picture bookmark entry type
+	TypePictureBookmark = "pBBk"
 	// TypeWorkspaceSettings represents the workspace settings entry type
 	TypeWorkspaceSettings = "bwsp"
 	// TypeIconViewPreferences represents the icon view preferences entry type
 	TypeIconViewPreferences = "icvp"
 	// TypeVersion represents the version entry type
 	TypeVersion = "vSrn"
+	// TypeListViewPreferences represents the list view preferences entry type
+	TypeListViewPreferences = "lsvp"
+	// TypeListViewProperties represents the list view properties entry type used by newer Finder versions
+	TypeListViewProperties = "lsvP"
+	// TypeViewStyle represents the view style entry type
+	TypeViewStyle = "vstl"
+	// TypeIconViewOptions represents a deprecated icon view options entry type
+	TypeIconViewOptions = "icvo"
 )
 
 type Entry interface {
@@ -28,12 +41,25 @@ type Entry interface {
 	DataType() string
 }
 
+// EntryItem is a record without a dedicated type. Buffer holds the encoded
+// value exactly as it is stored after the data type code.
 type EntryItem struct {
 	filename  string
 	entryType string
+	dataType  string
 	Buffer    []byte
 }
 
+// NewEntry creates a new raw entry.
+func NewEntry(filename, entryType, dataType string, buffer []byte) *EntryItem {
+	return &EntryItem{
+		filename:  filename,
+		entryType: entryType,
+		dataType:  dataType,
+		Buffer:    buffer,
+	}
+}
+
 func (e EntryItem) Filename() string {
 	return e.filename
 }
@@ -42,10 +68,123 @@ func (e EntryItem) EntryType() string {
 	return e.entryType
 }
 
+func (e EntryItem) DataType() string {
+	return e.dataType
+}
+
 func (e EntryItem) Bytes() []byte {
 	return e.Buffer
 }
 
+// Decode converts a record value into a typed entry.
+// Records without a dedicated type are returned as *EntryItem.
+func Decode(filename, entryType, dataType string, data []byte) (Entry, error) {
+	if dataType == "blob" {
+		switch entryType {
+		case TypeIconLocation:
+			return decodeIconLocation(filename, data)
+		case TypeIconViewPreferences:
+			return decodeIconViewPreferences(filename, data)
+		case TypeWorkspaceSettings:
+			return decodeWorkspaceSettings(filename, data)
+		case TypeListViewPreferences, TypeListViewProperties:
+			return decodeListViewPreferences(filename, entryType, data)
+		case TypeFinderWindowInfo:
+			return decodeFinderWindowInfo(filename, data)
+		case TypeBackground:
+			return decodeBackground(filename, data)
+		case TypePicture:
+			return decodePicture(filename, data)
+		case TypePictureBookmark:
+			return decodePictureBookmark(filename, data)
+		case TypeIconViewOptions:
+			if len(data) >= 8 && string(data[4:8]) == "icv4" {
+				return decodeIconViewOptions(filename, data)
+			}
+		}
+	}
+	if dataType == "type" && entryType == TypeViewStyle {
+		return decodeViewStyle(filename, data)
+	}
+	if dataType == "long" && entryType == TypeVersion {
+		return decodeVersion(filename, data)
+	}
+	return NewEntry(filename, entryType, dataType, data), nil
+}
+
+func plistUnwrap(data []byte) (map[string]any, error) {
+	if len(data) < 4 || int(binary.BigEndian.Uint32(data)) != len(data)-4 {
+		return nil, errors.New("invalid blob length")
+	}
+	values := map[string]any{}
+	if _, err := plist.Unmarshal(data[4:], &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// popBool removes key from values and returns it as a bool.
+func popBool(values map[string]any, key string, def bool) bool {
+	v, ok := values[key].(bool)
+	if !ok {
+		return def
+	}
+	delete(values, key)
+	return v
+}
+
+// popFloat removes key from values and returns it as a float64.
+// Finder writes some sizes as integers and others as reals.
+func popFloat(values map[string]any, key string, def float64) float64 {
+	var v float64
+	switch n := values[key].(type) {
+	case float64:
+		v = n
+	case float32:
+		v = float64(n)
+	case int64:
+		v = float64(n)
+	case uint64:
+		v = float64(n)
+	default:
+		return def
+	}
+	delete(values, key)
+	return v
+}
+
+func popInt(values map[string]any, key string, def int) int {
+	return int(popFloat(values, key, float64(def)))
+}
+
+func popString(values map[string]any, key string, def string) string {
+	v, ok := values[key].(string)
+	if !ok {
+		return def
+	}
+	delete(values, key)
+	return v
+}
+
+func popData(values map[string]any, key string) []byte {
+	v, ok := values[key].([]byte)
+	if !ok {
+		return nil
+	}
+	delete(values, key)
+	return v
+}
+
+// mergeExtra adds the keys that were not decoded into typed fields back into base.
+func mergeExtra(base, extra map[string]any) map[string]any {
+	for k, v := range extra {
+		if _, ok := base[k]; !ok {
+			base[k] = v
+		}
+	}
+	return base
+}
+
 func plistWrap(buffer []byte) []byte {
 	newBuff := make([]byte, len(buffer)+4)
 	binary.BigEndian.PutUint32(newBuff[0:4], uint32(len(buffer)))
@@ -0,0 +1,73 @@
+package entry
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FinderWindowInfoEntry is the window record read by Finder versions that
+// predate the bwsp property list. The window rectangle uses QuickDraw order.
+type FinderWindowInfoEntry struct {
+	Top      uint16 `json:"top"`
+	Left     uint16 `json:"left"`
+	Bottom   uint16 `json:"bottom"`
+	Right    uint16 `json:"right"`
+	View     string `json:"view"` // one of the vstl view styles
+	Flags    uint32 `json:"flags"`
+	filename string
+}
+
+func (f *FinderWindowInfoEntry) Bytes() []byte {
+	blob := make([]byte, 16+4)
+	binary.BigEndian.PutUint32(blob[0:], uint32(len(blob)-4))
+	binary.BigEndian.PutUint16(blob[4:], f.Top)
+	binary.BigEndian.PutUint16(blob[6:], f.Left)
+	binary.BigEndian.PutUint16(blob[8:], f.Bottom)
+	binary.BigEndian.PutUint16(blob[10:], f.Right)
+	copy(blob[12:16], ViewStyleIcon)
+	copy(blob[12:16], f.View)
+	binary.BigEndian.PutUint32(blob[16:], f.Flags)
+	return blob
+}
+
+func (f *FinderWindowInfoEntry) Filename() string {
+	return f.filename
+}
+
+func (f *FinderWindowInfoEntry) EntryType() string {
+	return TypeFinderWindowInfo
+}
+
+func (f *FinderWindowInfoEntry) DataType() string {
+	return "blob"
+}
+
+// NewFinderWindowInfoEntry creates a new legacy window entry from a window
+// position and size. Like the bwsp bounds, the position is measured from the
+// top left corner of the screen, so y is the top of the rectangle.
+func NewFinderWindowInfoEntry(filename string, x, y, width, height int, view string) *FinderWindowInfoEntry {
+	return &FinderWindowInfoEntry{
+		Top:      uint16(y),
+		Left:     uint16(x),
+		Bottom:   uint16(y + height),
+		Right:    uint16(x + width),
+		View:     view,
+		Flags:    0x00010000,
+		filename: filename,
+	}
+}
+
+func decodeFinderWindowInfo(filename string, data []byte) (*FinderWindowInfoEntry, error) {
+	if len(data) != 20 || binary.BigEndian.Uint32(data) != 16 {
+		return nil, fmt.Errorf("invalid %s record for %q", TypeFinderWindowInfo, filename)
+	}
+	return &FinderWindowInfoEntry{
+		Top:      binary.BigEndian.Uint16(data[4:]),
+		Left:     binary.BigEndian.Uint16(data[6:]),
+		Bottom:   binary.BigEndian.Uint16(data[8:]),
+		Right:    binary.BigEndian.Uint16(data[10:]),
+		View:     string(data[12:16]),
+		Flags:    binary.BigEndian.Uint32(data[16:]),
+		filename: filename,
+	}, nil
+}
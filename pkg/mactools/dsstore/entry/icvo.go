@@ -0,0 +1,69 @@
+package entry
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// IconViewOptionsEntry is the icon view record read by Finder versions that
+// predate the icvp property list.
+type IconViewOptionsEntry struct {
+	IconSize      uint16   `json:"iconSize"`
+	ArrangeBy     string   `json:"arrangeBy"` // none or grid
+	LabelOnBottom bool     `json:"labelOnBottom"`
+	Flags         [12]byte `json:"flags"`
+	filename      string
+}
+
+func (i *IconViewOptionsEntry) Bytes() []byte {
+	blob := make([]byte, 4+26)
+	binary.BigEndian.PutUint32(blob[0:], uint32(len(blob)-4))
+	copy(blob[4:], "icv4")
+	binary.BigEndian.PutUint16(blob[8:], i.IconSize)
+	copy(blob[10:14], "none")
+	if i.ArrangeBy == "grid" {
+		copy(blob[10:14], "grid")
+	}
+	copy(blob[14:18], "rght")
+	if i.LabelOnBottom {
+		copy(blob[14:18], "botm")
+	}
+	copy(blob[18:], i.Flags[:])
+	return blob
+}
+
+func (i *IconViewOptionsEntry) Filename() string {
+	return i.filename
+}
+
+func (i *IconViewOptionsEntry) EntryType() string {
+	return TypeIconViewOptions
+}
+
+func (i *IconViewOptionsEntry) DataType() string {
+	return "blob"
+}
+
+// NewIconViewOptionsEntry creates a new legacy icon view options entry.
+func NewIconViewOptionsEntry(filename string, iconSize uint16) *IconViewOptionsEntry {
+	return &IconViewOptionsEntry{
+		IconSize:  iconSize,
+		ArrangeBy: "none",
+		filename:  filename,
+	}
+}
+
+// decodeIconViewOptions reads the icv4 variant of the record. Older variants
+// are kept as raw entries by the caller.
+func decodeIconViewOptions(filename string, data []byte) (*IconViewOptionsEntry, error) {
+	if len(data) != 30 || int(binary.BigEndian.Uint32(data)) != len(data)-4 || string(data[4:8]) != "icv4" {
+		return nil, fmt.Errorf("invalid %s record for %q", TypeIconViewOptions, filename)
+	}
+	i := NewIconViewOptionsEntry(filename, binary.BigEndian.Uint16(data[8:]))
+	if string(data[10:14]) == "grid" {
+		i.ArrangeBy = "grid"
+	}
+	i.LabelOnBottom = string(data[14:18]) == "botm"
+	copy(i.Flags[:], data[18:])
+	return i, nil
+}
@@ -3,28 +3,38 @@ package entry
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"github.com/ironpark/zapp/pkg/mactools/alias"
+	"github.com/ironpark/zapp/pkg/mactools/bookmark"
 	"unicode/utf16"
 
 	"howett.net/plist"
 )
 
 type IconViewPreferencesEntry struct {
-	BackgroundType       int
-	BackgroundColorRed   float64
-	BackgroundColorGreen float64
-	BackgroundColorBlue  float64
-	BackgroundImageAlias []byte // 배경 이미지 경로 추가
-	ShowIconPreview      bool
-	ShowItemInfo         bool
-	TextSize             float64
-	IconSize             float64
-	ViewOptionsVersion   int
-	GridSpacing          float64
-	GridOffsetX          float64
-	GridOffsetY          float64
-	LabelOnBottom        bool
-	ArrangeBy            string
+	BackgroundType       int     `json:"backgroundType"`
+	BackgroundColorRed   float64 `json:"backgroundColorRed"`
+	BackgroundColorGreen float64 `json:"backgroundColorGreen"`
+	BackgroundColorBlue  float64 `json:"backgroundColorBlue"`
+	BackgroundImageAlias []byte  `json:"backgroundImageAlias,omitempty"` // 배경 이미지 경로 추가
+	ShowIconPreview      bool    `json:"showIconPreview"`
+	ShowItemInfo         bool    `json:"showItemInfo"`
+	TextSize             float64 `json:"textSize"`
+	IconSize             float64 `json:"iconSize"`
+	ViewOptionsVersion   int     `json:"viewOptionsVersion"`
+	GridSpacing          float64 `json:"gridSpacing"`
+	GridOffsetX          float64 `json:"gridOffsetX"`
+	GridOffsetY          float64 `json:"gridOffsetY"`
+	LabelOnBottom        bool    `json:"labelOnBottom"`
+	ArrangeBy            string  `json:"arrangeBy"`
+
+	// BackgroundImageBookmark is the bookmark data of the background image,
+	// read by Finder versions that no longer resolve aliases.
+	BackgroundImageBookmark []byte `json:"backgroundImageBookmark,omitempty"`
+	// Extra holds keys that have no dedicated field so that decoded
+	// preferences are written back unchanged.
+	Extra    PlistDict `json:"extra,omitempty"`
+	filename string
 }
 
 func (i *IconViewPreferencesEntry) Bytes() []byte {
@@ -49,9 +59,12 @@ func (i *IconViewPreferencesEntry) Bytes() []byte {
 	if i.BackgroundType == 2 && i.BackgroundImageAlias != nil {
 		base["backgroundImageAlias"] = i.BackgroundImageAlias
 	}
+	if i.BackgroundType == 2 && i.BackgroundImageBookmark != nil {
+		base["backgroundImageBookmark"] = i.BackgroundImageBookmark
+	}
 
 	buffer := &bytes.Buffer{}
-	err := plist.NewBinaryEncoder(buffer).Encode(base)
+	err := plist.NewBinaryEncoder(buffer).Encode(mergeExtra(base, i.Extra))
 	if err != nil {
 		return nil
 	}
@@ -61,6 +74,7 @@ func (i *IconViewPreferencesEntry) Bytes() []byte {
 func (i *IconViewPreferencesEntry) SetBgToDefault() {
 	i.BackgroundType = 0
 	i.BackgroundImageAlias = nil
+	i.BackgroundImageBookmark = nil
 }
 
 func (i *IconViewPreferencesEntry) SetBgColor(r, g, b float64) {
@@ -69,16 +83,40 @@ func (i *IconViewPreferencesEntry) SetBgColor(r, g, b float64) {
 	i.BackgroundColorGreen = g
 	i.BackgroundColorBlue = b
 	i.BackgroundImageAlias = nil
+	i.BackgroundImageBookmark = nil
 }
 
-func (i *IconViewPreferencesEntry) SetBgImage(imagePath string) (err error) {
+// SetBgImage refers to the background image with both an alias and bookmark data.
+func (i *IconViewPreferencesEntry) SetBgImage(imagePath string, volume alias.VolumeInfo) (err error) {
 	i.BackgroundType = 2
-	i.BackgroundImageAlias, err = alias.Create(imagePath)
+	if i.BackgroundImageAlias, err = alias.CreateWith(imagePath, volume); err != nil {
+		return err
+	}
+	i.BackgroundImageBookmark, err = bookmark.CreateWith(imagePath, volume)
 	return err
 }
 
+// SetBgImageData refers to the background image with a prepared alias and
+// bookmark data, for images on volumes that are not mounted.
+func (i *IconViewPreferencesEntry) SetBgImageData(aliasData, bookmarkData []byte) {
+	i.BackgroundType = 2
+	i.BackgroundImageAlias = aliasData
+	i.BackgroundImageBookmark = bookmarkData
+}
+
+// SetBgImageAlias refers to the background image with a prepared alias only,
+// for images on volumes that are not mounted.
+func (i *IconViewPreferencesEntry) SetBgImageAlias(aliasData []byte) {
+	i.BackgroundType = 2
+	i.BackgroundImageAlias = aliasData
+	i.BackgroundImageBookmark = nil
+}
+
 func (i *IconViewPreferencesEntry) Filename() string {
-	return "."
+	if i.filename == "" {
+		return "."
+	}
+	return i.filename
 }
 
 func (i *IconViewPreferencesEntry) EntryType() string {
@@ -110,6 +148,35 @@ func NewIconViewPreferencesEntry(iconSize float64) *IconViewPreferencesEntry {
 	}
 }
 
+func decodeIconViewPreferences(filename string, data []byte) (*IconViewPreferencesEntry, error) {
+	values, err := plistUnwrap(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s record for %q: %w", TypeIconViewPreferences, filename, err)
+	}
+	i := NewIconViewPreferencesEntry(64)
+	i.filename = filename
+	i.BackgroundType = popInt(values, "backgroundType", i.BackgroundType)
+	i.BackgroundColorRed = popFloat(values, "backgroundColorRed", i.BackgroundColorRed)
+	i.BackgroundColorGreen = popFloat(values, "backgroundColorGreen", i.BackgroundColorGreen)
+	i.BackgroundColorBlue = popFloat(values, "backgroundColorBlue", i.BackgroundColorBlue)
+	i.BackgroundImageAlias = popData(values, "backgroundImageAlias")
+	i.BackgroundImageBookmark = popData(values, "backgroundImageBookmark")
+	i.ShowIconPreview = popBool(values, "showIconPreview", i.ShowIconPreview)
+	i.ShowItemInfo = popBool(values, "showItemInfo", i.ShowItemInfo)
+	i.TextSize = popFloat(values, "textSize", i.TextSize)
+	i.IconSize = popFloat(values, "iconSize", i.IconSize)
+	i.ViewOptionsVersion = popInt(values, "viewOptionsVersion", i.ViewOptionsVersion)
+	i.GridSpacing = popFloat(values, "gridSpacing", i.GridSpacing)
+	i.GridOffsetX = popFloat(values, "gridOffsetX", i.GridOffsetX)
+	i.GridOffsetY = popFloat(values, "gridOffsetY", i.GridOffsetY)
+	i.LabelOnBottom = popBool(values, "labelOnBottom", i.LabelOnBottom)
+	i.ArrangeBy = popString(values, "arrangeBy", i.ArrangeBy)
+	if len(values) > 0 {
+		i.Extra = values
+	}
+	return i, nil
+}
+
 // utf16be converts a string to a big-endian UTF-16 byte slice.
 func utf16be(str string) []byte {
 	utf16Encoded := utf16.Encode([]rune(str))
@@ -1,10 +1,13 @@
 package entry
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"fmt"
+)
 
 type IconLocationEntry struct {
-	X        uint32
-	Y        uint32
+	X        uint32 `json:"x"`
+	Y        uint32 `json:"y"`
 	filename string
 }
 
@@ -37,3 +40,10 @@ func NewIconLocationEntry(filename string, x, y uint32) *IconLocationEntry {
 		filename: filename,
 	}
 }
+
+func decodeIconLocation(filename string, data []byte) (*IconLocationEntry, error) {
+	if len(data) < 12 || int(binary.BigEndian.Uint32(data)) != len(data)-4 {
+		return nil, fmt.Errorf("invalid %s record for %q", TypeIconLocation, filename)
+	}
+	return NewIconLocationEntry(filename, binary.BigEndian.Uint32(data[4:]), binary.BigEndian.Uint32(data[8:])), nil
+}
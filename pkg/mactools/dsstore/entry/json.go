@@ -0,0 +1,297 @@
+package entry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// Unmarshal creates an entry from the JSON form of its value,
+// as produced by encoding/json for the same entry type.
+func Unmarshal(filename, entryType, dataType string, value []byte) (Entry, error) {
+	var e Entry
+	switch {
+	case dataType == "blob" && len(value) > 0 && value[0] == '"':
+		// blobs without a typed decoder are dumped as base64 strings
+		e = NewEntry(filename, entryType, dataType, nil)
+	case dataType == "blob" && entryType == TypeIconLocation:
+		e = NewIconLocationEntry(filename, 0, 0)
+	case dataType == "blob" && entryType == TypeIconViewPreferences:
+		ivp := NewIconViewPreferencesEntry(64)
+		ivp.filename = filename
+		e = ivp
+	case dataType == "blob" && entryType == TypeWorkspaceSettings:
+		e = &WorkspaceSettingsEntry{filename: filename}
+	case dataType == "blob" && (entryType == TypeListViewPreferences || entryType == TypeListViewProperties):
+		e = newListViewEntry(filename, entryType)
+	case dataType == "blob" && entryType == TypeIconViewOptions:
+		e = NewIconViewOptionsEntry(filename, 0)
+	case dataType == "blob" && entryType == TypeFinderWindowInfo:
+		e = &FinderWindowInfoEntry{filename: filename}
+	case dataType == "blob" && entryType == TypeBackground:
+		e = NewBackgroundEntry(filename)
+	case dataType == "blob" && entryType == TypePicture:
+		e = NewPictureEntry(filename, nil)
+	case dataType == "blob" && entryType == TypePictureBookmark:
+		e = NewPictureBookmarkEntry(filename, nil)
+	case dataType == "long" && entryType == TypeVersion:
+		e = NewVersionEntry(filename, 0)
+	case dataType == "type" && entryType == TypeViewStyle:
+		e = NewViewStyleEntry(filename, ViewStyleIcon)
+	default:
+		e = NewEntry(filename, entryType, dataType, nil)
+	}
+	if err := json.Unmarshal(value, e); err != nil {
+		return nil, fmt.Errorf("invalid value for %s %q: %w", entryType, filename, err)
+	}
+	return e, nil
+}
+
+// Value returns the decoded value of the entry according to its data type.
+// Blobs are returned as raw bytes.
+func (e EntryItem) Value() (any, error) {
+	b := e.Buffer
+	switch e.dataType {
+	case "bool":
+		if len(b) == 1 {
+			return b[0] != 0, nil
+		}
+	case "long", "shor":
+		if len(b) == 4 {
+			return int32(binary.BigEndian.Uint32(b)), nil
+		}
+	case "comp", "dutc":
+		if len(b) == 8 {
+			return int64(binary.BigEndian.Uint64(b)), nil
+		}
+	case "type":
+		if len(b) == 4 {
+			return string(b), nil
+		}
+	case "ustr":
+		if len(b) >= 4 && len(b) == 4+int(binary.BigEndian.Uint32(b))*2 {
+			u16 := make([]uint16, (len(b)-4)/2)
+			for i := range u16 {
+				u16[i] = binary.BigEndian.Uint16(b[4+i*2:])
+			}
+			return string(utf16.Decode(u16)), nil
+		}
+	case "blob":
+		if len(b) >= 4 && len(b) == 4+int(binary.BigEndian.Uint32(b)) {
+			return b[4:], nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown data type %q", e.dataType)
+	}
+	return nil, fmt.Errorf("invalid %s value for %s %q", e.dataType, e.entryType, e.filename)
+}
+
+func (e EntryItem) MarshalJSON() ([]byte, error) {
+	v, err := e.Value()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func (e *EntryItem) UnmarshalJSON(data []byte) error {
+	var b []byte
+	switch e.dataType {
+	case "bool":
+		var v bool
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		b = []byte{0}
+		if v {
+			b[0] = 1
+		}
+	case "long", "shor":
+		var v int32
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		b = binary.BigEndian.AppendUint32(nil, uint32(v))
+	case "comp", "dutc":
+		var v int64
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		b = binary.BigEndian.AppendUint64(nil, uint64(v))
+	case "type":
+		var v string
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if len(v) != 4 {
+			return fmt.Errorf("type code must be 4 characters: %q", v)
+		}
+		b = []byte(v)
+	case "ustr":
+		var v string
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		u16 := utf16.Encode([]rune(v))
+		b = binary.BigEndian.AppendUint32(nil, uint32(len(u16)))
+		for _, c := range u16 {
+			b = binary.BigEndian.AppendUint16(b, c)
+		}
+	case "blob":
+		var v []byte
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		b = binary.BigEndian.AppendUint32(nil, uint32(len(v)))
+		b = append(b, v...)
+	default:
+		return fmt.Errorf("unknown data type %q", e.dataType)
+	}
+	e.Buffer = b
+	return nil
+}
+
+// The version is stored as a plain number, like other long records.
+func (v *VersionEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Version)
+}
+
+func (v *VersionEntry) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &v.Version)
+}
+
+// PlistDict holds property list values without a typed field. Values that
+// JSON cannot tell apart are tagged with their type, {"data": base64},
+// {"real": 1.0} or {"date": RFC 3339}, and so are dictionaries that would
+// read as a tag, {"dict": {...}}, so that they are written back unchanged.
+type PlistDict map[string]any
+
+// plistTags are the keys of the objects that tag a value with its type.
+var plistTags = []string{"data", "real", "date", "dict"}
+
+func (d PlistDict) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tagPlist(map[string]any(d)))
+}
+
+func (d *PlistDict) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return err
+	}
+	if values == nil {
+		*d = nil
+		return nil
+	}
+	v, err := untagPlist(values, false)
+	if err != nil {
+		return err
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("extra values must be a dictionary, got %T", v)
+	}
+	*d = m
+	return nil
+}
+
+func tagPlist(value any) any {
+	switch v := value.(type) {
+	case []byte:
+		return map[string]any{"data": v}
+	case float32:
+		return map[string]any{"real": float64(v)}
+	case float64:
+		return map[string]any{"real": v}
+	case time.Time:
+		return map[string]any{"date": v}
+	case []any:
+		tagged := make([]any, len(v))
+		for i, x := range v {
+			tagged[i] = tagPlist(x)
+		}
+		return tagged
+	case map[string]any:
+		tagged := make(map[string]any, len(v))
+		for k, x := range v {
+			tagged[k] = tagPlist(x)
+		}
+		if isPlistTag(v) {
+			return map[string]any{"dict": tagged}
+		}
+		return tagged
+	}
+	return value
+}
+
+func isPlistTag(m map[string]any) bool {
+	for k := range m {
+		return len(m) == 1 && slices.Contains(plistTags, k)
+	}
+	return false
+}
+
+// untagPlist reverses tagPlist on values decoded with json.Number. Untagged
+// numbers are integers, or reals when they have a fraction.
+func untagPlist(value any, dict bool) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case []any:
+		for i, x := range v {
+			var err error
+			if v[i], err = untagPlist(x, false); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[string]any:
+		if !dict && isPlistTag(v) {
+			for tag, x := range v {
+				return untagValue(tag, x)
+			}
+		}
+		for k, x := range v {
+			var err error
+			if v[k], err = untagPlist(x, false); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+func untagValue(tag string, value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		switch tag {
+		case "data":
+			return base64.StdEncoding.DecodeString(v)
+		case "date":
+			return time.Parse(time.RFC3339Nano, v)
+		}
+	case json.Number:
+		if tag == "real" {
+			return v.Float64()
+		}
+	case map[string]any:
+		if tag == "dict" {
+			return untagPlist(v, true)
+		}
+	}
+	return nil, fmt.Errorf("invalid %s value %v", tag, value)
+}
@@ -0,0 +1,196 @@
+package entry
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"howett.net/plist"
+)
+
+// ListViewColumn describes a column of the Finder list view.
+type ListViewColumn struct {
+	Identifier string `json:"identifier"`
+	Width      int    `json:"width"`
+	Visible    bool   `json:"visible"`
+	Ascending  bool   `json:"ascending"`
+}
+
+// DefaultListViewColumns returns the columns of a new Finder window, in display order.
+func DefaultListViewColumns() []ListViewColumn {
+	return []ListViewColumn{
+		{Identifier: "name", Width: 300, Visible: true, Ascending: true},
+		{Identifier: "dateModified", Width: 181, Visible: true},
+		{Identifier: "size", Width: 97, Visible: true},
+		{Identifier: "kind", Width: 115, Visible: true, Ascending: true},
+		{Identifier: "dateCreated", Width: 181},
+		{Identifier: "label", Width: 100, Ascending: true},
+		{Identifier: "version", Width: 75, Ascending: true},
+		{Identifier: "comments", Width: 300, Ascending: true},
+		{Identifier: "dateLastOpened", Width: 200},
+		{Identifier: "dateAdded", Width: 181},
+	}
+}
+
+// ListViewPreferencesEntry holds the list view settings of a folder. Finder
+// reads them from lsvp (columns keyed by name) or from the newer lsvP
+// (columns as an ordered array), so both record types share this entry.
+type ListViewPreferencesEntry struct {
+	Columns            []ListViewColumn `json:"columns"`
+	SortColumn         string           `json:"sortColumn"`
+	IconSize           float64          `json:"iconSize"`
+	TextSize           float64          `json:"textSize"`
+	ShowIconPreview    bool             `json:"showIconPreview"`
+	UseRelativeDates   bool             `json:"useRelativeDates"`
+	CalculateAllSizes  bool             `json:"calculateAllSizes"`
+	ViewOptionsVersion int              `json:"viewOptionsVersion"`
+	// Extra holds keys that have no dedicated field so that decoded
+	// preferences are written back unchanged.
+	Extra     PlistDict `json:"extra,omitempty"`
+	filename  string
+	entryType string
+}
+
+func (l *ListViewPreferencesEntry) Bytes() []byte {
+	base := map[string]any{
+		"sortColumn":         l.SortColumn,
+		"iconSize":           l.IconSize,
+		"textSize":           l.TextSize,
+		"showIconPreview":    l.ShowIconPreview,
+		"useRelativeDates":   l.UseRelativeDates,
+		"calculateAllSizes":  l.CalculateAllSizes,
+		"viewOptionsVersion": l.ViewOptionsVersion,
+	}
+	if l.entryType == TypeListViewProperties {
+		columns := make([]map[string]any, len(l.Columns))
+		for i, c := range l.Columns {
+			columns[i] = map[string]any{
+				"identifier": c.Identifier,
+				"width":      c.Width,
+				"visible":    c.Visible,
+				"ascending":  c.Ascending,
+			}
+		}
+		base["columns"] = columns
+	} else {
+		columns := map[string]any{}
+		for i, c := range l.Columns {
+			columns[c.Identifier] = map[string]any{
+				"index":     i,
+				"width":     c.Width,
+				"visible":   c.Visible,
+				"ascending": c.Ascending,
+			}
+		}
+		base["columns"] = columns
+	}
+
+	buffer := &bytes.Buffer{}
+	err := plist.NewBinaryEncoder(buffer).Encode(mergeExtra(base, l.Extra))
+	if err != nil {
+		return nil
+	}
+	return plistWrap(buffer.Bytes())
+}
+
+func (l *ListViewPreferencesEntry) Filename() string {
+	return l.filename
+}
+
+func (l *ListViewPreferencesEntry) EntryType() string {
+	return l.entryType
+}
+
+func (l *ListViewPreferencesEntry) DataType() string {
+	return "blob"
+}
+
+// NewListViewPreferencesEntry creates a new lsvp entry.
+func NewListViewPreferencesEntry(filename string) *ListViewPreferencesEntry {
+	return newListViewEntry(filename, TypeListViewPreferences)
+}
+
+// NewListViewPropertiesEntry creates a new lsvP entry.
+func NewListViewPropertiesEntry(filename string) *ListViewPreferencesEntry {
+	return newListViewEntry(filename, TypeListViewProperties)
+}
+
+func newListViewEntry(filename, entryType string) *ListViewPreferencesEntry {
+	return &ListViewPreferencesEntry{
+		Columns:            DefaultListViewColumns(),
+		SortColumn:         "name",
+		IconSize:           16,
+		TextSize:           12,
+		ShowIconPreview:    true,
+		UseRelativeDates:   true,
+		CalculateAllSizes:  false,
+		ViewOptionsVersion: 1,
+		filename:           filename,
+		entryType:          entryType,
+	}
+}
+
+func decodeListViewPreferences(filename, entryType string, data []byte) (*ListViewPreferencesEntry, error) {
+	values, err := plistUnwrap(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s record for %q: %w", entryType, filename, err)
+	}
+	l := newListViewEntry(filename, entryType)
+	l.SortColumn = popString(values, "sortColumn", l.SortColumn)
+	l.IconSize = popFloat(values, "iconSize", l.IconSize)
+	l.TextSize = popFloat(values, "textSize", l.TextSize)
+	l.ShowIconPreview = popBool(values, "showIconPreview", l.ShowIconPreview)
+	l.UseRelativeDates = popBool(values, "useRelativeDates", l.UseRelativeDates)
+	l.CalculateAllSizes = popBool(values, "calculateAllSizes", l.CalculateAllSizes)
+	l.ViewOptionsVersion = popInt(values, "viewOptionsVersion", l.ViewOptionsVersion)
+
+	switch columns := values["columns"].(type) {
+	case []any:
+		l.Columns = l.Columns[:0]
+		for _, v := range columns {
+			c, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s column for %q", entryType, filename)
+			}
+			l.Columns = append(l.Columns, decodeListViewColumn(popString(c, "identifier", ""), c))
+		}
+		delete(values, "columns")
+	case map[string]any:
+		type indexed struct {
+			index  int
+			column ListViewColumn
+		}
+		var list []indexed
+		for id, v := range columns {
+			c, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s column %q for %q", entryType, id, filename)
+			}
+			list = append(list, indexed{popInt(c, "index", len(columns)), decodeListViewColumn(id, c)})
+		}
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].index != list[j].index {
+				return list[i].index < list[j].index
+			}
+			return list[i].column.Identifier < list[j].column.Identifier
+		})
+		l.Columns = l.Columns[:0]
+		for _, c := range list {
+			l.Columns = append(l.Columns, c.column)
+		}
+		delete(values, "columns")
+	}
+	if len(values) > 0 {
+		l.Extra = values
+	}
+	return l, nil
+}
+
+func decodeListViewColumn(id string, values map[string]any) ListViewColumn {
+	return ListViewColumn{
+		Identifier: id,
+		Width:      popInt(values, "width", 100),
+		Visible:    popBool(values, "visible", false),
+		Ascending:  popBool(values, "ascending", false),
+	}
+}
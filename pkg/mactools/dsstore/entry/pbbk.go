@@ -0,0 +1,46 @@
+package entry
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// PictureBookmarkEntry holds the bookmark data of the background picture.
+type PictureBookmarkEntry struct {
+	Bookmark []byte `json:"bookmark"`
+	filename string
+}
+
+func (p *PictureBookmarkEntry) Bytes() []byte {
+	blob := make([]byte, 4+len(p.Bookmark))
+	binary.BigEndian.PutUint32(blob[0:], uint32(len(p.Bookmark)))
+	copy(blob[4:], p.Bookmark)
+	return blob
+}
+
+func (p *PictureBookmarkEntry) Filename() string {
+	return p.filename
+}
+
+func (p *PictureBookmarkEntry) EntryType() string {
+	return TypePictureBookmark
+}
+
+func (p *PictureBookmarkEntry) DataType() string {
+	return "blob"
+}
+
+// NewPictureBookmarkEntry creates a new picture bookmark entry from bookmark data.
+func NewPictureBookmarkEntry(filename string, bookmark []byte) *PictureBookmarkEntry {
+	return &PictureBookmarkEntry{
+		Bookmark: bookmark,
+		filename: filename,
+	}
+}
+
+func decodePictureBookmark(filename string, data []byte) (*PictureBookmarkEntry, error) {
+	if len(data) < 4 || int(binary.BigEndian.Uint32(data)) != len(data)-4 {
+		return nil, fmt.Errorf("invalid %s record for %q", TypePictureBookmark, filename)
+	}
+	return NewPictureBookmarkEntry(filename, data[4:]), nil
+}
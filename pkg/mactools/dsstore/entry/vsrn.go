@@ -1,8 +1,43 @@
 package entry
 
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// VersionEntry is the vSrn record that Finder writes next to the legacy records.
+type VersionEntry struct {
+	Version  uint32 `json:"version"`
+	filename string
+}
+
+func (v *VersionEntry) Bytes() []byte {
+	return binary.BigEndian.AppendUint32(nil, v.Version)
+}
+
+func (v *VersionEntry) Filename() string {
+	return v.filename
+}
+
+func (v *VersionEntry) EntryType() string {
+	return TypeVersion
+}
+
+func (v *VersionEntry) DataType() string {
+	return "long"
+}
+
 // NewVersionEntry creates a new version entry.
-//func NewVersionEntry(filename string, version uint32) (*EntryItem, error) {
-//	blob := make([]byte, 4)
-//	binary.BigEndian.PutUint32(blob, version)
-//	return NewEntry(filename, TypeVersion, "long", blob), nil
-//}
+func NewVersionEntry(filename string, version uint32) *VersionEntry {
+	return &VersionEntry{
+		Version:  version,
+		filename: filename,
+	}
+}
+
+func decodeVersion(filename string, data []byte) (*VersionEntry, error) {
+	if len(data) != 4 {
+		return nil, fmt.Errorf("invalid %s record for %q", TypeVersion, filename)
+	}
+	return NewVersionEntry(filename, binary.BigEndian.Uint32(data)), nil
+}
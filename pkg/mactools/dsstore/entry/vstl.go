@@ -0,0 +1,49 @@
+package entry
+
+import "fmt"
+
+// View styles stored in vstl records.
+const (
+	ViewStyleIcon    = "icnv"
+	ViewStyleList    = "Nlsv"
+	ViewStyleColumn  = "clmv"
+	ViewStyleGallery = "glyv"
+)
+
+type ViewStyleEntry struct {
+	Style    string `json:"style"`
+	filename string
+}
+
+func (v *ViewStyleEntry) Bytes() []byte {
+	b := []byte(ViewStyleIcon)
+	copy(b, v.Style)
+	return b
+}
+
+func (v *ViewStyleEntry) Filename() string {
+	return v.filename
+}
+
+func (v *ViewStyleEntry) EntryType() string {
+	return TypeViewStyle
+}
+
+func (v *ViewStyleEntry) DataType() string {
+	return "type"
+}
+
+// NewViewStyleEntry creates a new view style entry.
+func NewViewStyleEntry(filename, style string) *ViewStyleEntry {
+	return &ViewStyleEntry{
+		Style:    style,
+		filename: filename,
+	}
+}
+
+func decodeViewStyle(filename string, data []byte) (*ViewStyleEntry, error) {
+	if len(data) != 4 {
+		return nil, fmt.Errorf("invalid %s record for %q", TypeViewStyle, filename)
+	}
+	return NewViewStyleEntry(filename, string(data)), nil
+}
@@ -0,0 +1,102 @@
+package dsstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Record is the serializable form of an entry, used to keep layouts in version control.
+type Record struct {
+	Filename  string          `json:"filename"`
+	EntryType string          `json:"entryType"`
+	DataType  string          `json:"dataType"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Records returns the entries in their serializable form.
+func (ds *DSStore) Records() ([]Record, error) {
+	records := make([]Record, 0, len(ds.Entries))
+	for _, e := range ds.Entries {
+		value, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s %q: %w", e.EntryType(), e.Filename(), err)
+		}
+		records = append(records, Record{
+			Filename:  e.Filename(),
+			EntryType: e.EntryType(),
+			DataType:  e.DataType(),
+			Value:     value,
+		})
+	}
+	return records, nil
+}
+
+func (ds *DSStore) MarshalJSON() ([]byte, error) {
+	records, err := ds.Records()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(records)
+}
+
+func (ds *DSStore) UnmarshalJSON(data []byte) error {
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+	entries := make([]entry.Entry, 0, len(records))
+	for _, r := range records {
+		if len(r.EntryType) != 4 || len(r.DataType) != 4 {
+			return fmt.Errorf("invalid record for %q: entryType and dataType must be 4 characters", r.Filename)
+		}
+		e, err := entry.Unmarshal(r.Filename, r.EntryType, r.DataType, r.Value)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+	}
+	ds.Entries = entries
+	return nil
+}
+
+// Unmarshal parses a JSON or YAML list of records.
+func Unmarshal(data []byte) (*DSStore, error) {
+	if !json.Valid(data) {
+		// YAML is converted to JSON so that both share the entry decoders
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	ds := NewDSStore()
+	if err := json.Unmarshal(data, ds); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// Open reads a .DS_Store file or a JSON/YAML list of records.
+func Open(path string) (*DSStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("\x00\x00\x00\x01Bud1")) {
+		return Decode(bytes.NewReader(data))
+	}
+	ds, err := Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return ds, nil
+}
@@ -0,0 +1,58 @@
+package dsstore
+
+import (
+	"math"
+
+	"github.com/ironpark/zapp/pkg/mactools/dsstore/entry"
+)
+
+// legacyEntries derives the records read by old Finder versions from the
+// modern bwsp and icvp records. Records that already exist are left alone.
+func (ds *DSStore) legacyEntries() []entry.Entry {
+	var entries []entry.Entry
+	add := func(e entry.Entry) {
+		if _, ok := ds.find(e.Filename(), e.EntryType()); !ok {
+			entries = append(entries, e)
+		}
+	}
+	for _, e := range ds.Entries {
+		switch e := e.(type) {
+		case *entry.WorkspaceSettingsEntry:
+			view := entry.ViewStyleIcon
+			if v, ok := ds.find(e.Filename(), entry.TypeViewStyle); ok {
+				view = v.(*entry.ViewStyleEntry).Style
+			}
+			add(entry.NewFinderWindowInfoEntry(e.Filename(), e.X, e.Y, e.Width, e.Height, view))
+		case *entry.IconViewPreferencesEntry:
+			icvo := entry.NewIconViewOptionsEntry(e.Filename(), uint16(e.IconSize))
+			if e.ArrangeBy == "grid" {
+				icvo.ArrangeBy = "grid"
+			}
+			icvo.LabelOnBottom = e.LabelOnBottom
+			add(icvo)
+
+			bkgd := entry.NewBackgroundEntry(e.Filename())
+			switch {
+			case e.BackgroundType == 1:
+				bkgd.Kind = entry.BackgroundColor
+				bkgd.Red = colorComponent(e.BackgroundColorRed)
+				bkgd.Green = colorComponent(e.BackgroundColorGreen)
+				bkgd.Blue = colorComponent(e.BackgroundColorBlue)
+			case e.BackgroundType == 2 && e.BackgroundImageAlias != nil:
+				bkgd.Kind = entry.BackgroundPicture
+				bkgd.PictureSize = uint32(len(e.BackgroundImageAlias))
+				add(entry.NewPictureEntry(e.Filename(), e.BackgroundImageAlias))
+			}
+			add(bkgd)
+		}
+	}
+	if len(entries) > 0 {
+		add(entry.NewVersionEntry(".", 1))
+	}
+	return entries
+}
+
+// colorComponent converts a 0-1 color component to the 16 bit range of QuickDraw colors.
+func colorComponent(v float64) uint16 {
+	return uint16(math.Round(math.Max(0, math.Min(1, v)) * 0xffff))
+}
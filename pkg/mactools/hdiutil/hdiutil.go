@@ -16,6 +16,8 @@ const (
 	UDCO         Format = "UDCO" // Compressed (ADC)
 	UDZO         Format = "UDZO" // Compressed (zlib)
 	UDBZ         Format = "UDBZ" // Compressed (bzip2)
+	ULFO         Format = "ULFO" // Compressed (lzfse), macOS 10.11+
+	ULMO         Format = "ULMO" // Compressed (lzma), macOS 10.15+
 	UFBI         Format = "UFBI" // Full block, single-partition image
 	UDTO         Format = "UDTO" // DVD/CD master
 	UDSP         Format = "UDSP" // Sparse disk image
@@ -31,6 +33,8 @@ var supportedFormats = map[Format]bool{
 	UDCO:         true,
 	UDZO:         true,
 	UDBZ:         true,
+	ULFO:         true,
+	ULMO:         true,
 	UFBI:         true,
 	UDTO:         true,
 	UDSP:         true,
@@ -48,12 +52,17 @@ func Create(ctx context.Context, volName, srcFolder string, format Format, outpu
 	return runCommand(ctx, "create", "-volname", volName, "-srcfolder", srcFolder, "-ov", "-format", string(format), outputFile)
 }
 
-// Convert converts a DMG file from one format to another
-func Convert(ctx context.Context, inputFile string, format Format, outputFile string) error {
+// Convert converts a DMG file from one format to another. imageKeys are
+// passed as -imagekey options, e.g. "zlib-level=9".
+func Convert(ctx context.Context, inputFile string, format Format, outputFile string, imageKeys ...string) error {
 	if !supportedFormats[format] {
 		return fmt.Errorf("unsupported format: %s", format)
 	}
-	return runCommand(ctx, "convert", inputFile, "-format", string(format), "-o", outputFile)
+	args := []string{inputFile, "-format", string(format)}
+	for _, key := range imageKeys {
+		args = append(args, "-imagekey", key)
+	}
+	return runCommand(ctx, "convert", append(args, "-o", outputFile)...)
 }
 
 // Attach mounts a DMG file
@@ -65,6 +74,11 @@ func Attach(ctx context.Context, dmgPath, mountPoint string) error {
 	return runCommand(ctx, "attach", args...)
 }
 
+// Verify checks the checksums of a DMG file
+func Verify(ctx context.Context, dmgPath string) error {
+	return runCommand(ctx, "verify", dmgPath)
+}
+
 // Detach unmounts a DMG file with retry
 func Detach(ctx context.Context, target string) error {
 	const (
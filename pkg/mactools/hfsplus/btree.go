@@ -0,0 +1,159 @@
+package hfsplus
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// B-tree node kinds.
+const (
+	kindLeaf   = -1
+	kindIndex  = 0
+	kindHeader = 1
+)
+
+// B-tree attributes.
+const (
+	bigKeys           = 0x2
+	variableIndexKeys = 0x4
+)
+
+// btreeRecord is a leaf record. key starts with its own length field.
+type btreeRecord struct {
+	key  []byte
+	data []byte
+}
+
+// btree is a B-tree laid out in nodes, node 0 being the header node.
+type btree struct {
+	maxKeyLength uint16
+	attributes   uint32
+	depth        uint16
+	root         uint32
+	leafRecords  uint32
+	firstLeaf    uint32
+	lastLeaf     uint32
+	nodes        [][]byte
+}
+
+// buildBTree packs records, sorted by key, into leaf nodes and builds the
+// index levels on top of them. Index records repeat the first key of each child.
+func buildBTree(records []btreeRecord, maxKeyLength uint16, attributes uint32) (*btree, error) {
+	t := &btree{maxKeyLength: maxKeyLength, attributes: attributes, leafRecords: uint32(len(records))}
+	t.nodes = [][]byte{nil} // the header node is written last
+	if len(records) == 0 {
+		return t, nil
+	}
+
+	level := make([][]byte, len(records))
+	keys := make([][]byte, len(records))
+	for i, r := range records {
+		level[i] = append(append([]byte{}, r.key...), r.data...)
+		keys[i] = r.key
+	}
+	kind, height := int8(kindLeaf), uint8(1)
+	for {
+		groups, err := packNodes(level)
+		if err != nil {
+			return nil, err
+		}
+		first := uint32(len(t.nodes))
+		var nextLevel, nextKeys [][]byte
+		for i, g := range groups {
+			id := first + uint32(i)
+			var fLink, bLink uint32
+			if i > 0 {
+				bLink = id - 1
+			}
+			if i < len(groups)-1 {
+				fLink = id + 1
+			}
+			t.nodes = append(t.nodes, writeNode(kind, height, fLink, bLink, level[g[0]:g[1]]))
+			nextLevel = append(nextLevel, binary.BigEndian.AppendUint32(append([]byte{}, keys[g[0]]...), id))
+			nextKeys = append(nextKeys, keys[g[0]])
+		}
+		if kind == kindLeaf {
+			t.firstLeaf, t.lastLeaf = first, first+uint32(len(groups))-1
+		}
+		t.depth = uint16(height)
+		if len(groups) == 1 {
+			t.root = first
+			return t, nil
+		}
+		level, keys = nextLevel, nextKeys
+		kind, height = kindIndex, height+1
+	}
+}
+
+// packNodes groups consecutive records into nodes. Every record takes its
+// size plus a 2 byte offset, and every node ends with the free space offset.
+func packNodes(records [][]byte) ([][2]int, error) {
+	const capacity = nodeSize - 14 - 2
+	var groups [][2]int
+	start, used := 0, 0
+	for i, r := range records {
+		size := len(r) + 2
+		if size > capacity {
+			return nil, fmt.Errorf("B-tree record of %d bytes does not fit in a node", len(r))
+		}
+		if used+size > capacity {
+			groups = append(groups, [2]int{start, i})
+			start, used = i, 0
+		}
+		used += size
+	}
+	return append(groups, [2]int{start, len(records)}), nil
+}
+
+func writeNode(kind int8, height uint8, fLink, bLink uint32, records [][]byte) []byte {
+	b := make([]byte, nodeSize)
+	binary.BigEndian.PutUint32(b[0:], fLink)
+	binary.BigEndian.PutUint32(b[4:], bLink)
+	b[8] = byte(kind)
+	b[9] = height
+	binary.BigEndian.PutUint16(b[10:], uint16(len(records)))
+	pos := 14
+	for i, r := range records {
+		binary.BigEndian.PutUint16(b[nodeSize-2*(i+1):], uint16(pos))
+		pos += copy(b[pos:], r)
+	}
+	binary.BigEndian.PutUint16(b[nodeSize-2*(len(records)+1):], uint16(pos))
+	return b
+}
+
+// encode returns the contents of the B-tree file, which holds totalNodes nodes.
+func (t *btree) encode(totalNodes uint32, clumpSize uint32) ([]byte, error) {
+	const mapSize = nodeSize - 14 - 106 - 128 - 8
+	if totalNodes > mapSize*8 {
+		return nil, fmt.Errorf("B-tree of %d nodes needs map nodes", totalNodes)
+	}
+	if int(totalNodes) < len(t.nodes) {
+		return nil, fmt.Errorf("B-tree needs %d nodes, only %d allocated", len(t.nodes), totalNodes)
+	}
+	header := make([]byte, 106)
+	binary.BigEndian.PutUint16(header[0:], t.depth)
+	binary.BigEndian.PutUint32(header[2:], t.root)
+	binary.BigEndian.PutUint32(header[6:], t.leafRecords)
+	binary.BigEndian.PutUint32(header[10:], t.firstLeaf)
+	binary.BigEndian.PutUint32(header[14:], t.lastLeaf)
+	binary.BigEndian.PutUint16(header[18:], nodeSize)
+	binary.BigEndian.PutUint16(header[20:], t.maxKeyLength)
+	binary.BigEndian.PutUint32(header[22:], totalNodes)
+	binary.BigEndian.PutUint32(header[26:], totalNodes-uint32(len(t.nodes)))
+	binary.BigEndian.PutUint32(header[32:], clumpSize)
+	header[36] = 0 // btreeType
+	header[37] = 0 // keyCompareType, only used by HFSX
+	binary.BigEndian.PutUint32(header[38:], t.attributes)
+
+	nodeMap := make([]byte, mapSize)
+	for i := range t.nodes {
+		nodeMap[i/8] |= 0x80 >> (i % 8)
+	}
+	t.nodes[0] = writeNode(kindHeader, 0, 0, 0, [][]byte{header, make([]byte, 128), nodeMap})
+
+	file := make([]byte, int(totalNodes)*nodeSize)
+	for i, n := range t.nodes {
+		copy(file[i*nodeSize:], n)
+	}
+	return file, nil
+}
@@ -0,0 +1,216 @@
+package hfsplus
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options configures a volume.
+type Options struct {
+	VolumeName string
+	// Created is the creation date of the volume, the current time when zero.
+	Created time.Time
+	// VolumeUUID identifies the volume, a random one is used when zero.
+	VolumeUUID [8]byte
+	// ModTime replaces the dates of all files and folders when set, so that
+	// the volume does not depend on when its source was copied.
+	ModTime time.Time
+	// NormalizePermissions stores 0755 for folders, symbolic links and
+	// executables and 0644 for other files instead of their permissions on disk.
+	NormalizePermissions bool
+}
+
+// node is a file, folder or symbolic link of the volume.
+type node struct {
+	id         uint32
+	parent     *node
+	name       string
+	mode       fs.FileMode
+	modified   time.Time
+	source     string // path of the file on disk
+	link       string // target of a symbolic link
+	finderInfo FinderInfo
+	rsrc       []byte
+	children   []*node
+
+	// set by the layout
+	dataSize   uint64
+	dataStart  uint32
+	dataBlocks uint32
+	rsrcStart  uint32
+	rsrcBlocks uint32
+}
+
+func (n *node) isDir() bool {
+	return n.mode.IsDir()
+}
+
+// Builder creates an HFS+ volume image from a directory tree. Catalog node
+// IDs are assigned when the tree is read, so they can be referred to (e.g.
+// by aliases) before the image is written.
+type Builder struct {
+	opts   Options
+	root   *node
+	nodes  map[string]*node
+	nextID uint32
+}
+
+// NewBuilder reads the directory tree at srcDir. File contents are read when
+// the image is written.
+func NewBuilder(srcDir string, opts Options) (*Builder, error) {
+	if opts.VolumeName == "" {
+		return nil, fmt.Errorf("volume name is required")
+	}
+	if opts.Created.IsZero() {
+		opts.Created = time.Now()
+	}
+	if opts.VolumeUUID == [8]byte{} {
+		if _, err := rand.Read(opts.VolumeUUID[:]); err != nil {
+			return nil, err
+		}
+	}
+	stat, err := os.Stat(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", srcDir)
+	}
+	b := &Builder{
+		opts:   opts,
+		nodes:  map[string]*node{},
+		nextID: FirstUserID,
+	}
+	b.root = b.newNode(RootFolderID, nil, opts.VolumeName, stat)
+	b.nodes["."] = b.root
+	if err := b.readDir(b.root, srcDir, "."); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Builder) readDir(parent *node, dir, rel string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	// sorted names give stable catalog node IDs
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		n := b.newNode(b.nextID, parent, entry.Name(), info)
+		b.nextID++
+		childRel := path.Join(rel, entry.Name())
+		switch {
+		case info.IsDir():
+		case info.Mode()&fs.ModeSymlink != 0:
+			if n.link, err = os.Readlink(p); err != nil {
+				return err
+			}
+			n.finderInfo = FinderInfo{Type: "slnk", Creator: "rhap"}
+		case info.Mode().IsRegular():
+			n.source = p
+		default:
+			return fmt.Errorf("unsupported file type: %s", p)
+		}
+		if len(catalogName(n.name)) > 255 {
+			return fmt.Errorf("file name is too long: %s", p)
+		}
+		for _, sibling := range parent.children {
+			if FastUnicodeCompare(sibling.name, n.name) == 0 {
+				return fmt.Errorf("%q and %q only differ in case", sibling.name, n.name)
+			}
+		}
+		parent.children = append(parent.children, n)
+		b.nodes[childRel] = n
+		if info.IsDir() {
+			if err := b.readDir(n, p, childRel); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Builder) newNode(id uint32, parent *node, name string, info fs.FileInfo) *node {
+	n := &node{id: id, parent: parent, name: name, mode: info.Mode(), modified: info.ModTime()}
+	if !b.opts.ModTime.IsZero() {
+		n.modified = b.opts.ModTime
+	}
+	if b.opts.NormalizePermissions {
+		perm := fs.FileMode(0644)
+		if info.IsDir() || info.Mode()&fs.ModeSymlink != 0 || info.Mode()&0111 != 0 {
+			perm = 0755
+		}
+		n.mode = info.Mode().Type() | perm
+	}
+	return n
+}
+
+func (b *Builder) lookup(relPath string) (*node, error) {
+	n, ok := b.nodes[path.Clean(strings.TrimPrefix(filepath.ToSlash(relPath), "/"))]
+	if !ok {
+		return nil, fmt.Errorf("%s is not part of the volume", relPath)
+	}
+	return n, nil
+}
+
+// FileID returns the catalog node ID of the file or folder at relPath,
+// relative to the root of the volume.
+func (b *Builder) FileID(relPath string) (uint32, error) {
+	n, err := b.lookup(relPath)
+	if err != nil {
+		return 0, err
+	}
+	return n.id, nil
+}
+
+// ModTime returns the modification date the file or folder at relPath has
+// in the volume.
+func (b *Builder) ModTime(relPath string) (time.Time, error) {
+	n, err := b.lookup(relPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return n.modified, nil
+}
+
+// Created returns the creation date of the volume.
+func (b *Builder) Created() time.Time {
+	return b.opts.Created
+}
+
+// SetFinderInfo sets the Finder information of the file or folder at relPath.
+// "." is the root folder, which holds the custom icon flag of the volume.
+func (b *Builder) SetFinderInfo(relPath string, info FinderInfo) error {
+	n, err := b.lookup(relPath)
+	if err != nil {
+		return err
+	}
+	n.finderInfo = info
+	return nil
+}
+
+// SetResourceFork sets the resource fork of the file at relPath.
+func (b *Builder) SetResourceFork(relPath string, data []byte) error {
+	n, err := b.lookup(relPath)
+	if err != nil {
+		return err
+	}
+	if n.isDir() {
+		return fmt.Errorf("%s is a folder and has no resource fork", relPath)
+	}
+	n.rsrc = data
+	return nil
+}
@@ -0,0 +1,132 @@
+package hfsplus
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"sort"
+)
+
+// BSD file types stored in the permissions of catalog records.
+const (
+	modeDir     = 0o040000
+	modeRegular = 0o100000
+	modeSymlink = 0o120000
+)
+
+// unknown owner and group, as used for removable media
+const unknownID = 99
+
+type catalogKey struct {
+	parentID uint32
+	name     []uint16
+}
+
+func (k catalogKey) bytes() []byte {
+	b := make([]byte, 8+2*len(k.name))
+	binary.BigEndian.PutUint16(b[0:], uint16(6+2*len(k.name)))
+	binary.BigEndian.PutUint32(b[2:], k.parentID)
+	binary.BigEndian.PutUint16(b[6:], uint16(len(k.name)))
+	for i, c := range k.name {
+		binary.BigEndian.PutUint16(b[8+i*2:], c)
+	}
+	return b
+}
+
+type catalogRecord struct {
+	key  catalogKey
+	data []byte
+}
+
+// catalogRecords returns the folder, file and thread records of the tree,
+// sorted by parent ID and case-insensitive name.
+func (b *Builder) catalogRecords() []btreeRecord {
+	var records []catalogRecord
+	var walk func(n *node)
+	walk = func(n *node) {
+		parentID := uint32(RootParentID)
+		if n.parent != nil {
+			parentID = n.parent.id
+		}
+		name := catalogName(n.name)
+		threadType := int16(recordFileThread)
+		if n.isDir() {
+			threadType = recordFolderThread
+			records = append(records, catalogRecord{catalogKey{parentID, name}, b.folderRecord(n)})
+		} else {
+			records = append(records, catalogRecord{catalogKey{parentID, name}, b.fileRecord(n)})
+		}
+		thread := make([]byte, 10+2*len(name))
+		binary.BigEndian.PutUint16(thread[0:], uint16(threadType))
+		binary.BigEndian.PutUint32(thread[4:], parentID)
+		binary.BigEndian.PutUint16(thread[8:], uint16(len(name)))
+		for i, c := range name {
+			binary.BigEndian.PutUint16(thread[10+i*2:], c)
+		}
+		records = append(records, catalogRecord{catalogKey{n.id, nil}, thread})
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(b.root)
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i].key, records[j].key
+		if a.parentID != b.parentID {
+			return a.parentID < b.parentID
+		}
+		return compareUnits(a.name, b.name) < 0
+	})
+	result := make([]btreeRecord, len(records))
+	for i, r := range records {
+		result[i] = btreeRecord{key: r.key.bytes(), data: r.data}
+	}
+	return result
+}
+
+// putCommon writes the fields shared by folder and file records: the dates,
+// the BSD permissions and the Finder information.
+func (b *Builder) putCommon(rec []byte, n *node, fileType uint16) {
+	binary.BigEndian.PutUint32(rec[8:], n.id)
+	date := hfsDate(n.modified)
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint32(rec[12+i*4:], date) // create, content, attribute and access dates
+	}
+	binary.BigEndian.PutUint32(rec[32:], unknownID)
+	binary.BigEndian.PutUint32(rec[36:], unknownID)
+	binary.BigEndian.PutUint16(rec[42:], fileType|uint16(n.mode.Perm()))
+	n.finderInfo.put(rec[48:64], fileType != modeDir)
+}
+
+func (b *Builder) folderRecord(n *node) []byte {
+	rec := make([]byte, 88)
+	binary.BigEndian.PutUint16(rec[0:], recordFolder)
+	binary.BigEndian.PutUint32(rec[4:], uint32(len(n.children)))
+	b.putCommon(rec, n, modeDir)
+	return rec
+}
+
+const threadExists = 0x0002
+
+func (b *Builder) fileRecord(n *node) []byte {
+	rec := make([]byte, 248)
+	binary.BigEndian.PutUint16(rec[0:], recordFile)
+	binary.BigEndian.PutUint16(rec[2:], threadExists)
+	fileType := uint16(modeRegular)
+	if n.mode&fs.ModeSymlink != 0 {
+		fileType = modeSymlink
+	}
+	b.putCommon(rec, n, fileType)
+	putFork(rec[88:], n.dataSize, n.dataStart, n.dataBlocks)
+	putFork(rec[168:], uint64(len(n.rsrc)), n.rsrcStart, n.rsrcBlocks)
+	return rec
+}
+
+// putFork writes HFSPlusForkData for a fork stored in a single extent.
+func putFork(b []byte, size uint64, start, blocks uint32) {
+	binary.BigEndian.PutUint64(b[0:], size)
+	binary.BigEndian.PutUint32(b[12:], blocks)
+	if blocks > 0 {
+		binary.BigEndian.PutUint32(b[16:], start)
+		binary.BigEndian.PutUint32(b[20:], blocks)
+	}
+}
@@ -0,0 +1,108 @@
+// Package hfsplus is a package for building HFS+ volume images from a
+// directory tree and reading them back, as described in Apple Technical
+// Note TN1150.
+package hfsplus
+
+import (
+	"encoding/binary"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	BlockSize = 4096
+	nodeSize  = 4096
+)
+
+// Reserved catalog node IDs.
+const (
+	RootParentID  = 1
+	RootFolderID  = 2
+	ExtentsFileID = 3
+	CatalogFileID = 4
+	FirstUserID   = 16
+)
+
+const (
+	catalogKeyLength = 516
+	extentsKeyLength = 10
+)
+
+// Catalog record types.
+const (
+	recordFolder       = 1
+	recordFile         = 2
+	recordFolderThread = 3
+	recordFileThread   = 4
+)
+
+// Finder flags of FinderInfo.
+const (
+	IsOnDesk      = 0x0001
+	HasBundle     = 0x2000
+	IsInvisible   = 0x4000
+	IsAlias       = 0x8000
+	HasCustomIcon = 0x0400
+	IsStationery  = 0x0800
+	NameLocked    = 0x1000
+	HasBeenInited = 0x0100
+)
+
+// FinderInfo is the part of the Finder information that is shared by files
+// and folders. Type and Creator only apply to files.
+type FinderInfo struct {
+	Type    string
+	Creator string
+	Flags   uint16
+	X, Y    int16
+}
+
+func (f FinderInfo) put(b []byte, isFile bool) {
+	if isFile {
+		copy(b[0:4], f.Type)
+		copy(b[4:8], f.Creator)
+	}
+	binary.BigEndian.PutUint16(b[8:], f.Flags)
+	binary.BigEndian.PutUint16(b[10:], uint16(f.Y))
+	binary.BigEndian.PutUint16(b[12:], uint16(f.X))
+}
+
+// HFSEpoch is the reference date of HFS+ dates.
+var HFSEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func hfsDate(t time.Time) uint32 {
+	if t.Before(HFSEpoch) {
+		return 0
+	}
+	return uint32(t.Sub(HFSEpoch) / time.Second)
+}
+
+// catalogName converts a POSIX file name to the form stored in the catalog:
+// decomposed UTF-16 in which ':' takes the place of '/'.
+func catalogName(name string) []uint16 {
+	u16 := utf16.Encode([]rune(decompose(name)))
+	for i, c := range u16 {
+		if c == ':' {
+			u16[i] = '/'
+		}
+	}
+	return u16
+}
+
+// decompose applies NFD except to the ranges HFS+ leaves composed
+// (U+2000–U+2FFF, U+F900–U+FAFF and U+2F800–U+2FAFF).
+func decompose(s string) string {
+	var out, segment []rune
+	for _, r := range s {
+		if (r >= 0x2000 && r <= 0x2fff) || (r >= 0xf900 && r <= 0xfaff) || (r >= 0x2f800 && r <= 0x2faff) {
+			out = append(out, []rune(norm.NFD.String(string(segment)))...)
+			out = append(out, r)
+			segment = segment[:0]
+			continue
+		}
+		segment = append(segment, r)
+	}
+	return string(append(out, []rune(norm.NFD.String(string(segment)))...))
+}
@@ -0,0 +1,292 @@
+package hfsplus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestFastUnicodeCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int // sign of the result
+	}{
+		{"abc", "ABC", 0},
+		{"a", "b", -1},
+		{"ab", "a", 1},
+		{"Ⅻ", "ⅻ", 0},
+		{"ＡＢ", "ａｂ", 0},
+		{"a‍b", "ab", 0},
+		{"é", "é", 0},
+		{"한글", "한글.app", -1},
+		{"가", "각", -1},
+		// unlike code point order, U+FF61 sorts after the surrogate pair of U+1F600
+		{"｡", "\U0001f600", 1},
+		{"a\x00", "a", 1},
+	}
+	for _, tt := range tests {
+		got := FastUnicodeCompare(tt.a, tt.b)
+		if (got < 0 && tt.want >= 0) || (got > 0 && tt.want <= 0) || (got == 0 && tt.want != 0) {
+			t.Errorf("compare(%q, %q) = %d, want sign %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".background"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".background", "bg.png"), make([]byte, 5000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/Applications", filepath.Join(dir, "Applications")); err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	b, err := NewBuilder(dir, Options{VolumeName: "Test", Created: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetFinderInfo(".", FinderInfo{Flags: HasCustomIcon}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetResourceFork("hello.txt", []byte("rsrc")); err != nil {
+		t.Fatal(err)
+	}
+	helloID, err := b.FileID("hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := buf.Bytes()
+	if n != int64(len(image)) || len(image)%BlockSize != 0 {
+		t.Fatalf("wrote %d bytes, image is %d bytes", n, len(image))
+	}
+	header := image[1024:1536]
+	if !bytes.Equal(header, image[len(image)-1024:len(image)-512]) {
+		t.Error("alternate volume header differs")
+	}
+	if string(header[0:2]) != "H+" {
+		t.Fatalf("signature = %q", header[0:2])
+	}
+	if got := binary.BigEndian.Uint32(header[16:]); got != hfsDate(created) {
+		t.Errorf("create date = %d, want %d", got, hfsDate(created))
+	}
+	if files, folders := binary.BigEndian.Uint32(header[32:]), binary.BigEndian.Uint32(header[36:]); files != 3 || folders != 1 {
+		t.Errorf("files = %d, folders = %d", files, folders)
+	}
+	if total := binary.BigEndian.Uint32(header[44:]); int(total)*BlockSize != len(image) {
+		t.Errorf("total blocks = %d", total)
+	}
+
+	// walk the catalog leaf nodes
+	catalogStart := int(binary.BigEndian.Uint32(header[272+16:])) * BlockSize
+	catalog := image[catalogStart:]
+	leaf := binary.BigEndian.Uint32(catalog[14+10:])
+	records := map[string][]byte{}
+	var names []string
+	for leaf != 0 {
+		node := catalog[int(leaf)*nodeSize:][:nodeSize]
+		if int8(node[8]) != kindLeaf {
+			t.Fatalf("node %d is not a leaf", leaf)
+		}
+		count := int(binary.BigEndian.Uint16(node[10:]))
+		for i := 0; i < count; i++ {
+			off := int(binary.BigEndian.Uint16(node[nodeSize-2*(i+1):]))
+			keyLength := int(binary.BigEndian.Uint16(node[off:]))
+			nameLength := int(binary.BigEndian.Uint16(node[off+6:]))
+			u16 := make([]uint16, nameLength)
+			for j := range u16 {
+				u16[j] = binary.BigEndian.Uint16(node[off+8+j*2:])
+			}
+			name := string(utf16.Decode(u16))
+			names = append(names, name)
+			records[name] = node[off+2+keyLength:]
+		}
+		leaf = binary.BigEndian.Uint32(node[0:])
+	}
+	// records are sorted by parent ID, thread records have an empty name
+	want := []string{"Test", "", ".background", "Applications", "hello.txt", "", "bg.png", "", "", ""}
+	if len(names) != len(want) {
+		t.Fatalf("catalog names = %q, want %q", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("catalog names = %q, want %q", names, want)
+		}
+	}
+
+	root := records["Test"]
+	if binary.BigEndian.Uint16(root[0:]) != recordFolder || binary.BigEndian.Uint32(root[8:]) != RootFolderID {
+		t.Error("root folder record is wrong")
+	}
+	if binary.BigEndian.Uint16(root[48+8:]) != HasCustomIcon {
+		t.Error("root folder has no custom icon flag")
+	}
+
+	hello := records["hello.txt"]
+	if binary.BigEndian.Uint32(hello[8:]) != helloID {
+		t.Errorf("hello.txt ID = %d, want %d", binary.BigEndian.Uint32(hello[8:]), helloID)
+	}
+	readFork := func(fork []byte) string {
+		size := binary.BigEndian.Uint64(fork[0:])
+		start := int(binary.BigEndian.Uint32(fork[16:])) * BlockSize
+		return string(image[start : start+int(size)])
+	}
+	if got := readFork(hello[88:]); got != "hello" {
+		t.Errorf("data fork = %q", got)
+	}
+	if got := readFork(hello[168:]); got != "rsrc" {
+		t.Errorf("resource fork = %q", got)
+	}
+
+	link := records["Applications"]
+	if got := binary.BigEndian.Uint16(link[42:]) & 0o170000; got != modeSymlink {
+		t.Errorf("symlink mode = %o", got)
+	}
+	if string(link[48:56]) != "slnkrhap" {
+		t.Errorf("symlink type and creator = %q", link[48:56])
+	}
+	if got := readFork(link[88:]); got != "/Applications" {
+		t.Errorf("symlink target = %q", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "App.app", "Contents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	plist := bytes.Repeat([]byte("<plist/>"), 1000)
+	if err := os.WriteFile(filepath.Join(dir, "App.app", "Contents", "Info.plist"), plist, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".VolumeIcon.icns"), []byte("icns"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/Applications", filepath.Join(dir, "Applications")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBuilder(dir, Options{VolumeName: "Reader"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetFinderInfo(".VolumeIcon.icns", FinderInfo{Creator: "icnC"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetResourceFork(".VolumeIcon.icns", []byte("resource")); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := Open(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "Reader" || v.Files != 3 || v.Folders != 2 {
+		t.Errorf("volume = %q with %d files and %d folders", v.Name, v.Files, v.Folders)
+	}
+	data, err := v.ReadFile("app.APP/Contents/Info.plist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, plist) {
+		t.Error("Info.plist differs")
+	}
+	icon, err := v.Lookup(".VolumeIcon.icns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icon.FinderInfo.Creator != "icnC" {
+		t.Errorf("creator = %q", icon.FinderInfo.Creator)
+	}
+	r, err := v.ResourceFork(icon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsrc := make([]byte, icon.RsrcSize)
+	if _, err := r.ReadAt(rsrc, 0); err != nil || string(rsrc) != "resource" {
+		t.Errorf("resource fork = %q, %v", rsrc, err)
+	}
+	link, err := v.Lookup("Applications")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, err := v.Readlink(link); err != nil || target != "/Applications" {
+		t.Errorf("link target = %q, %v", target, err)
+	}
+	var names []string
+	if err := v.Walk(func(name string, f *File) error {
+		names = append(names, name)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 6 {
+		t.Errorf("walked %q", names)
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	opts := Options{
+		VolumeName:           "Same",
+		Created:              time.Unix(1700000000, 0),
+		VolumeUUID:           [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+		ModTime:              time.Unix(1700000000, 0),
+		NormalizePermissions: true,
+	}
+	var images [2][]byte
+	for i, perm := range []os.FileMode{0600, 0664} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "file"), []byte("data"), perm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(filepath.Join(dir, "file"), perm); err != nil {
+			t.Fatal(err)
+		}
+		modified := time.Now().Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, "file"), modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewBuilder(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := b.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		images[i] = buf.Bytes()
+	}
+	if !bytes.Equal(images[0], images[1]) {
+		t.Fatal("volumes differ")
+	}
+	v, err := Open(bytes.NewReader(images[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := v.Lookup("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Mode.Perm() != 0644 || !f.Modified.Equal(opts.ModTime) {
+		t.Errorf("file has mode %v and date %v", f.Mode, f.Modified)
+	}
+}
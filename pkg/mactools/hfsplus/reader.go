@@ -0,0 +1,393 @@
+package hfsplus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// File is a file, folder or symbolic link read from the catalog of a volume.
+type File struct {
+	ID         uint32
+	ParentID   uint32
+	Name       string
+	Mode       fs.FileMode
+	Created    time.Time
+	Modified   time.Time
+	FinderInfo FinderInfo
+	DataSize   int64
+	RsrcSize   int64
+	Children   []*File
+
+	data, rsrc fork
+}
+
+func (f *File) IsDir() bool {
+	return f.Mode.IsDir()
+}
+
+// extent is a run of allocation blocks.
+type extent struct {
+	start, count uint32
+}
+
+type fork struct {
+	size    int64
+	blocks  uint32
+	extents []extent
+}
+
+func readFork(b []byte) fork {
+	f := fork{size: int64(binary.BigEndian.Uint64(b[0:])), blocks: binary.BigEndian.Uint32(b[12:])}
+	for i := 0; i < 8; i++ {
+		e := extent{binary.BigEndian.Uint32(b[16+i*8:]), binary.BigEndian.Uint32(b[20+i*8:])}
+		if e.count == 0 {
+			break
+		}
+		f.extents = append(f.extents, e)
+	}
+	return f
+}
+
+// Volume is an HFS+ volume opened for reading. The whole catalog is read
+// when the volume is opened.
+type Volume struct {
+	Name      string
+	Created   time.Time
+	BlockSize uint32
+	Files     int
+	Folders   int
+
+	r       io.ReaderAt
+	root    *File
+	byID    map[uint32]*File
+	extents fork
+}
+
+// Open reads the volume header and the catalog of the volume in r.
+func Open(r io.ReaderAt) (*Volume, error) {
+	h := make([]byte, 512)
+	if _, err := r.ReadAt(h, volumeHeaderOffset); err != nil {
+		return nil, fmt.Errorf("failed to read volume header: %w", err)
+	}
+	if sig := string(h[0:2]); sig != "H+" && sig != "HX" {
+		return nil, fmt.Errorf("not an HFS+ volume (signature %q)", sig)
+	}
+	v := &Volume{
+		r:         r,
+		Created:   fromHFSDate(binary.BigEndian.Uint32(h[16:])),
+		Files:     int(binary.BigEndian.Uint32(h[32:])),
+		Folders:   int(binary.BigEndian.Uint32(h[36:])),
+		BlockSize: binary.BigEndian.Uint32(h[40:]),
+		byID:      map[uint32]*File{},
+		extents:   readFork(h[192:]),
+	}
+	if v.BlockSize == 0 || v.BlockSize%512 != 0 {
+		return nil, fmt.Errorf("invalid block size %d", v.BlockSize)
+	}
+	catalog, err := v.openFork(CatalogFileID, 0, readFork(h[272:]))
+	if err != nil {
+		return nil, err
+	}
+	if err := v.readCatalog(catalog); err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	return v, nil
+}
+
+func fromHFSDate(d uint32) time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return HFSEpoch.Add(time.Duration(d) * time.Second)
+}
+
+// openFork returns a reader of a fork, looking up the extents that do not
+// fit in the catalog record in the extents overflow file.
+func (v *Volume) openFork(fileID uint32, forkType byte, f fork) (*io.SectionReader, error) {
+	found := uint32(0)
+	for _, e := range f.extents {
+		found += e.count
+	}
+	if found < f.blocks {
+		if fileID == ExtentsFileID {
+			return nil, fmt.Errorf("extents file is fragmented")
+		}
+		overflow, err := v.overflowExtents(fileID, forkType, found)
+		if err != nil {
+			return nil, err
+		}
+		f.extents = append(f.extents, overflow...)
+	}
+	return io.NewSectionReader(&forkReader{r: v.r, blockSize: int64(v.BlockSize), extents: f.extents}, 0, f.size), nil
+}
+
+func (v *Volume) overflowExtents(fileID uint32, forkType byte, startBlock uint32) ([]extent, error) {
+	extents, err := v.openFork(ExtentsFileID, 0, v.extents)
+	if err != nil {
+		return nil, err
+	}
+	var result []extent
+	err = walkLeaves(extents, func(key, data []byte) error {
+		if len(key) < 12 || key[2] != forkType || binary.BigEndian.Uint32(key[4:]) != fileID {
+			return nil
+		}
+		if binary.BigEndian.Uint32(key[8:]) < startBlock {
+			return nil
+		}
+		for i := 0; i < 8 && 8*i+8 <= len(data); i++ {
+			e := extent{binary.BigEndian.Uint32(data[i*8:]), binary.BigEndian.Uint32(data[i*8+4:])}
+			if e.count > 0 {
+				result = append(result, e)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+type forkReader struct {
+	r         io.ReaderAt
+	blockSize int64
+	extents   []extent
+}
+
+func (f *forkReader) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	logical := int64(0)
+	for _, e := range f.extents {
+		size := int64(e.count) * f.blockSize
+		if off+int64(n) < logical+size {
+			for n < len(p) && off+int64(n) < logical+size {
+				within := off + int64(n) - logical
+				chunk := p[n:min(len(p), n+int(size-within))]
+				m, err := f.r.ReadAt(chunk, int64(e.start)*f.blockSize+within)
+				n += m
+				if err != nil {
+					return n, err
+				}
+			}
+			if n == len(p) {
+				return n, nil
+			}
+		}
+		logical += size
+	}
+	return n, io.EOF
+}
+
+// walkLeaves calls fn with the key (including its length) and the data of
+// every leaf record of the B-tree, in key order.
+func walkLeaves(tree io.ReaderAt, fn func(key, data []byte) error) error {
+	header := make([]byte, 14+106)
+	if _, err := tree.ReadAt(header, 0); err != nil {
+		return err
+	}
+	if int8(header[8]) != kindHeader {
+		return fmt.Errorf("invalid B-tree header node")
+	}
+	nodeSize := int64(binary.BigEndian.Uint16(header[14+18:]))
+	totalNodes := binary.BigEndian.Uint32(header[14+22:])
+	if nodeSize < 512 {
+		return fmt.Errorf("invalid B-tree node size %d", nodeSize)
+	}
+	node := make([]byte, nodeSize)
+	for id, visited := binary.BigEndian.Uint32(header[14+10:]), uint32(0); id != 0; visited++ {
+		if id >= totalNodes || visited >= totalNodes {
+			return fmt.Errorf("invalid B-tree node link %d", id)
+		}
+		if _, err := tree.ReadAt(node, int64(id)*nodeSize); err != nil {
+			return err
+		}
+		if int8(node[8]) != kindLeaf {
+			return fmt.Errorf("B-tree node %d is not a leaf", id)
+		}
+		count := int(binary.BigEndian.Uint16(node[10:]))
+		for i := 0; i < count; i++ {
+			start := int(binary.BigEndian.Uint16(node[nodeSize-2*int64(i+1):]))
+			end := int(binary.BigEndian.Uint16(node[nodeSize-2*int64(i+2):]))
+			if start < 14 || end > int(nodeSize) || start+2 > end {
+				return fmt.Errorf("invalid record %d in B-tree node %d", i, id)
+			}
+			keyLength := 2 + int(binary.BigEndian.Uint16(node[start:]))
+			if start+keyLength > end {
+				return fmt.Errorf("invalid key in B-tree node %d", id)
+			}
+			record := node[start:end]
+			if err := fn(record[:keyLength], record[keyLength:]); err != nil {
+				return err
+			}
+		}
+		id = binary.BigEndian.Uint32(node[0:])
+	}
+	return nil
+}
+
+func (v *Volume) readCatalog(catalog io.ReaderAt) error {
+	var files []*File
+	err := walkLeaves(catalog, func(key, data []byte) error {
+		if len(key) < 8 || len(data) < 2 {
+			return fmt.Errorf("invalid catalog record")
+		}
+		parentID := binary.BigEndian.Uint32(key[2:])
+		nameLength := int(binary.BigEndian.Uint16(key[6:]))
+		if len(key) < 8+2*nameLength {
+			return fmt.Errorf("invalid catalog key")
+		}
+		u16 := make([]uint16, nameLength)
+		for i := range u16 {
+			u16[i] = binary.BigEndian.Uint16(key[8+i*2:])
+			if u16[i] == '/' {
+				u16[i] = ':'
+			}
+		}
+		f := &File{ParentID: parentID, Name: string(utf16.Decode(u16))}
+		switch binary.BigEndian.Uint16(data) {
+		case recordFolder:
+			if len(data) < 88 {
+				return fmt.Errorf("invalid folder record")
+			}
+			f.readCommon(data, false)
+			f.Mode |= fs.ModeDir
+		case recordFile:
+			if len(data) < 248 {
+				return fmt.Errorf("invalid file record")
+			}
+			f.readCommon(data, true)
+			f.data, f.rsrc = readFork(data[88:]), readFork(data[168:])
+			f.DataSize, f.RsrcSize = f.data.size, f.rsrc.size
+		default:
+			return nil // thread records
+		}
+		v.byID[f.ID] = f
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// records are sorted by parent and name, so are the children
+	for _, f := range files {
+		if f.ID == RootFolderID {
+			v.root = f
+			v.Name = f.Name
+			continue
+		}
+		if parent, ok := v.byID[f.ParentID]; ok {
+			parent.Children = append(parent.Children, f)
+		}
+	}
+	if v.root == nil {
+		return fmt.Errorf("root folder not found")
+	}
+	return nil
+}
+
+func (f *File) readCommon(data []byte, isFile bool) {
+	f.ID = binary.BigEndian.Uint32(data[8:])
+	f.Created = fromHFSDate(binary.BigEndian.Uint32(data[12:]))
+	f.Modified = fromHFSDate(binary.BigEndian.Uint32(data[16:]))
+	mode := binary.BigEndian.Uint16(data[42:])
+	f.Mode = fs.FileMode(mode & 0o777)
+	if mode&0o170000 == modeSymlink {
+		f.Mode |= fs.ModeSymlink
+	}
+	info := data[48:64]
+	if isFile {
+		f.FinderInfo.Type = strings.TrimRight(string(info[0:4]), "\x00")
+		f.FinderInfo.Creator = strings.TrimRight(string(info[4:8]), "\x00")
+	}
+	f.FinderInfo.Flags = binary.BigEndian.Uint16(info[8:])
+	f.FinderInfo.Y = int16(binary.BigEndian.Uint16(info[10:]))
+	f.FinderInfo.X = int16(binary.BigEndian.Uint16(info[12:]))
+}
+
+// Root returns the root folder of the volume.
+func (v *Volume) Root() *File {
+	return v.root
+}
+
+// Lookup returns the file at name, a slash separated path relative to the
+// root of the volume. Names are compared like HFS+ does, ignoring case.
+func (v *Volume) Lookup(name string) (*File, error) {
+	f := v.root
+	for _, part := range strings.Split(path.Clean("/"+name), "/") {
+		if part == "" {
+			continue
+		}
+		var next *File
+		for _, child := range f.Children {
+			if FastUnicodeCompare(child.Name, part) == 0 {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+		}
+		f = next
+	}
+	return f, nil
+}
+
+// Walk calls fn for every file of the volume, the parents before their
+// children. Paths are relative to the root, which is ".".
+func (v *Volume) Walk(fn func(name string, f *File) error) error {
+	var walk func(name string, f *File) error
+	walk = func(name string, f *File) error {
+		if err := fn(name, f); err != nil {
+			return err
+		}
+		for _, child := range f.Children {
+			if err := walk(path.Join(name, child.Name), child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(".", v.root)
+}
+
+// Data returns a reader of the data fork of f.
+func (v *Volume) Data(f *File) (*io.SectionReader, error) {
+	return v.openFork(f.ID, 0x00, f.data)
+}
+
+// ResourceFork returns a reader of the resource fork of f.
+func (v *Volume) ResourceFork(f *File) (*io.SectionReader, error) {
+	return v.openFork(f.ID, 0xff, f.rsrc)
+}
+
+// ReadFile returns the data fork of the file at name.
+func (v *Volume) ReadFile(name string) ([]byte, error) {
+	f, err := v.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if f.IsDir() {
+		return nil, fmt.Errorf("%s is a folder", name)
+	}
+	r, err := v.Data(f)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Readlink returns the target of a symbolic link.
+func (v *Volume) Readlink(f *File) (string, error) {
+	if f.Mode&fs.ModeSymlink == 0 {
+		return "", fmt.Errorf("%s is not a symbolic link", f.Name)
+	}
+	r, err := v.Data(f)
+	if err != nil {
+		return "", err
+	}
+	target, err := io.ReadAll(r)
+	return string(target), err
+}
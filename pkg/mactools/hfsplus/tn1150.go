@@ -1,7 +1,13 @@
-package dsstore
+package hfsplus
+
+import (
+	"unicode/utf16"
+
+	"golang.org/x/text/unicode/norm"
+)
 
 // lowerCaseMap: Mapping uppercase to lowercase
-var lowerCaseMap = map[rune]rune{
+var lowerCaseMap = map[uint16]uint16{
 	0x0000: 0xffff, 0x0041: 0x0061, 0x0042: 0x0062, 0x0043: 0x0063, 0x0044: 0x0064, 0x0045: 0x0065, 0x0046: 0x0066,
 	0x0047: 0x0067, 0x0048: 0x0068, 0x0049: 0x0069, 0x004a: 0x006a, 0x004b: 0x006b, 0x004c: 0x006c, 0x004d: 0x006d,
 	0x004e: 0x006e, 0x004f: 0x006f, 0x0050: 0x0070, 0x0051: 0x0071, 0x0052: 0x0072, 0x0053: 0x0073, 0x0054: 0x0074,
@@ -42,34 +48,47 @@ var lowerCaseMap = map[rune]rune{
 	0x10ba: 0x10ea, 0x10bb: 0x10eb, 0x10bc: 0x10ec, 0x10bd: 0x10ed, 0x10be: 0x10ee, 0x10bf: 0x10ef, 0x10c0: 0x10f0,
 	0x10c1: 0x10f1, 0x10c2: 0x10f2, 0x10c3: 0x10f3, 0x10c4: 0x10f4, 0x10c5: 0x10f5, 0x200c: 0x0000, 0x200d: 0x0000,
 	0x200e: 0x0000, 0x200f: 0x0000, 0x202a: 0x0000, 0x202b: 0x0000, 0x202c: 0x0000, 0x202d: 0x0000, 0x202e: 0x0000,
-	0x206a: 0x0000, 0x206b: 0x0000, 0x206c: 0x0000, 0x206d: 0x0000, 0x206e: 0x0000, 0x206f: 0x0000, 0x2160: 0x0010,
-	0x2161: 0x0010, 0x2162: 0x0010, 0x2163: 0x0010, 0x2164: 0x0010, 0x2165: 0x0010, 0x2166: 0x0010, 0x2167: 0x0010,
-	0x2168: 0x0010, 0x2169: 0x0010, 0x216a: 0x0010, 0x216b: 0x0010, 0x216c: 0x0010, 0x216d: 0x0010, 0x216e: 0x0010,
-	0x216f: 0x0010, 0xfeff: 0x0000, 0xff21: 0x0020, 0xff22: 0x0020, 0xff23: 0x0020, 0xff24: 0x0020, 0xff25: 0x0020,
-	0xff26: 0x0020, 0xff27: 0x0020, 0xff28: 0x0020, 0xff29: 0x0020, 0xff2a: 0x0020, 0xff2b: 0x0020, 0xff2c: 0x0020,
-	0xff2d: 0x0020, 0xff2e: 0x0020, 0xff2f: 0x0020, 0xff30: 0x0020, 0xff31: 0x0020, 0xff32: 0x0020, 0xff33: 0x0020,
-	0xff34: 0x0020, 0xff35: 0x0020, 0xff36: 0x0020, 0xff37: 0x0020, 0xff38: 0x0020, 0xff39: 0x0020, 0xff3a: 0x0020,
+	0x206a: 0x0000, 0x206b: 0x0000, 0x206c: 0x0000, 0x206d: 0x0000, 0x206e: 0x0000, 0x206f: 0x0000, 0x2160: 0x2170,
+	0x2161: 0x2171, 0x2162: 0x2172, 0x2163: 0x2173, 0x2164: 0x2174, 0x2165: 0x2175, 0x2166: 0x2176, 0x2167: 0x2177,
+	0x2168: 0x2178, 0x2169: 0x2179, 0x216a: 0x217a, 0x216b: 0x217b, 0x216c: 0x217c, 0x216d: 0x217d, 0x216e: 0x217e,
+	0x216f: 0x217f, 0xfeff: 0x0000, 0xff21: 0xff41, 0xff22: 0xff42, 0xff23: 0xff43, 0xff24: 0xff44, 0xff25: 0xff45,
+	0xff26: 0xff46, 0xff27: 0xff47, 0xff28: 0xff48, 0xff29: 0xff49, 0xff2a: 0xff4a, 0xff2b: 0xff4b, 0xff2c: 0xff4c,
+	0xff2d: 0xff4d, 0xff2e: 0xff4e, 0xff2f: 0xff4f, 0xff30: 0xff50, 0xff31: 0xff51, 0xff32: 0xff52, 0xff33: 0xff53,
+	0xff34: 0xff54, 0xff35: 0xff55, 0xff36: 0xff56, 0xff37: 0xff57, 0xff38: 0xff58, 0xff39: 0xff59, 0xff3a: 0xff5a,
 }
 
-func hfsPlusFastUnicodeCompare(str1, str2 string) int {
-	minLen := len(str1)
-	if len(str2) < minLen {
-		minLen = len(str2)
-	}
+// FastUnicodeCompare orders names the way HFS+ catalogs and Finder do:
+// both are compared as NFD-decomposed UTF-16 code units, case-folded with the
+// TN1150 table. Ignorable characters fold to zero and are skipped.
+func FastUnicodeCompare(str1, str2 string) int {
+	return compareUnits(utf16.Encode([]rune(norm.NFD.String(str1))), utf16.Encode([]rune(norm.NFD.String(str2))))
+}
 
-	for i := 0; i < minLen; i++ {
-		c1 := rune(str1[i])
-		c2 := rune(str2[i])
-		if lc, exists := lowerCaseMap[c1]; exists {
-			c1 = lc
+// compareUnits is FastUnicodeCompare for names that are already decomposed.
+func compareUnits(s1, s2 []uint16) int {
+	i, j := 0, 0
+	for {
+		var c1, c2 uint16
+		for c1 == 0 && i < len(s1) {
+			c1 = foldCase(s1[i])
+			i++
 		}
-		if lc, exists := lowerCaseMap[c2]; exists {
-			c2 = lc
+		for c2 == 0 && j < len(s2) {
+			c2 = foldCase(s2[j])
+			j++
 		}
 		if c1 != c2 {
-			return int(c1 - c2)
+			return int(c1) - int(c2)
+		}
+		if c1 == 0 {
+			return 0
 		}
 	}
+}
 
-	return len(str1) - len(str2)
+func foldCase(c uint16) uint16 {
+	if lc, exists := lowerCaseMap[c]; exists {
+		return lc
+	}
+	return c
 }
@@ -0,0 +1,264 @@
+package hfsplus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	volumeHeaderOffset = 1024
+	clumpSize          = 64 * 1024
+	extentsNodes       = 4
+	unmounted          = 1 << 8
+)
+
+// layout is the position of the special files in allocation blocks.
+type layout struct {
+	totalBlocks    uint32
+	bitmapBlocks   uint32
+	extentsStart   uint32
+	catalogStart   uint32
+	catalogBlocks  uint32
+	usedBlocks     uint32 // blocks before the free space
+	files, folders uint32
+	catalog        []byte
+	extents        []byte
+}
+
+func blocks(size uint64) uint32 {
+	return uint32((size + BlockSize - 1) / BlockSize)
+}
+
+// layout places the allocation bitmap, the extents and catalog files and
+// then the forks of every file, each in a single extent.
+func (b *Builder) layout() (*layout, error) {
+	l := &layout{}
+	var forks []*node
+	var walk func(n *node) error
+	walk = func(n *node) error {
+		if n.isDir() {
+			if n != b.root {
+				l.folders++
+			}
+			for _, child := range n.children {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		l.files++
+		switch {
+		case n.link != "":
+			n.dataSize = uint64(len(n.link))
+		default:
+			info, err := os.Stat(n.source)
+			if err != nil {
+				return err
+			}
+			n.dataSize = uint64(info.Size())
+		}
+		forks = append(forks, n)
+		return nil
+	}
+	if err := walk(b.root); err != nil {
+		return nil, err
+	}
+
+	dataBlocks := uint32(0)
+	for _, n := range forks {
+		dataBlocks += blocks(n.dataSize) + blocks(uint64(len(n.rsrc)))
+	}
+
+	records := b.catalogRecords()
+	catalog, err := buildBTree(records, catalogKeyLength, bigKeys|variableIndexKeys)
+	if err != nil {
+		return nil, err
+	}
+	// leave room for a few more catalog nodes, as hdiutil does
+	catalogNodes := uint32(len(catalog.nodes)) + 8
+	l.catalogBlocks = catalogNodes * nodeSize / BlockSize
+
+	// the bitmap has to cover itself, iterate until its size is stable
+	fixed := 1 + extentsNodes*nodeSize/BlockSize + l.catalogBlocks + dataBlocks + 1
+	for {
+		total := fixed + l.bitmapBlocks
+		need := blocks(uint64(total+7) / 8)
+		if need == l.bitmapBlocks {
+			l.totalBlocks = total
+			break
+		}
+		l.bitmapBlocks = need
+	}
+	l.extentsStart = 1 + l.bitmapBlocks
+	l.catalogStart = l.extentsStart + extentsNodes*nodeSize/BlockSize
+
+	next := l.catalogStart + l.catalogBlocks
+	for _, n := range forks {
+		n.dataStart, n.dataBlocks = next, blocks(n.dataSize)
+		next += n.dataBlocks
+		n.rsrcStart, n.rsrcBlocks = next, blocks(uint64(len(n.rsrc)))
+		next += n.rsrcBlocks
+	}
+	l.usedBlocks = next
+
+	// the records hold the fork positions, so encode them after the layout
+	if catalog, err = buildBTree(b.catalogRecords(), catalogKeyLength, bigKeys|variableIndexKeys); err != nil {
+		return nil, err
+	}
+	if l.catalog, err = catalog.encode(catalogNodes, clumpSize); err != nil {
+		return nil, err
+	}
+	extents, err := buildBTree(nil, extentsKeyLength, bigKeys)
+	if err != nil {
+		return nil, err
+	}
+	if l.extents, err = extents.encode(extentsNodes, clumpSize); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (b *Builder) volumeHeader(l *layout) []byte {
+	h := make([]byte, 512)
+	copy(h[0:], "H+")
+	binary.BigEndian.PutUint16(h[2:], 4)
+	binary.BigEndian.PutUint32(h[4:], unmounted)
+	copy(h[8:], "10.0")
+	created := hfsDate(b.opts.Created)
+	binary.BigEndian.PutUint32(h[16:], created)
+	binary.BigEndian.PutUint32(h[20:], created)
+	binary.BigEndian.PutUint32(h[28:], created)
+	binary.BigEndian.PutUint32(h[32:], l.files)
+	binary.BigEndian.PutUint32(h[36:], l.folders)
+	binary.BigEndian.PutUint32(h[40:], BlockSize)
+	binary.BigEndian.PutUint32(h[44:], l.totalBlocks)
+	binary.BigEndian.PutUint32(h[48:], l.totalBlocks-l.usedBlocks-1)
+	binary.BigEndian.PutUint32(h[52:], l.usedBlocks)
+	binary.BigEndian.PutUint32(h[56:], clumpSize)
+	binary.BigEndian.PutUint32(h[60:], clumpSize)
+	binary.BigEndian.PutUint32(h[64:], b.nextID)
+	binary.BigEndian.PutUint32(h[68:], 1)
+	binary.BigEndian.PutUint64(h[72:], 1) // MacRoman
+	// finderInfo[0] is the blessed folder, the root when opening the volume
+	binary.BigEndian.PutUint32(h[80:], RootFolderID)
+	copy(h[104:112], b.opts.VolumeUUID[:])
+	putSpecialFork(h[112:], l.bitmapBlocks*BlockSize, 1, l.bitmapBlocks)
+	putSpecialFork(h[192:], uint32(len(l.extents)), l.extentsStart, uint32(len(l.extents)/BlockSize))
+	putSpecialFork(h[272:], uint32(len(l.catalog)), l.catalogStart, l.catalogBlocks)
+	return h
+}
+
+func putSpecialFork(b []byte, size, start, count uint32) {
+	putFork(b, uint64(size), start, count)
+	binary.BigEndian.PutUint32(b[8:], clumpSize)
+}
+
+func (l *layout) bitmap() []byte {
+	bitmap := make([]byte, l.bitmapBlocks*BlockSize)
+	mark := func(block uint32) {
+		bitmap[block/8] |= 0x80 >> (block % 8)
+	}
+	for i := uint32(0); i < l.usedBlocks; i++ {
+		mark(i)
+	}
+	// the last block holds the alternate volume header
+	mark(l.totalBlocks - 1)
+	return bitmap
+}
+
+// WriteTo writes the volume image to w. The image is written sequentially,
+// so w can be the input of a disk image writer.
+func (b *Builder) WriteTo(w io.Writer) (int64, error) {
+	l, err := b.layout()
+	if err != nil {
+		return 0, err
+	}
+	cw := &countingWriter{w: w}
+	header := b.volumeHeader(l)
+
+	boot := make([]byte, BlockSize)
+	copy(boot[volumeHeaderOffset:], header)
+	cw.Write(boot)
+	cw.Write(l.bitmap())
+	cw.Write(l.extents)
+	cw.Write(l.catalog)
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+
+	var writeForks func(n *node) error
+	writeForks = func(n *node) error {
+		for _, child := range n.children {
+			if err := writeForks(child); err != nil {
+				return err
+			}
+		}
+		if n.isDir() {
+			return nil
+		}
+		if n.link != "" {
+			cw.Write([]byte(n.link))
+		} else if err := copyFile(cw, n.source, n.dataSize); err != nil {
+			return err
+		}
+		cw.pad(BlockSize)
+		cw.Write(n.rsrc)
+		cw.pad(BlockSize)
+		return cw.err
+	}
+	if err := writeForks(b.root); err != nil {
+		return cw.n, err
+	}
+
+	cw.zeros(int64(l.totalBlocks)*BlockSize - volumeHeaderOffset - cw.n)
+	cw.Write(header)
+	cw.zeros(volumeHeaderOffset - 512)
+	return cw.n, cw.err
+}
+
+func copyFile(w io.Writer, name string, size uint64) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.CopyN(w, f, int64(size)); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", name, err)
+	}
+	return nil
+}
+
+// countingWriter keeps the first error, so writes can be checked once.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func (c *countingWriter) zeros(n int64) {
+	zero := make([]byte, BlockSize)
+	for n > 0 && c.err == nil {
+		chunk := min(n, BlockSize)
+		c.Write(zero[:chunk])
+		n -= chunk
+	}
+}
+
+func (c *countingWriter) pad(align int64) {
+	if rem := c.n % align; rem != 0 {
+		c.zeros(align - rem)
+	}
+}
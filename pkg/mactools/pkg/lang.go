@@ -27,7 +27,7 @@ var validLanguageCodes = map[string]bool{
 	"za": true, "zu": true,
 }
 
-// Function to validate language code
-func isValidLanguageCode(code string) bool {
+// IsValidLanguageCode reports whether code is an ISO 639-1 language code.
+func IsValidLanguageCode(code string) bool {
 	return validLanguageCodes[strings.ToLower(code)]
 }
@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	cpioHeaderSize = 76
+	cpioTrailer    = "TRAILER!!!"
+)
+
+// normalizeMode keeps the file type of mode and sets the permissions to 0755
+// for folders, links and executables and 0644 for other files.
+func normalizeMode(mode uint32) uint32 {
+	const typeDir, typeLink = 0o040000, 0o120000
+	fileType := mode & 0o170000
+	if fileType == typeDir || fileType == typeLink || mode&0o111 != 0 {
+		return fileType | 0o755
+	}
+	return fileType | 0o644
+}
+
+// normalizePayload normalizes the gzip compressed cpio archive that pkgbuild
+// writes as the Payload of a component package.
+func normalizePayload(data []byte, t time.Time) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return nil, errors.New("payload is not a gzip compressed cpio archive")
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	archive, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	if archive, err = normalizeCpio(archive, t); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	if _, err := zw.Write(archive); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// normalizeCpio rewrites the headers of an odc cpio archive: the dates
+// become t, the owners root:wheel and the permissions those of
+// normalizeMode. The device and inode numbers are replaced by the order in
+// which the files first appear, so that hard links still share one.
+func normalizeCpio(archive []byte, t time.Time) ([]byte, error) {
+	out := make([]byte, 0, len(archive))
+	inodes := map[string]uint64{}
+	pos := 0
+	for {
+		if pos+cpioHeaderSize > len(archive) {
+			return nil, errors.New("cpio archive has no trailer")
+		}
+		header := archive[pos : pos+cpioHeaderSize]
+		if string(header[:6]) != "070707" {
+			return nil, fmt.Errorf("unsupported cpio header at %d", pos)
+		}
+		field := func(offset, size int) (uint64, error) {
+			return strconv.ParseUint(string(header[offset:offset+size]), 8, 64)
+		}
+		// dev, ino, mode, uid, gid, nlink, rdev, mtime, namesize, filesize
+		var values [10]uint64
+		for i, f := range [][2]int{{6, 6}, {12, 6}, {18, 6}, {24, 6}, {30, 6}, {36, 6}, {42, 6}, {48, 11}, {59, 6}, {65, 11}} {
+			v, err := field(f[0], f[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpio header at %d: %w", pos, err)
+			}
+			values[i] = v
+		}
+		dev, ino, mode, nlink, rdev, nameSize, fileSize := values[0], values[1], values[2], values[5], values[6], values[8], values[9]
+		end := pos + cpioHeaderSize + int(nameSize) + int(fileSize)
+		if nameSize == 0 || end > len(archive) {
+			return nil, fmt.Errorf("cpio entry at %d is out of range", pos)
+		}
+		name := archive[pos+cpioHeaderSize : pos+cpioHeaderSize+int(nameSize)-1]
+		if string(name) == cpioTrailer {
+			// the trailer and the padding after it are kept as they are
+			return append(out, archive[pos:]...), nil
+		}
+		key := fmt.Sprintf("%o:%o", dev, ino)
+		id, ok := inodes[key]
+		if !ok {
+			id = uint64(len(inodes) + 1)
+			inodes[key] = id
+		}
+		if id > 0o777777 {
+			return nil, errors.New("too many files for a cpio archive")
+		}
+		out = fmt.Appendf(out, "070707%06o%06o%06o%06o%06o%06o%06o%011o%06o%011o",
+			0, id, normalizeMode(uint32(mode)), 0, 0, nlink, rdev, t.Unix(), nameSize, fileSize)
+		out = append(out, archive[pos+cpioHeaderSize:end]...)
+		pos = end
+	}
+}
+
+// normalizeBom normalizes the modes, owners and dates of the paths of a bill
+// of materials like normalizeCpio does for the payload it describes.
+func normalizeBom(data []byte, t time.Time) ([]byte, error) {
+	bom := bytes.Clone(data)
+	if len(bom) < 32 || string(bom[:8]) != "BOMStore" {
+		return nil, errors.New("not a bill of materials")
+	}
+	indexOffset := int(binary.BigEndian.Uint32(bom[16:]))
+	varsOffset := int(binary.BigEndian.Uint32(bom[24:]))
+	block := func(i uint32) ([]byte, error) {
+		entry := indexOffset + 4 + int(i)*8
+		if indexOffset+4 > len(bom) || int(i) >= int(binary.BigEndian.Uint32(bom[indexOffset:])) || entry+8 > len(bom) {
+			return nil, fmt.Errorf("block %d is out of range", i)
+		}
+		address := int(binary.BigEndian.Uint32(bom[entry:]))
+		length := int(binary.BigEndian.Uint32(bom[entry+4:]))
+		if address+length > len(bom) {
+			return nil, fmt.Errorf("block %d is out of range", i)
+		}
+		return bom[address : address+length], nil
+	}
+
+	// the variables name the blocks, Paths is the tree of the files
+	paths := uint32(0)
+	if varsOffset+4 > len(bom) {
+		return nil, errors.New("variables are out of range")
+	}
+	pos := varsOffset + 4
+	for n := binary.BigEndian.Uint32(bom[varsOffset:]); n > 0; n-- {
+		if pos+5 > len(bom) || pos+5+int(bom[pos+4]) > len(bom) {
+			return nil, errors.New("variables are out of range")
+		}
+		if string(bom[pos+5:pos+5+int(bom[pos+4])]) == "Paths" {
+			paths = binary.BigEndian.Uint32(bom[pos:])
+		}
+		pos += 5 + int(bom[pos+4])
+	}
+	tree, err := block(paths)
+	if err != nil || len(tree) < 12 || string(tree[:4]) != "tree" {
+		return nil, errors.New("bill of materials has no Paths tree")
+	}
+
+	// descend to the first leaf and follow the leaves from there
+	next := binary.BigEndian.Uint32(tree[8:])
+	for visited := 0; next != 0; visited++ {
+		if visited > len(bom) {
+			return nil, errors.New("Paths tree has a cycle")
+		}
+		node, err := block(next)
+		if err != nil {
+			return nil, err
+		}
+		if len(node) < 12 {
+			return nil, fmt.Errorf("Paths block %d is too short", next)
+		}
+		leaf := binary.BigEndian.Uint16(node) != 0
+		count := int(binary.BigEndian.Uint16(node[2:]))
+		if len(node) < 12+count*8 {
+			return nil, fmt.Errorf("Paths block %d is too short", next)
+		}
+		if !leaf {
+			if count == 0 {
+				return nil, fmt.Errorf("Paths block %d is empty", next)
+			}
+			next = binary.BigEndian.Uint32(node[12:])
+			continue
+		}
+		for i := 0; i < count; i++ {
+			info1, err := block(binary.BigEndian.Uint32(node[12+i*8:]))
+			if err != nil || len(info1) < 8 {
+				return nil, fmt.Errorf("invalid path %d of block %d", i, next)
+			}
+			info2, err := block(binary.BigEndian.Uint32(info1[4:]))
+			if err != nil || len(info2) < 18 {
+				return nil, fmt.Errorf("invalid path %d of block %d", i, next)
+			}
+			binary.BigEndian.PutUint16(info2[4:], uint16(normalizeMode(uint32(binary.BigEndian.Uint16(info2[4:])))))
+			binary.BigEndian.PutUint32(info2[6:], 0)  // user
+			binary.BigEndian.PutUint32(info2[10:], 0) // group
+			binary.BigEndian.PutUint32(info2[14:], uint32(t.Unix()))
+		}
+		next = binary.BigEndian.Uint32(node[4:])
+	}
+	return bom, nil
+}
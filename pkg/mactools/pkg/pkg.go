@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 )
 
 type Config struct {
@@ -15,12 +16,17 @@ type Config struct {
 	Identifier      string
 	InstallLocation string
 	LicensePaths    map[string]string
+	// Reproducible packages a copy of the app dated SOURCE_DATE_EPOCH, with
+	// normalized permissions and root ownership, and normalizes the dates,
+	// owners and inode numbers of the payload, the bill of materials and the
+	// table of contents of the built archive.
+	Reproducible bool
 }
 
 func CreatePKG(config Config) error {
 	// 언어 코드 유효성 검사
 	for lang := range config.LicensePaths {
-		if !isValidLanguageCode(lang) {
+		if !IsValidLanguageCode(lang) {
 			return fmt.Errorf("invalid language code: %s", lang)
 		}
 	}
@@ -31,13 +37,32 @@ func CreatePKG(config Config) error {
 	}
 	defer os.RemoveAll(tempDir)
 
+	root := filepath.Dir(config.AppPath)
+	var sourceDate time.Time
+	if config.Reproducible {
+		if sourceDate, err = fsutil.SourceDate(); err != nil {
+			return err
+		}
+		root = filepath.Join(tempDir, "root")
+		if err := fsutil.CopyTree(config.AppPath, filepath.Join(root, filepath.Base(config.AppPath))); err != nil {
+			return fmt.Errorf("failed to copy app: %v", err)
+		}
+		if err := fsutil.Normalize(root, sourceDate); err != nil {
+			return fmt.Errorf("failed to normalize app: %v", err)
+		}
+	}
+
 	componentPkgPath := filepath.Join(tempDir, "component.pkg")
-	cmd := exec.Command("pkgbuild",
-		"--root", filepath.Dir(config.AppPath),
+	args := []string{
+		"--root", root,
 		"--install-location", config.InstallLocation,
 		"--identifier", config.Identifier,
 		"--version", config.Version,
-		componentPkgPath)
+	}
+	if config.Reproducible {
+		args = append(args, "--ownership", "recommended")
+	}
+	cmd := exec.Command("pkgbuild", append(args, componentPkgPath)...)
 
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("pkgbuild failed: %v\nOutput: %s", err, output)
@@ -84,6 +109,11 @@ func CreatePKG(config Config) error {
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("productbuild failed: %v\nOutput: %s", err, output)
 	}
+	if config.Reproducible {
+		if err := normalizeXar(config.OutputPath, sourceDate); err != nil {
+			return fmt.Errorf("failed to normalize PKG: %v", err)
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreatePKGReproducible(t *testing.T) {
+	for _, tool := range []string{"pkgbuild", "productbuild"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	build := func(dir string, mtime time.Time, mode os.FileMode) []byte {
+		app := filepath.Join(dir, "App.app")
+		macOS := filepath.Join(app, "Contents", "MacOS")
+		if err := os.MkdirAll(macOS, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range map[string]string{
+			filepath.Join(app, "Contents", "Info.plist"): "<plist/>",
+			filepath.Join(macOS, "App"):                  "#!/bin/sh\n",
+		} {
+			if err := os.WriteFile(name, []byte(data), mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(name, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		out := filepath.Join(dir, "App.pkg")
+		err := CreatePKG(Config{
+			AppPath:         app,
+			OutputPath:      out,
+			Version:         "1.0",
+			Identifier:      "com.example.app",
+			InstallLocation: "/Applications",
+			Reproducible:    true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	a := build(t.TempDir(), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), 0755)
+	b := build(t.TempDir(), time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC), 0775)
+	if !bytes.Equal(a, b) {
+		t.Error("packages built from the same app differ")
+	}
+}
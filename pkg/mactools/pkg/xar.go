@@ -0,0 +1,399 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	xarMagic      = 0x78617221 // "xar!"
+	xarHeaderSize = 28
+)
+
+// normalizeXar rewrites the xar archive at path, a flat package, so that it
+// does not depend on when and by whom it was built: the dates of the table of
+// contents become t, the owners root:wheel and the inode and device numbers
+// 0, and the Payload and Bom of the component packages are normalized the
+// same way. The heap is laid out again with the new sizes and checksums.
+// Signed archives cannot be rewritten.
+func normalizeXar(path string, t time.Time) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < xarHeaderSize || binary.BigEndian.Uint32(data) != xarMagic {
+		return fmt.Errorf("%s is not a xar archive", path)
+	}
+	headerSize := int(binary.BigEndian.Uint16(data[4:]))
+	tocLength := binary.BigEndian.Uint64(data[8:])
+	if headerSize < xarHeaderSize || uint64(len(data)-headerSize) < tocLength {
+		return fmt.Errorf("%s: invalid xar header", path)
+	}
+	heap := data[headerSize+int(tocLength):]
+	tocData, err := inflate(data[headerSize : headerSize+int(tocLength)])
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	root, err := parseTOC(tocData)
+	if err != nil {
+		return fmt.Errorf("%s: invalid table of contents: %w", path, err)
+	}
+	toc := root.child("xar").child("toc")
+	if toc == nil {
+		return fmt.Errorf("%s: table of contents has no toc element", path)
+	}
+	if toc.child("signature") != nil || toc.child("x-signature") != nil {
+		return fmt.Errorf("%s is signed, sign it after it is normalized", path)
+	}
+
+	var h hash.Hash
+	switch alg := binary.BigEndian.Uint32(data[24:]); alg {
+	case 0:
+	case 1:
+		h = sha1.New()
+	case 2:
+		h = md5.New()
+	case 3:
+		// named in the rest of the header
+		if h, err = newHash(string(bytes.TrimRight(data[xarHeaderSize:headerSize], "\x00"))); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported checksum algorithm %d", path, alg)
+	}
+	// the checksum of the table of contents comes first in the heap
+	var newHeap []byte
+	if h != nil {
+		checksum := toc.child("checksum")
+		if checksum == nil {
+			return fmt.Errorf("%s: table of contents has no checksum", path)
+		}
+		size, err := strconv.Atoi(checksum.child("size").text())
+		if err != nil || size != h.Size() {
+			return fmt.Errorf("%s: invalid checksum of the table of contents", path)
+		}
+		checksum.child("offset").setText("0")
+		newHeap = make([]byte, size)
+	}
+
+	normalizeTOC(toc, t)
+	files, err := tocFiles(toc, heap)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	// the data keeps its order in the heap
+	sort.SliceStable(files, func(i, j int) bool { return files[i].offset < files[j].offset })
+	for _, f := range files {
+		archived := f.archived
+		switch f.name {
+		case "Payload":
+			archived, err = f.rewrite(func(b []byte) ([]byte, error) { return normalizePayload(b, t) })
+		case "Bom":
+			archived, err = f.rewrite(func(b []byte) ([]byte, error) { return normalizeBom(b, t) })
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, f.name, err)
+		}
+		f.data.child("offset").setText(strconv.Itoa(len(newHeap)))
+		f.data.child("length").setText(strconv.Itoa(len(archived)))
+		newHeap = append(newHeap, archived...)
+	}
+
+	var out bytes.Buffer
+	root.write(&out)
+	compressed, err := deflate(out.Bytes())
+	if err != nil {
+		return err
+	}
+	if h != nil {
+		h.Write(compressed)
+		copy(newHeap, h.Sum(nil))
+	}
+
+	header := bytes.Clone(data[:headerSize])
+	binary.BigEndian.PutUint64(header[8:], uint64(len(compressed)))
+	binary.BigEndian.PutUint64(header[16:], uint64(out.Len()))
+	return os.WriteFile(path, append(append(header, compressed...), newHeap...), 0644)
+}
+
+// normalizeTOC replaces the dates, owners and inode numbers of a table of
+// contents. Dates keep their form, with or without the Z of UTC.
+func normalizeTOC(toc *xarNode, t time.Time) {
+	date := t.UTC().Format("2006-01-02T15:04:05")
+	toc.walk(func(n *xarNode) {
+		switch n.name {
+		case "creation-time", "ctime", "mtime", "atime":
+			if strings.HasSuffix(n.text(), "Z") {
+				n.setText(date + "Z")
+			} else {
+				n.setText(date)
+			}
+		case "uid", "gid", "inode", "deviceno":
+			n.setText("0")
+		case "user":
+			n.setText("root")
+		case "group":
+			n.setText("wheel")
+		}
+	})
+}
+
+// xarFile is a file of the archive with data in the heap.
+type xarFile struct {
+	name     string
+	data     *xarNode
+	offset   int
+	archived []byte
+}
+
+// tocFiles returns the files with data in the heap.
+func tocFiles(toc *xarNode, heap []byte) ([]*xarFile, error) {
+	var files []*xarFile
+	var err error
+	toc.walk(func(n *xarNode) {
+		data := n.child("data")
+		if n.name != "file" || data == nil || err != nil {
+			return
+		}
+		name := n.child("name").text()
+		offset, offsetErr := strconv.Atoi(data.child("offset").text())
+		length, lengthErr := strconv.Atoi(data.child("length").text())
+		if offsetErr != nil || lengthErr != nil || offset < 0 || length < 0 || offset+length > len(heap) {
+			err = fmt.Errorf("invalid data of %s", name)
+			return
+		}
+		files = append(files, &xarFile{name: name, data: data, offset: offset, archived: heap[offset : offset+length]})
+	})
+	return files, err
+}
+
+// rewrite changes the extracted contents of the file with fn, encodes them
+// again and updates the size and checksums.
+func (f *xarFile) rewrite(fn func([]byte) ([]byte, error)) ([]byte, error) {
+	style := ""
+	if e := f.data.child("encoding"); e != nil {
+		style = e.attr("style")
+	}
+	var contents []byte
+	var err error
+	switch style {
+	case "", "application/octet-stream":
+		contents = f.archived
+	case "application/x-gzip":
+		// xar calls zlib streams gzip
+		contents, err = inflate(f.archived)
+	default:
+		err = fmt.Errorf("unsupported encoding %s", style)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if contents, err = fn(contents); err != nil {
+		return nil, err
+	}
+	archived := contents
+	if style == "application/x-gzip" {
+		if archived, err = deflate(contents); err != nil {
+			return nil, err
+		}
+	}
+	f.data.child("size").setText(strconv.Itoa(len(contents)))
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{{"extracted-checksum", contents}, {"archived-checksum", archived}} {
+		n := f.data.child(c.name)
+		if n == nil {
+			continue
+		}
+		h, err := newHash(n.attr("style"))
+		if err != nil {
+			return nil, err
+		}
+		h.Write(c.data)
+		n.setText(hex.EncodeToString(h.Sum(nil)))
+	}
+	return archived, nil
+}
+
+func newHash(name string) (hash.Hash, error) {
+	switch strings.ToLower(name) {
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum %q", name)
+}
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	zw := zlib.NewWriter(&out)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// xarNode is an element of the table of contents. Its content, text and
+// child elements, is kept in order so that the table is written back as it
+// was read apart from the values that are changed.
+type xarNode struct {
+	name    string
+	attrs   []xml.Attr
+	content []any // string or *xarNode
+}
+
+func parseTOC(data []byte) (*xarNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	stack := []*xarNode{{}}
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &xarNode{name: qualifiedName(tok.Name), attrs: tok.Attr}
+			top.content = append(top.content, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) == 1 || qualifiedName(tok.Name) != top.name {
+				return nil, fmt.Errorf("unexpected </%s>", qualifiedName(tok.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 1 {
+				top.content = append(top.content, string(tok))
+			}
+		}
+	}
+	if len(stack) != 1 {
+		return nil, errors.New("unclosed elements")
+	}
+	return stack[0], nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+func (n *xarNode) child(name string) *xarNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.content {
+		if c, ok := c.(*xarNode); ok && c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *xarNode) attr(name string) string {
+	for _, a := range n.attrs {
+		if qualifiedName(a.Name) == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *xarNode) text() string {
+	if n == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range n.content {
+		if s, ok := c.(string); ok {
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+func (n *xarNode) setText(s string) {
+	n.content = []any{s}
+}
+
+// walk calls fn for every element below n, parents first.
+func (n *xarNode) walk(fn func(*xarNode)) {
+	for _, c := range n.content {
+		if c, ok := c.(*xarNode); ok {
+			fn(c)
+			c.walk(fn)
+		}
+	}
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// write encodes the document n, the root returned by parseTOC.
+func (n *xarNode) write(b *bytes.Buffer) {
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	n.writeContent(b)
+}
+
+func (n *xarNode) writeContent(b *bytes.Buffer) {
+	for _, c := range n.content {
+		switch c := c.(type) {
+		case string:
+			textEscaper.WriteString(b, c)
+		case *xarNode:
+			b.WriteString("<" + c.name)
+			for _, a := range c.attrs {
+				b.WriteString(" " + qualifiedName(a.Name) + `="`)
+				attrEscaper.WriteString(b, a.Value)
+				b.WriteString(`"`)
+			}
+			if len(c.content) == 0 {
+				b.WriteString("/>")
+				continue
+			}
+			b.WriteString(">")
+			c.writeContent(b)
+			b.WriteString("</" + c.name + ">")
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type cpioEntry struct {
+	name                string
+	dev, ino, mode, uid int // uid is the group too
+	mtime               int64
+	data                string
+}
+
+func writeCpio(entries []cpioEntry) []byte {
+	var b bytes.Buffer
+	for _, e := range append(entries, cpioEntry{name: cpioTrailer}) {
+		fmt.Fprintf(&b, "070707%06o%06o%06o%06o%06o%06o%06o%011o%06o%011o",
+			e.dev, e.ino, e.mode, e.uid, e.uid, 1, 0, e.mtime, len(e.name)+1, len(e.data))
+		b.WriteString(e.name + "\x00" + e.data)
+	}
+	return b.Bytes()
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Name = "Payload"
+	zw.ModTime = time.Now()
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// writeBom writes a bill of materials with a Paths tree of one leaf, owned
+// by owner as user and group.
+func writeBom(modes []uint16, owner uint32, mtime uint32) []byte {
+	var blocks [][]byte
+	add := func(b []byte) uint32 {
+		blocks = append(blocks, b)
+		return uint32(len(blocks))
+	}
+	leaf := binary.BigEndian.AppendUint16(nil, 1)
+	leaf = binary.BigEndian.AppendUint16(leaf, uint16(len(modes)))
+	leaf = append(leaf, make([]byte, 8)...)
+	for i, mode := range modes {
+		info2 := []byte{1, 1, 0, 3}
+		info2 = binary.BigEndian.AppendUint16(info2, mode)
+		info2 = binary.BigEndian.AppendUint32(info2, owner)
+		info2 = binary.BigEndian.AppendUint32(info2, owner)
+		info2 = binary.BigEndian.AppendUint32(info2, mtime)
+		info2 = append(info2, make([]byte, 13)...)
+		info1 := binary.BigEndian.AppendUint32(nil, uint32(i+1))
+		info1 = binary.BigEndian.AppendUint32(info1, add(info2))
+		name := append(binary.BigEndian.AppendUint32(nil, 0), fmt.Sprintf("file%d\x00", i)...)
+		leaf = binary.BigEndian.AppendUint32(leaf, add(info1))
+		leaf = binary.BigEndian.AppendUint32(leaf, add(name))
+	}
+	paths := add(leaf)
+	tree := append([]byte("tree"), 0, 0, 0, 1)
+	tree = binary.BigEndian.AppendUint32(tree, paths)
+	tree = binary.BigEndian.AppendUint32(tree, 4096)
+	tree = binary.BigEndian.AppendUint32(tree, uint32(len(modes)))
+	tree = append(tree, 0)
+	treeIndex := add(tree)
+
+	bom := make([]byte, 32)
+	copy(bom, "BOMStore")
+	var index []byte
+	index = binary.BigEndian.AppendUint32(index, uint32(len(blocks)+1))
+	index = append(index, make([]byte, 8)...) // block 0 is null
+	for _, b := range blocks {
+		index = binary.BigEndian.AppendUint32(index, uint32(len(bom)))
+		index = binary.BigEndian.AppendUint32(index, uint32(len(b)))
+		bom = append(bom, b...)
+	}
+	binary.BigEndian.PutUint32(bom[8:], 1)
+	binary.BigEndian.PutUint32(bom[12:], uint32(len(blocks)))
+	binary.BigEndian.PutUint32(bom[16:], uint32(len(bom)))
+	binary.BigEndian.PutUint32(bom[20:], uint32(len(index)))
+	bom = append(bom, index...)
+	vars := binary.BigEndian.AppendUint32(nil, 1)
+	vars = binary.BigEndian.AppendUint32(vars, treeIndex)
+	vars = append(append(vars, 5), "Paths"...)
+	binary.BigEndian.PutUint32(bom[24:], uint32(len(bom)))
+	binary.BigEndian.PutUint32(bom[28:], uint32(len(vars)))
+	return append(bom, vars...)
+}
+
+type xarEntry struct {
+	name string
+	data []byte
+	zlib bool
+}
+
+func sha1Hex(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeXar writes a xar archive with a SHA-1 checksum of the table of
+// contents, as productbuild does, with the files in a component package.
+func writeXar(t *testing.T, path, created, user string, inode int, files []xarEntry) {
+	heap := make([]byte, 20)
+	var toc strings.Builder
+	toc.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<xar>\n <toc>\n")
+	toc.WriteString(`  <checksum style="sha1"><offset>0</offset><size>20</size></checksum>` + "\n")
+	toc.WriteString("  <creation-time>" + created + "</creation-time>\n")
+	toc.WriteString(`  <file id="1"><name>component.pkg</name><type>directory</type>` + "\n")
+	for i, f := range files {
+		archived, encoding := f.data, "application/octet-stream"
+		if f.zlib {
+			var err error
+			if archived, err = deflate(f.data); err != nil {
+				t.Fatal(err)
+			}
+			encoding = "application/x-gzip"
+		}
+		fmt.Fprintf(&toc, `   <file id="%d"><name>%s</name><type>file</type>`, i+2, f.name)
+		fmt.Fprintf(&toc, `<ctime>%sZ</ctime><mtime>%sZ</mtime><user>%s</user><uid>501</uid><group>staff</group><gid>20</gid>`, created, created, user)
+		fmt.Fprintf(&toc, `<inode>%s</inode><deviceno>16777220</deviceno>`, strings.Repeat("7", inode+i))
+		fmt.Fprintf(&toc, `<data><length>%d</length><offset>%d</offset><size>%d</size><encoding style="%s"/>`, len(archived), len(heap), len(f.data), encoding)
+		fmt.Fprintf(&toc, `<extracted-checksum style="sha1">%s</extracted-checksum><archived-checksum style="sha1">%s</archived-checksum></data></file>`+"\n", sha1Hex(f.data), sha1Hex(archived))
+		heap = append(heap, archived...)
+	}
+	toc.WriteString("  </file>\n </toc>\n</xar>\n")
+
+	compressed, err := deflate([]byte(toc.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum(compressed)
+	copy(heap, sum[:])
+	header := make([]byte, xarHeaderSize)
+	binary.BigEndian.PutUint32(header, xarMagic)
+	binary.BigEndian.PutUint16(header[4:], xarHeaderSize)
+	binary.BigEndian.PutUint16(header[6:], 1)
+	binary.BigEndian.PutUint64(header[8:], uint64(len(compressed)))
+	binary.BigEndian.PutUint64(header[16:], uint64(toc.Len()))
+	binary.BigEndian.PutUint32(header[24:], 1)
+	if err := os.WriteFile(path, append(append(header, compressed...), heap...), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readXar returns the table of contents of the archive at path and the
+// extracted contents of its files, checking every checksum.
+func readXar(t *testing.T, path string) (string, map[string][]byte) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tocLength := binary.BigEndian.Uint64(data[8:])
+	compressed := data[xarHeaderSize : xarHeaderSize+tocLength]
+	heap := data[xarHeaderSize+tocLength:]
+	if sum := sha1.Sum(compressed); !bytes.Equal(heap[:20], sum[:]) {
+		t.Error("checksum of the table of contents does not match")
+	}
+	tocData, err := inflate(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := parseTOC(tocData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	root.walk(func(n *xarNode) {
+		data := n.child("data")
+		if n.name != "file" || data == nil {
+			return
+		}
+		offset, _ := strconv.Atoi(data.child("offset").text())
+		length, _ := strconv.Atoi(data.child("length").text())
+		archived := heap[offset : offset+length]
+		contents := archived
+		if data.child("encoding").attr("style") == "application/x-gzip" {
+			if contents, err = inflate(archived); err != nil {
+				t.Fatal(err)
+			}
+		}
+		name := n.child("name").text()
+		if sha1Hex(archived) != data.child("archived-checksum").text() || sha1Hex(contents) != data.child("extracted-checksum").text() {
+			t.Errorf("checksums of %s do not match", name)
+		}
+		if strconv.Itoa(len(contents)) != data.child("size").text() {
+			t.Errorf("size of %s does not match", name)
+		}
+		files[name] = contents
+	})
+	return string(tocData), files
+}
+
+func TestNormalizeXar(t *testing.T) {
+	files := func(mtime int64, ino int) []xarEntry {
+		payload := writeCpio([]cpioEntry{
+			{name: ".", dev: 0o100004, ino: ino, mode: 0o40775, uid: 501, mtime: mtime},
+			{name: "./App.app/Contents/MacOS/App", dev: 0o100004, ino: ino + 1, mode: 0o100700, uid: 501, mtime: mtime, data: "binary"},
+			{name: "./App.app/Contents/Info.plist", dev: 0o100004, ino: ino + 2, mode: 0o100664, uid: 501, mtime: mtime, data: "<plist/>"},
+		})
+		return []xarEntry{
+			{name: "PackageInfo", data: []byte("<pkg-info/>")},
+			{name: "Bom", data: writeBom([]uint16{0o40775, 0o100700, 0o100664}, 501, uint32(mtime)), zlib: true},
+			{name: "Payload", data: gzipData(t, payload)},
+		}
+	}
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.pkg"), filepath.Join(dir, "b.pkg")
+	writeXar(t, a, "2024-01-02T03:04:05", "alice", 6, files(1704164645, 1000))
+	writeXar(t, b, "2025-06-07T08:09:10", "bob", 8, files(1749283750, 52000))
+	date := time.Unix(1700000000, 0)
+	for _, path := range []string{a, b} {
+		if err := normalizeXar(path, date); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dataA, _ := os.ReadFile(a)
+	dataB, _ := os.ReadFile(b)
+	if !bytes.Equal(dataA, dataB) {
+		t.Fatal("archives differ")
+	}
+
+	toc, contents := readXar(t, a)
+	for _, want := range []string{
+		"<creation-time>2023-11-14T22:13:20</creation-time>",
+		"<mtime>2023-11-14T22:13:20Z</mtime>",
+		"<user>root</user><uid>0</uid><group>wheel</group><gid>0</gid>",
+		"<inode>0</inode><deviceno>0</deviceno>",
+		`<encoding style="application/x-gzip"/>`,
+	} {
+		if !strings.Contains(toc, want) {
+			t.Errorf("table of contents has no %s: %s", want, toc)
+		}
+	}
+	if string(contents["PackageInfo"]) != "<pkg-info/>" {
+		t.Errorf("PackageInfo = %q", contents["PackageInfo"])
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(contents["Payload"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := writeCpio([]cpioEntry{
+		{name: ".", ino: 1, mode: 0o40755, mtime: date.Unix()},
+		{name: "./App.app/Contents/MacOS/App", ino: 2, mode: 0o100755, mtime: date.Unix(), data: "binary"},
+		{name: "./App.app/Contents/Info.plist", ino: 3, mode: 0o100644, mtime: date.Unix(), data: "<plist/>"},
+	})
+	if !bytes.Equal(payload, want) {
+		t.Errorf("payload =\n%s\nwant\n%s", payload, want)
+	}
+
+	if want := writeBom([]uint16{0o40755, 0o100755, 0o100644}, 0, uint32(date.Unix())); !bytes.Equal(contents["Bom"], want) {
+		t.Errorf("Bom =\n%x\nwant\n%x", contents["Bom"], want)
+	}
+}
+
+func TestNormalizeXarSigned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signed.pkg")
+	writeXar(t, path, "2024-01-02T03:04:05", "alice", 6, nil)
+	data, _ := os.ReadFile(path)
+	tocLength := binary.BigEndian.Uint64(data[8:])
+	toc, _ := inflate(data[xarHeaderSize : xarHeaderSize+tocLength])
+	toc = bytes.Replace(toc, []byte("</toc>"), []byte(`<signature style="RSA"/></toc>`), 1)
+	compressed, _ := deflate(toc)
+	binary.BigEndian.PutUint64(data[8:], uint64(len(compressed)))
+	data = append(append(data[:xarHeaderSize:xarHeaderSize], compressed...), data[xarHeaderSize+tocLength:]...)
+	os.WriteFile(path, data, 0644)
+	if err := normalizeXar(path, time.Unix(0, 0)); err == nil || !strings.Contains(err.Error(), "signed") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package rsrc
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+)
+
+// AppleDouble entry IDs.
+const (
+	entryResourceFork = 2
+	entryFinderInfo   = 9
+)
+
+const (
+	appleDoubleMagic   = 0x00051607
+	appleDoubleVersion = 0x00020000
+)
+
+// AppleDouble is the content of a ._ file, which holds the Finder info and
+// the resource fork of the file next to it on file systems without extended
+// attributes.
+type AppleDouble struct {
+	FinderInfo   FinderInfo
+	ResourceFork []byte
+}
+
+// AppleDoublePath returns the path of the AppleDouble file of path.
+func AppleDoublePath(path string) string {
+	dir, name := filepath.Split(path)
+	return filepath.Join(dir, "._"+name)
+}
+
+// MarshalBinary encodes d in the layout of the files macOS writes: the Finder
+// info followed by the resource fork.
+func (d *AppleDouble) MarshalBinary() ([]byte, error) {
+	const numEntries = 2
+	b := binary.BigEndian.AppendUint32(nil, appleDoubleMagic)
+	b = binary.BigEndian.AppendUint32(b, appleDoubleVersion)
+	b = append(b, "Mac OS X        "...)
+	b = binary.BigEndian.AppendUint16(b, numEntries)
+	offset := uint32(len(b) + 12*numEntries)
+	for _, e := range [][2]uint32{
+		{entryFinderInfo, uint32(len(d.FinderInfo))},
+		{entryResourceFork, uint32(len(d.ResourceFork))},
+	} {
+		b = binary.BigEndian.AppendUint32(b, e[0])
+		b = binary.BigEndian.AppendUint32(b, offset)
+		b = binary.BigEndian.AppendUint32(b, e[1])
+		offset += e[1]
+	}
+	b = append(b, d.FinderInfo[:]...)
+	return append(b, d.ResourceFork...), nil
+}
+
+// UnmarshalBinary decodes an AppleDouble file. Entries other than the Finder
+// info and the resource fork, and the extended attributes macOS stores after
+// the Finder info, are dropped.
+func (d *AppleDouble) UnmarshalBinary(b []byte) error {
+	errInvalid := errors.New("invalid AppleDouble file")
+	if len(b) < 26 || binary.BigEndian.Uint32(b) != appleDoubleMagic {
+		return errInvalid
+	}
+	*d = AppleDouble{}
+	numEntries := int(binary.BigEndian.Uint16(b[24:]))
+	if len(b) < 26+12*numEntries {
+		return errInvalid
+	}
+	for i := 0; i < numEntries; i++ {
+		e := b[26+12*i:]
+		id := binary.BigEndian.Uint32(e[0:])
+		offset := int(binary.BigEndian.Uint32(e[4:]))
+		length := int(binary.BigEndian.Uint32(e[8:]))
+		if offset+length > len(b) {
+			return errInvalid
+		}
+		switch id {
+		case entryFinderInfo:
+			copy(d.FinderInfo[:], b[offset:offset+length])
+		case entryResourceFork:
+			d.ResourceFork = append([]byte(nil), b[offset:offset+length]...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package rsrc
+
+import "encoding/binary"
+
+// HasCustomIcon is the Finder flag of files and folders with a custom icon.
+const HasCustomIcon = 0x0400
+
+// FinderInfo is the 32 bytes of Finder information of a file or folder. The
+// type and creator only apply to files, the flags are at the same place for
+// both.
+type FinderInfo [32]byte
+
+func (f *FinderInfo) Type() string    { return string(f[0:4]) }
+func (f *FinderInfo) Creator() string { return string(f[4:8]) }
+func (f *FinderInfo) Flags() uint16   { return binary.BigEndian.Uint16(f[8:]) }
+
+func (f *FinderInfo) SetType(typ string)        { copy(f[0:4], typ+"\x00\x00\x00\x00") }
+func (f *FinderInfo) SetCreator(creator string) { copy(f[4:8], creator+"\x00\x00\x00\x00") }
+func (f *FinderInfo) SetFlags(flags uint16)     { binary.BigEndian.PutUint16(f[8:], flags) }
@@ -0,0 +1,170 @@
+// Package rsrc reads and writes classic Mac OS resource forks and Finder
+// info, which give files and folders their custom icons. They are stored in
+// the com.apple.FinderInfo and com.apple.ResourceFork extended attributes on
+// macOS, or in AppleDouble files on file systems without them.
+package rsrc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// CustomIconID is the ID of the icns resource that holds the custom icon of
+// a file (kCustomIconResource).
+const CustomIconID = -16455
+
+const (
+	headerSize  = 256 // header and the reserved system and application data
+	mapHeadSize = 28  // copy of the header, handle, file reference, attributes and offsets
+	refSize     = 12
+)
+
+// Resource is an entry of a resource fork.
+type Resource struct {
+	Type       string // four characters, e.g. "icns"
+	ID         int16
+	Name       string
+	Attributes uint8
+	Data       []byte
+}
+
+// Encode builds a resource fork with the resources, grouped by type in the
+// order the types first appear.
+func Encode(resources []Resource) ([]byte, error) {
+	var types []string
+	byType := map[string][]Resource{}
+	for _, r := range resources {
+		if len(r.Type) != 4 {
+			return nil, fmt.Errorf("invalid resource type %q", r.Type)
+		}
+		if _, ok := byType[r.Type]; !ok {
+			types = append(types, r.Type)
+		}
+		byType[r.Type] = append(byType[r.Type], r)
+	}
+
+	var data, names []byte
+	typeList := binary.BigEndian.AppendUint16(nil, uint16(len(types)-1))
+	var refList []byte
+	refOffset := 2 + 8*len(types) // relative to the type list
+	for _, typ := range types {
+		typeList = append(typeList, typ...)
+		typeList = binary.BigEndian.AppendUint16(typeList, uint16(len(byType[typ])-1))
+		typeList = binary.BigEndian.AppendUint16(typeList, uint16(refOffset+len(refList)))
+		for _, r := range byType[typ] {
+			nameOffset := uint16(0xffff)
+			if r.Name != "" {
+				name, err := charmap.Macintosh.NewEncoder().String(r.Name)
+				if err != nil || len(name) > 255 {
+					return nil, fmt.Errorf("invalid name of resource %s %d: %q", r.Type, r.ID, r.Name)
+				}
+				nameOffset = uint16(len(names))
+				names = append(append(names, byte(len(name))), name...)
+			}
+			if len(data) >= 1<<24 {
+				return nil, errors.New("resource fork is too large")
+			}
+			refList = binary.BigEndian.AppendUint16(refList, uint16(r.ID))
+			refList = binary.BigEndian.AppendUint16(refList, nameOffset)
+			refList = binary.BigEndian.AppendUint32(refList, uint32(r.Attributes)<<24|uint32(len(data)))
+			refList = append(refList, 0, 0, 0, 0) // handle
+			data = binary.BigEndian.AppendUint32(data, uint32(len(r.Data)))
+			data = append(data, r.Data...)
+		}
+	}
+	nameListOffset := mapHeadSize + len(typeList) + len(refList)
+	if nameListOffset+len(names) > 0xffff {
+		return nil, errors.New("resource map is too large")
+	}
+
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint32(header[0:], headerSize)
+	binary.BigEndian.PutUint32(header[4:], uint32(headerSize+len(data)))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(data)))
+	binary.BigEndian.PutUint32(header[12:], uint32(nameListOffset+len(names)))
+
+	fork := make([]byte, headerSize, headerSize+len(data)+nameListOffset+len(names))
+	copy(fork, header)
+	fork = append(fork, data...)
+	fork = append(fork, header...)
+	fork = append(fork, make([]byte, 8)...) // handle, file reference and attributes
+	fork = binary.BigEndian.AppendUint16(fork, mapHeadSize)
+	fork = binary.BigEndian.AppendUint16(fork, uint16(nameListOffset))
+	fork = append(fork, typeList...)
+	fork = append(fork, refList...)
+	return append(fork, names...), nil
+}
+
+// Decode parses a resource fork. An empty fork has no resources.
+func Decode(fork []byte) ([]Resource, error) {
+	if len(fork) == 0 {
+		return nil, nil
+	}
+	errInvalid := errors.New("invalid resource fork")
+	if len(fork) < 16 {
+		return nil, errInvalid
+	}
+	dataOffset := int(binary.BigEndian.Uint32(fork[0:]))
+	mapOffset := int(binary.BigEndian.Uint32(fork[4:]))
+	dataLength := int(binary.BigEndian.Uint32(fork[8:]))
+	mapLength := int(binary.BigEndian.Uint32(fork[12:]))
+	if dataOffset+dataLength > len(fork) || mapOffset+mapLength > len(fork) || mapLength < mapHeadSize+2 {
+		return nil, errInvalid
+	}
+	data := fork[dataOffset : dataOffset+dataLength]
+	m := fork[mapOffset : mapOffset+mapLength]
+	typeList := int(binary.BigEndian.Uint16(m[24:]))
+	nameList := int(binary.BigEndian.Uint16(m[26:]))
+	if typeList+2 > len(m) || nameList > len(m) {
+		return nil, errInvalid
+	}
+	// the count of an empty fork is 0xffff, which wraps to 0
+	numTypes := int(binary.BigEndian.Uint16(m[typeList:]) + 1)
+	if typeList+2+8*numTypes > len(m) {
+		return nil, errInvalid
+	}
+
+	var resources []Resource
+	for i := 0; i < numTypes; i++ {
+		t := m[typeList+2+8*i:]
+		typ := string(t[:4])
+		count := int(binary.BigEndian.Uint16(t[4:])) + 1
+		refs := typeList + int(binary.BigEndian.Uint16(t[6:]))
+		if refs+count*refSize > len(m) {
+			return nil, errInvalid
+		}
+		for j := 0; j < count; j++ {
+			ref := m[refs+j*refSize:]
+			r := Resource{
+				Type:       typ,
+				ID:         int16(binary.BigEndian.Uint16(ref[0:])),
+				Attributes: ref[4],
+			}
+			if nameOffset := binary.BigEndian.Uint16(ref[2:]); nameOffset != 0xffff {
+				n := nameList + int(nameOffset)
+				if n >= len(m) || n+1+int(m[n]) > len(m) {
+					return nil, errInvalid
+				}
+				name, err := charmap.Macintosh.NewDecoder().Bytes(m[n+1 : n+1+int(m[n])])
+				if err != nil {
+					return nil, err
+				}
+				r.Name = string(name)
+			}
+			offset := int(binary.BigEndian.Uint32(ref[4:]) & 0xffffff)
+			if offset+4 > len(data) {
+				return nil, errInvalid
+			}
+			length := int(binary.BigEndian.Uint32(data[offset:]))
+			if offset+4+length > len(data) {
+				return nil, errInvalid
+			}
+			r.Data = data[offset+4 : offset+4+length]
+			resources = append(resources, r)
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,110 @@
+package rsrc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	resources := []Resource{
+		{Type: "icns", ID: CustomIconID, Data: []byte("icns\x00\x00\x00\x08")},
+		{Type: "STR#", ID: 5000, Name: "Français", Data: []byte{0, 0}},
+		{Type: "icns", ID: 128, Attributes: 0x20, Data: []byte{}},
+	}
+	fork, err := Encode(resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint32(fork) != headerSize {
+		t.Errorf("data offset = %d", binary.BigEndian.Uint32(fork))
+	}
+	mapOffset := binary.BigEndian.Uint32(fork[4:])
+	if !bytes.Equal(fork[mapOffset:mapOffset+16], fork[:16]) {
+		t.Error("map does not start with a copy of the header")
+	}
+	if got := binary.BigEndian.Uint16(fork[mapOffset+28:]); got != 1 {
+		t.Errorf("number of types - 1 = %d", got)
+	}
+
+	got, err := Decode(fork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Resource{resources[0], resources[2], resources[1]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+
+	empty, err := Encode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Decode(empty); err != nil || len(got) != 0 {
+		t.Errorf("Decode(empty fork) = %v, %v", got, err)
+	}
+	if _, err := Decode(fork[:len(fork)-1]); err == nil {
+		t.Error("Decode of a truncated fork succeeded")
+	}
+}
+
+func TestAppleDouble(t *testing.T) {
+	d := AppleDouble{ResourceFork: []byte("fork")}
+	d.FinderInfo.SetCreator("icnC")
+	d.FinderInfo.SetFlags(HasCustomIcon)
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 50+32+4 || string(data[8:24]) != "Mac OS X        " {
+		t.Errorf("AppleDouble = %q", data)
+	}
+	var got AppleDouble
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("UnmarshalBinary = %+v, want %+v", got, d)
+	}
+	if got.FinderInfo.Creator() != "icnC" || got.FinderInfo.Type() != "\x00\x00\x00\x00" {
+		t.Errorf("type %q, creator %q", got.FinderInfo.Type(), got.FinderInfo.Creator())
+	}
+}
+
+func TestSetCustomIcon(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("uses extended attributes on macOS")
+	}
+	path := filepath.Join(t.TempDir(), "App.dmg")
+	if err := os.WriteFile(path, []byte("dmg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCustomIcon(path, []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCustomIcon(path, []byte("icon")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "._App.dmg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d AppleDouble
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if d.FinderInfo.Flags() != HasCustomIcon {
+		t.Errorf("flags = %#x", d.FinderInfo.Flags())
+	}
+	resources, err := Decode(d.ResourceFork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 1 || resources[0].ID != CustomIconID || string(resources[0].Data) != "icon" {
+		t.Errorf("resources = %+v", resources)
+	}
+}
@@ -0,0 +1,126 @@
+package rsrc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Extended attributes of the Finder info and the resource fork.
+const (
+	FinderInfoAttr   = "com.apple.FinderInfo"
+	ResourceForkAttr = "com.apple.ResourceFork"
+)
+
+// errNoXattr is returned where the file system or the platform has no
+// extended attributes, the AppleDouble file is used instead.
+var errNoXattr = errors.New("extended attributes are not supported")
+
+// SetCustomIcon gives the file at path the icns data as its custom icon by
+// adding it to the resource fork and setting the HasCustomIcon flag. Other
+// resources of the file are kept.
+func SetCustomIcon(path string, icns []byte) error {
+	return update(path, func(info *FinderInfo, fork []byte) ([]byte, error) {
+		resources, err := Decode(fork)
+		if err != nil {
+			return nil, err
+		}
+		icon := Resource{Type: "icns", ID: CustomIconID, Data: icns}
+		replaced := false
+		for i, r := range resources {
+			if r.Type == icon.Type && r.ID == icon.ID {
+				resources[i], replaced = icon, true
+			}
+		}
+		if !replaced {
+			resources = append(resources, icon)
+		}
+		info.SetFlags(info.Flags() | HasCustomIcon)
+		return Encode(resources)
+	})
+}
+
+// SetFlags sets the Finder flags of the file or folder at path in addition
+// to the flags it has, e.g. HasCustomIcon on the root of a volume with a
+// .VolumeIcon.icns.
+func SetFlags(path string, flags uint16) error {
+	return update(path, func(info *FinderInfo, fork []byte) ([]byte, error) {
+		info.SetFlags(info.Flags() | flags)
+		return fork, nil
+	})
+}
+
+// SetCreator sets the creator code of the file at path, e.g. icnC for a
+// .VolumeIcon.icns.
+func SetCreator(path, creator string) error {
+	return update(path, func(info *FinderInfo, fork []byte) ([]byte, error) {
+		info.SetCreator(creator)
+		return fork, nil
+	})
+}
+
+// update lets fn change the Finder info and the resource fork of the file
+// at path. They are read from and written to the extended attributes, or to
+// the AppleDouble file where those are not supported.
+func update(path string, fn func(info *FinderInfo, fork []byte) ([]byte, error)) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	var info FinderInfo
+	infoData, err := getxattr(path, FinderInfoAttr)
+	if errors.Is(err, errNoXattr) {
+		return updateAppleDouble(path, fn)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read Finder info of %s: %w", path, err)
+	}
+	copy(info[:], infoData)
+	fork, err := getxattr(path, ResourceForkAttr)
+	if err != nil {
+		return fmt.Errorf("failed to read resource fork of %s: %w", path, err)
+	}
+
+	oldInfo := info
+	newFork, err := fn(&info, fork)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(newFork, fork) {
+		if err := setxattr(path, ResourceForkAttr, newFork); errors.Is(err, errNoXattr) {
+			return updateAppleDouble(path, fn)
+		} else if err != nil {
+			return fmt.Errorf("failed to write resource fork of %s: %w", path, err)
+		}
+	}
+	if info != oldInfo {
+		if err := setxattr(path, FinderInfoAttr, info[:]); errors.Is(err, errNoXattr) {
+			return updateAppleDouble(path, fn)
+		} else if err != nil {
+			return fmt.Errorf("failed to write Finder info of %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func updateAppleDouble(path string, fn func(info *FinderInfo, fork []byte) ([]byte, error)) error {
+	adPath := AppleDoublePath(path)
+	var d AppleDouble
+	if data, err := os.ReadFile(adPath); err == nil {
+		if err := d.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("%s: %w", adPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	fork, err := fn(&d.FinderInfo, d.ResourceFork)
+	if err != nil {
+		return err
+	}
+	d.ResourceFork = fork
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(adPath, data, 0644)
+}
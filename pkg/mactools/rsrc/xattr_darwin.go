@@ -0,0 +1,51 @@
+//go:build darwin
+
+package rsrc
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// getxattr returns the extended attribute name of path, or nil if the file
+// does not have it.
+func getxattr(path, name string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, name, nil)
+		if errors.Is(err, unix.ENOATTR) {
+			return nil, nil
+		}
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, errNoXattr
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := unix.Getxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue // grown in between
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// setxattr replaces the extended attribute name of path with data.
+func setxattr(path, name string, data []byte) error {
+	// a shorter resource fork does not truncate the one it replaces
+	if err := unix.Removexattr(path, name); err != nil && !errors.Is(err, unix.ENOATTR) {
+		if errors.Is(err, unix.ENOTSUP) {
+			return errNoXattr
+		}
+		return err
+	}
+	err := unix.Setxattr(path, name, data, 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		return errNoXattr
+	}
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !darwin
+
+package rsrc
+
+// Other platforms have no com.apple extended attributes, e.g. Linux only
+// allows names in the user namespace, so AppleDouble files are used.
+
+func getxattr(path, name string) ([]byte, error) {
+	return nil, errNoXattr
+}
+
+func setxattr(path, name string, data []byte) error {
+	return errNoXattr
+}
@@ -0,0 +1,50 @@
+package udif
+
+import "fmt"
+
+// decodeADC decompresses Apple Data Compression (UDCO) chunks into dst.
+func decodeADC(dst, src []byte) error {
+	out := 0
+	for i := 0; i < len(src) && out < len(dst); {
+		b := src[i]
+		var length, offset int
+		switch {
+		case b&0x80 != 0:
+			// literal run
+			length = int(b&0x7f) + 1
+			if i+1+length > len(src) || out+length > len(dst) {
+				return fmt.Errorf("ADC literal run out of range")
+			}
+			out += copy(dst[out:], src[i+1:i+1+length])
+			i += 1 + length
+			continue
+		case b&0x40 != 0:
+			if i+3 > len(src) {
+				return fmt.Errorf("ADC stream is truncated")
+			}
+			length = int(b&0x3f) + 4
+			offset = int(src[i+1])<<8 | int(src[i+2])
+			i += 3
+		default:
+			if i+2 > len(src) {
+				return fmt.Errorf("ADC stream is truncated")
+			}
+			length = int(b&0x3f)>>2 + 3
+			offset = int(b&0x3)<<8 | int(src[i+1])
+			i += 2
+		}
+		from := out - offset - 1
+		if from < 0 || out+length > len(dst) {
+			return fmt.Errorf("ADC match out of range")
+		}
+		// the match may overlap its own output
+		for j := 0; j < length; j++ {
+			dst[out] = dst[from+j]
+			out++
+		}
+	}
+	if out != len(dst) {
+		return fmt.Errorf("ADC stream decoded to %d bytes, want %d", out, len(dst))
+	}
+	return nil
+}
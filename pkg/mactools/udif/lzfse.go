@@ -0,0 +1,539 @@
+package udif
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// LZFSE block magics.
+const (
+	lzfseEndOfStream    = 0x24787662 // bvx$
+	lzfseUncompressed   = 0x2d787662 // bvx-
+	lzfseCompressedV1   = 0x31787662 // bvx1
+	lzfseCompressedV2   = 0x32787662 // bvx2
+	lzfseCompressedLZVN = 0x6e787662 // bvxn
+)
+
+const (
+	lzfseLSymbols       = 20
+	lzfseMSymbols       = 20
+	lzfseDSymbols       = 64
+	lzfseLiteralSymbols = 256
+	lzfseLStates        = 64
+	lzfseMStates        = 64
+	lzfseDStates        = 256
+	lzfseLiteralStates  = 1024
+	lzfseV1HeaderSize   = 770
+	lzfseV2HeaderSize   = 32
+)
+
+var (
+	lzfseLExtraBits = [lzfseLSymbols]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 3, 5, 8}
+	lzfseLBaseValue = [lzfseLSymbols]int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 20, 28, 60}
+	lzfseMExtraBits = [lzfseMSymbols]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 5, 8, 11}
+	lzfseMBaseValue = [lzfseMSymbols]int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 24, 56, 312}
+	lzfseDExtraBits [lzfseDSymbols]uint8
+	lzfseDBaseValue [lzfseDSymbols]int32
+)
+
+func init() {
+	// distances 0-3 have no extra bits, then every group of four symbols
+	// takes one more extra bit than the previous group
+	base := int32(0)
+	for i := range lzfseDExtraBits {
+		lzfseDExtraBits[i] = uint8(i / 4)
+		lzfseDBaseValue[i] = base
+		base += 1 << lzfseDExtraBits[i]
+	}
+}
+
+var errLZFSE = errors.New("invalid LZFSE stream")
+
+// decodeLZFSE decompresses an LZFSE stream, made of blocks that end with
+// an end of stream block, into dst.
+func decodeLZFSE(dst, src []byte) error {
+	pos := 0
+	for {
+		if len(src) < 4 {
+			return errLZFSE
+		}
+		var err error
+		start := pos
+		switch binary.LittleEndian.Uint32(src) {
+		case lzfseEndOfStream:
+			if pos != len(dst) {
+				return fmt.Errorf("LZFSE stream decoded to %d bytes, want %d", pos, len(dst))
+			}
+			return nil
+		case lzfseUncompressed:
+			if len(src) < 8 {
+				return errLZFSE
+			}
+			n := int(binary.LittleEndian.Uint32(src[4:]))
+			if len(src) < 8+n || pos+n > len(dst) {
+				return errLZFSE
+			}
+			pos += copy(dst[pos:], src[8:8+n])
+			src = src[8+n:]
+		case lzfseCompressedLZVN:
+			if len(src) < 12 {
+				return errLZFSE
+			}
+			nRaw := int(binary.LittleEndian.Uint32(src[4:]))
+			nPayload := int(binary.LittleEndian.Uint32(src[8:]))
+			if len(src) < 12+nPayload || pos+nRaw > len(dst) {
+				return errLZFSE
+			}
+			if pos, err = decodeLZVN(dst[:pos+nRaw], pos, src[12:12+nPayload]); err != nil {
+				return err
+			}
+			if pos != start+nRaw {
+				return errLZFSE
+			}
+			src = src[12+nPayload:]
+		case lzfseCompressedV1, lzfseCompressedV2:
+			var b lzfseBlock
+			var headerSize int
+			if headerSize, err = b.parseHeader(src); err != nil {
+				return err
+			}
+			payloadSize := int(b.nLiteralPayload) + int(b.nLMDPayload)
+			if len(src) < headerSize+payloadSize || pos+int(b.nRawBytes) > len(dst) {
+				return errLZFSE
+			}
+			if pos, err = b.decode(dst[:pos+int(b.nRawBytes)], pos, src[headerSize:headerSize+payloadSize]); err != nil {
+				return err
+			}
+			if pos != start+int(b.nRawBytes) {
+				return errLZFSE
+			}
+			src = src[headerSize+payloadSize:]
+		default:
+			return fmt.Errorf("unknown LZFSE block magic 0x%08x", binary.LittleEndian.Uint32(src))
+		}
+	}
+}
+
+// lzfseBlock is the header of a compressed block, in its v1 form.
+type lzfseBlock struct {
+	nRawBytes       uint32
+	nLiterals       uint32
+	nMatches        uint32
+	nLiteralPayload uint32
+	nLMDPayload     uint32
+	literalBits     int
+	literalState    [4]uint16
+	lmdBits         int
+	lState          uint16
+	mState          uint16
+	dState          uint16
+	// L, M, D and literal frequencies, in that order
+	freq [lzfseLSymbols + lzfseMSymbols + lzfseDSymbols + lzfseLiteralSymbols]uint16
+}
+
+// parseHeader reads a v1 or v2 block header and returns its size.
+func (b *lzfseBlock) parseHeader(src []byte) (int, error) {
+	le16, le32 := binary.LittleEndian.Uint16, binary.LittleEndian.Uint32
+	if le32(src) == lzfseCompressedV1 {
+		if len(src) < lzfseV1HeaderSize {
+			return 0, errLZFSE
+		}
+		b.nRawBytes = le32(src[4:])
+		b.nLiterals = le32(src[12:])
+		b.nMatches = le32(src[16:])
+		b.nLiteralPayload = le32(src[20:])
+		b.nLMDPayload = le32(src[24:])
+		b.literalBits = int(int32(le32(src[28:])))
+		for i := range b.literalState {
+			b.literalState[i] = le16(src[32+i*2:])
+		}
+		b.lmdBits = int(int32(le32(src[40:])))
+		b.lState, b.mState, b.dState = le16(src[44:]), le16(src[46:]), le16(src[48:])
+		for i := range b.freq {
+			b.freq[i] = le16(src[50+i*2:])
+		}
+		return lzfseV1HeaderSize, b.validate()
+	}
+
+	if len(src) < lzfseV2HeaderSize {
+		return 0, errLZFSE
+	}
+	field := func(v uint64, offset, n uint) uint32 {
+		return uint32(v>>offset) & (1<<n - 1)
+	}
+	v0, v1, v2 := binary.LittleEndian.Uint64(src[8:]), binary.LittleEndian.Uint64(src[16:]), binary.LittleEndian.Uint64(src[24:])
+	b.nRawBytes = le32(src[4:])
+	b.nLiterals = field(v0, 0, 20)
+	b.nLiteralPayload = field(v0, 20, 20)
+	b.nMatches = field(v0, 40, 20)
+	b.literalBits = int(field(v0, 60, 3)) - 7
+	for i := range b.literalState {
+		b.literalState[i] = uint16(field(v1, uint(i)*10, 10))
+	}
+	b.nLMDPayload = field(v1, 40, 20)
+	b.lmdBits = int(field(v1, 60, 3)) - 7
+	headerSize := int(field(v2, 0, 32))
+	b.lState = uint16(field(v2, 32, 10))
+	b.mState = uint16(field(v2, 42, 10))
+	b.dState = uint16(field(v2, 52, 10))
+	if headerSize < lzfseV2HeaderSize || headerSize > len(src) {
+		return 0, errLZFSE
+	}
+
+	// the frequencies are stored with a variable length code
+	table := src[lzfseV2HeaderSize:headerSize]
+	var accum uint32
+	accumBits := 0
+	for i := range b.freq {
+		for len(table) > 0 && accumBits+8 <= 32 {
+			accum |= uint32(table[0]) << accumBits
+			accumBits += 8
+			table = table[1:]
+		}
+		value, n := lzfseFreqValue(accum)
+		if n > accumBits {
+			return 0, errLZFSE
+		}
+		b.freq[i] = value
+		accum >>= n
+		accumBits -= n
+	}
+	return headerSize, b.validate()
+}
+
+func lzfseFreqValue(bits uint32) (uint16, int) {
+	nbitsTable := [32]int{
+		2, 3, 2, 5, 2, 3, 2, 8, 2, 3, 2, 5, 2, 3, 2, 14,
+		2, 3, 2, 5, 2, 3, 2, 8, 2, 3, 2, 5, 2, 3, 2, 14,
+	}
+	valueTable := [32]uint16{
+		0, 2, 1, 4, 0, 3, 1, 0, 0, 2, 1, 5, 0, 3, 1, 0,
+		0, 2, 1, 6, 0, 3, 1, 0, 0, 2, 1, 7, 0, 3, 1, 0,
+	}
+	b := bits & 31
+	switch n := nbitsTable[b]; n {
+	case 8:
+		return 8 + uint16(bits>>4&0xf), n
+	case 14:
+		return 24 + uint16(bits>>4&0x3ff), n
+	default:
+		return valueTable[b], n
+	}
+}
+
+func (b *lzfseBlock) validate() error {
+	if b.literalBits < -7 || b.literalBits > 0 || b.lmdBits < -7 || b.lmdBits > 0 {
+		return errLZFSE
+	}
+	for _, s := range b.literalState {
+		if s >= lzfseLiteralStates {
+			return errLZFSE
+		}
+	}
+	if b.lState >= lzfseLStates || b.mState >= lzfseMStates || b.dState >= lzfseDStates {
+		return errLZFSE
+	}
+	return nil
+}
+
+// decode decodes the literals and then the L, M, D triples of the block into
+// dst, starting at pos. Matches may refer to the output of previous blocks.
+func (b *lzfseBlock) decode(dst []byte, pos int, payload []byte) (int, error) {
+	freq := b.freq[:]
+	lFreq, freq := freq[:lzfseLSymbols], freq[lzfseLSymbols:]
+	mFreq, freq := freq[:lzfseMSymbols], freq[lzfseMSymbols:]
+	dFreq, literalFreq := freq[:lzfseDSymbols], freq[lzfseDSymbols:]
+
+	literalTable, err := newFSETable(lzfseLiteralStates, literalFreq)
+	if err != nil {
+		return pos, err
+	}
+	lTable, err := newFSEValueTable(lzfseLStates, lFreq, lzfseLExtraBits[:], lzfseLBaseValue[:])
+	if err != nil {
+		return pos, err
+	}
+	mTable, err := newFSEValueTable(lzfseMStates, mFreq, lzfseMExtraBits[:], lzfseMBaseValue[:])
+	if err != nil {
+		return pos, err
+	}
+	dTable, err := newFSEValueTable(lzfseDStates, dFreq, lzfseDExtraBits[:], lzfseDBaseValue[:])
+	if err != nil {
+		return pos, err
+	}
+
+	// literals are decoded four at a time with interleaved states
+	literals := make([]byte, (b.nLiterals+3)&^3)
+	in, err := newFSEIn(payload[:b.nLiteralPayload], b.literalBits)
+	if err != nil {
+		return pos, err
+	}
+	states := b.literalState
+	for i := 0; i < len(literals); i += 4 {
+		in.flush()
+		for j := range states {
+			if literals[i+j], err = literalTable.decode(&states[j], in); err != nil {
+				return pos, err
+			}
+		}
+	}
+	literals = literals[:b.nLiterals]
+
+	if in, err = newFSEIn(payload[b.nLiteralPayload:], b.lmdBits); err != nil {
+		return pos, err
+	}
+	lState, mState, dState := b.lState, b.mState, b.dState
+	d := int32(0)
+	for i := uint32(0); i < b.nMatches; i++ {
+		in.flush()
+		l, err := lTable.decode(&lState, in)
+		if err != nil {
+			return pos, err
+		}
+		m, err := mTable.decode(&mState, in)
+		if err != nil {
+			return pos, err
+		}
+		newD, err := dTable.decode(&dState, in)
+		if err != nil {
+			return pos, err
+		}
+		if newD != 0 {
+			d = newD
+		}
+		if int(l) > len(literals) || pos+int(l)+int(m) > len(dst) {
+			return pos, errLZFSE
+		}
+		pos += copy(dst[pos:], literals[:l])
+		literals = literals[l:]
+		if pos, err = copyMatch(dst, pos, int(m), int(d)); err != nil {
+			return pos, err
+		}
+	}
+	return pos, nil
+}
+
+// copyMatch copies n bytes from distance d back, which may overlap the output.
+func copyMatch(dst []byte, pos, n, d int) (int, error) {
+	if n == 0 {
+		return pos, nil
+	}
+	if d <= 0 || d > pos || pos+n > len(dst) {
+		return pos, errLZFSE
+	}
+	for i := 0; i < n; i++ {
+		dst[pos] = dst[pos-d]
+		pos++
+	}
+	return pos, nil
+}
+
+// fseIn reads an FSE bit stream backwards, starting from its end.
+type fseIn struct {
+	accum   uint64
+	nbits   int
+	buf     []byte
+	pos     int
+	missing int // bits of accum from before the start of buf
+}
+
+func newFSEIn(buf []byte, n int) (*fseIn, error) {
+	s := &fseIn{buf: buf, pos: len(buf)}
+	if n != 0 {
+		s.fill(8)
+		s.nbits = n + 64
+	} else {
+		s.fill(7)
+	}
+	if s.nbits < 56 || s.nbits >= 64 || s.accum>>s.nbits != 0 {
+		return nil, errLZFSE
+	}
+	return s, nil
+}
+
+// fill shifts n more bytes into the accumulator. The reference decoder reads
+// whatever precedes the stream, the block header for the literals, when it
+// nears the start, without ever pulling these bits; here they are zeros.
+func (s *fseIn) fill(n int) {
+	for i := 0; i < n; i++ {
+		s.accum <<= 8
+		if s.pos > 0 {
+			s.pos--
+			s.accum |= uint64(s.buf[s.pos])
+		} else {
+			s.missing += 8
+		}
+	}
+	s.nbits += 8 * n
+}
+
+// flush refills the accumulator to at least 56 bits.
+func (s *fseIn) flush() {
+	s.fill((63 - s.nbits) / 8)
+}
+
+func (s *fseIn) pull(n uint8) (uint64, error) {
+	if int(n) > s.nbits-s.missing {
+		return 0, errLZFSE
+	}
+	s.nbits -= int(n)
+	result := s.accum >> s.nbits
+	s.accum &= 1<<s.nbits - 1
+	return result, nil
+}
+
+type fseEntry struct {
+	k      uint8
+	symbol uint8
+	delta  uint16
+}
+
+type fseTable []fseEntry
+
+// newFSETable spreads the states of every symbol, as fse_init_decoder_table.
+func newFSETable(nstates int, freq []uint16) (fseTable, error) {
+	t := make(fseTable, 0, nstates)
+	err := fseSpread(nstates, freq, func(symbol, k, delta int) {
+		t = append(t, fseEntry{k: uint8(k), symbol: uint8(symbol), delta: uint16(delta)})
+	})
+	return t, err
+}
+
+func (t fseTable) decode(state *uint16, in *fseIn) (byte, error) {
+	if int(*state) >= len(t) {
+		return 0, errLZFSE
+	}
+	e := t[*state]
+	v, err := in.pull(e.k)
+	*state = e.delta + uint16(v)
+	return e.symbol, err
+}
+
+type fseValueEntry struct {
+	totalBits uint8
+	valueBits uint8
+	delta     uint16
+	base      int32
+}
+
+type fseValueTable []fseValueEntry
+
+func newFSEValueTable(nstates int, freq []uint16, extraBits []uint8, baseValue []int32) (fseValueTable, error) {
+	t := make(fseValueTable, 0, nstates)
+	err := fseSpread(nstates, freq, func(symbol, k, delta int) {
+		t = append(t, fseValueEntry{
+			totalBits: uint8(k) + extraBits[symbol],
+			valueBits: extraBits[symbol],
+			delta:     uint16(delta),
+			base:      baseValue[symbol],
+		})
+	})
+	return t, err
+}
+
+func (t fseValueTable) decode(state *uint16, in *fseIn) (int32, error) {
+	if int(*state) >= len(t) {
+		return 0, errLZFSE
+	}
+	e := t[*state]
+	v, err := in.pull(e.totalBits)
+	*state = e.delta + uint16(v>>e.valueBits)
+	return e.base + int32(v&(1<<e.valueBits-1)), err
+}
+
+// fseSpread calls add for the states of each symbol, with the number of bits
+// to read and the base of the next state.
+func fseSpread(nstates int, freq []uint16, add func(symbol, k, delta int)) error {
+	nclz := bits.LeadingZeros32(uint32(nstates))
+	sum := 0
+	for symbol, f := range freq {
+		if f == 0 {
+			continue
+		}
+		sum += int(f)
+		if sum > nstates {
+			return errLZFSE
+		}
+		k := bits.LeadingZeros32(uint32(f)) - nclz
+		j0 := (2*nstates)>>k - int(f)
+		for j := 0; j < int(f); j++ {
+			if j < j0 {
+				add(symbol, k, (int(f)+j)<<k-nstates)
+			} else {
+				add(symbol, k-1, (j-j0)<<(k-1))
+			}
+		}
+	}
+	return nil
+}
+
+// decodeLZVN decodes an LZVN stream into dst, starting at pos.
+func decodeLZVN(dst []byte, pos int, src []byte) (int, error) {
+	d := 0
+	for i := 0; i < len(src); {
+		op := src[i]
+		var l, m, n int // literals, match length and opcode size
+		switch {
+		case op == 0x06: // end of stream
+			return pos, nil
+		case op == 0x0e || op == 0x16: // nop
+			i++
+			continue
+		case op&0xf0 == 0x70 || op&0xf0 == 0xd0:
+			return pos, fmt.Errorf("undefined LZVN opcode 0x%02x", op)
+		case op >= 0xf0: // match
+			n, m = 1, int(op&0xf)
+			if op == 0xf0 {
+				if i+2 > len(src) {
+					return pos, errLZFSE
+				}
+				n, m = 2, int(src[i+1])+16
+			}
+		case op >= 0xe0: // literal
+			n, l = 1, int(op&0xf)
+			if op == 0xe0 {
+				if i+2 > len(src) {
+					return pos, errLZFSE
+				}
+				n, l = 2, int(src[i+1])+16
+			}
+		case op&0xe0 == 0xa0: // medium distance
+			if i+3 > len(src) {
+				return pos, errLZFSE
+			}
+			v := int(binary.LittleEndian.Uint16(src[i+1:]))
+			n, l = 3, int(op>>3&3)
+			m = (int(op&7)<<2 | v&3) + 3
+			d = v >> 2
+		case op&7 == 6: // previous distance
+			if op < 0x40 {
+				return pos, fmt.Errorf("undefined LZVN opcode 0x%02x", op)
+			}
+			n, l, m = 1, int(op>>6), int(op>>3&7)+3
+		case op&7 == 7: // large distance
+			if i+3 > len(src) {
+				return pos, errLZFSE
+			}
+			n, l, m = 3, int(op>>6), int(op>>3&7)+3
+			d = int(binary.LittleEndian.Uint16(src[i+1:]))
+		default: // small distance
+			if i+2 > len(src) {
+				return pos, errLZFSE
+			}
+			n, l, m = 2, int(op>>6), int(op>>3&7)+3
+			d = int(op&7)<<8 | int(src[i+1])
+		}
+		i += n
+		if i+l > len(src) || pos+l > len(dst) {
+			return pos, errLZFSE
+		}
+		pos += copy(dst[pos:], src[i:i+l])
+		i += l
+		var err error
+		if pos, err = copyMatch(dst, pos, m, d); err != nil {
+			return pos, err
+		}
+	}
+	return pos, errLZFSE
+}
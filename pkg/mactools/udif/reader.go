@@ -0,0 +1,157 @@
+package udif
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"howett.net/plist"
+)
+
+// Image is a UDIF image opened for reading.
+type Image struct {
+	Trailer    Trailer
+	Partitions []Partition
+	Resources  map[string][]Resource // the whole resource fork by type, including blkx
+	r          io.ReaderAt
+}
+
+// Partition is a blkx resource, the block table of one partition of the disk.
+type Partition struct {
+	Name       string
+	ID         string
+	Attributes string
+	Table      BlockTable
+}
+
+// Size returns the size of the partition in bytes.
+func (p *Partition) Size() int64 {
+	return int64(p.Table.SectorCount) * SectorSize
+}
+
+// Open reads the trailer and the block tables of the image in r.
+func Open(r io.ReaderAt, size int64) (*Image, error) {
+	if size < kolySize {
+		return nil, fmt.Errorf("file is too small to be a disk image")
+	}
+	koly := make([]byte, kolySize)
+	if _, err := r.ReadAt(koly, size-kolySize); err != nil {
+		return nil, err
+	}
+	img := &Image{r: r}
+	if err := img.Trailer.UnmarshalBinary(koly); err != nil {
+		return nil, err
+	}
+	if img.Trailer.XMLLength == 0 || img.Trailer.XMLOffset+img.Trailer.XMLLength > uint64(size) {
+		return nil, fmt.Errorf("image has no valid resource fork")
+	}
+	xml := make([]byte, img.Trailer.XMLLength)
+	if _, err := r.ReadAt(xml, int64(img.Trailer.XMLOffset)); err != nil {
+		return nil, err
+	}
+	var doc struct {
+		ResourceFork map[string][]Resource `plist:"resource-fork"`
+	}
+	if _, err := plist.Unmarshal(xml, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode resource fork: %w", err)
+	}
+	img.Resources = doc.ResourceFork
+	for _, res := range doc.ResourceFork["blkx"] {
+		p := Partition{Name: res.Name, ID: res.ID, Attributes: res.Attributes}
+		if err := p.Table.UnmarshalBinary(res.Data); err != nil {
+			return nil, fmt.Errorf("partition %q: %w", res.Name, err)
+		}
+		img.Partitions = append(img.Partitions, p)
+	}
+	if len(img.Partitions) == 0 {
+		return nil, fmt.Errorf("image has no blkx resources")
+	}
+	return img, nil
+}
+
+// Open returns a reader of the decompressed contents of p.
+func (img *Image) Open(p *Partition) *io.SectionReader {
+	return io.NewSectionReader(&partitionReader{img: img, table: &p.Table}, 0, p.Size())
+}
+
+// ReadChunk returns the decompressed sectors of a chunk of p.
+func (img *Image) ReadChunk(p *Partition, c *Chunk) ([]byte, error) {
+	return img.readChunk(&p.Table, c)
+}
+
+func (img *Image) readChunk(table *BlockTable, c *Chunk) ([]byte, error) {
+	out := make([]byte, c.SectorCount*SectorSize)
+	switch c.Type {
+	case ChunkZero, ChunkIgnore, ChunkComment, ChunkTerminator:
+		return out, nil
+	}
+	src := make([]byte, c.CompressedLength)
+	offset := int64(img.Trailer.DataForkOffset + table.DataOffset + c.CompressedOffset)
+	if _, err := img.r.ReadAt(src, offset); err != nil {
+		return nil, fmt.Errorf("failed to read chunk at %d: %w", offset, err)
+	}
+	var err error
+	switch c.Type {
+	case ChunkRaw:
+		copy(out, src)
+	case ChunkZlib:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(src)); err == nil {
+			_, err = io.ReadFull(zr, out)
+		}
+	case ChunkBzip2:
+		_, err = io.ReadFull(bzip2.NewReader(bytes.NewReader(src)), out)
+	case ChunkADC:
+		err = decodeADC(out, src)
+	case ChunkLZFSE:
+		err = decodeLZFSE(out, src)
+	default:
+		return nil, fmt.Errorf("unsupported chunk type 0x%08x", c.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress chunk at %d: %w", offset, err)
+	}
+	return out, nil
+}
+
+// partitionReader maps offsets of a partition to its chunks. The last
+// decompressed chunk is kept, as reads are mostly sequential.
+type partitionReader struct {
+	img   *Image
+	table *BlockTable
+
+	mu     sync.Mutex
+	cached *Chunk
+	data   []byte
+}
+
+func (r *partitionReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for n < len(p) {
+		sector := uint64(off+int64(n)) / SectorSize
+		chunks := r.table.Chunks
+		i := sort.Search(len(chunks), func(i int) bool {
+			return chunks[i].SectorNumber+chunks[i].SectorCount > sector
+		})
+		if i == len(chunks) || chunks[i].SectorNumber > sector || chunks[i].Type == ChunkTerminator {
+			return n, io.EOF
+		}
+		c := &chunks[i]
+		if r.cached != c {
+			data, err := r.img.readChunk(r.table, c)
+			if err != nil {
+				return n, err
+			}
+			r.cached, r.data = c, data
+		}
+		start := off + int64(n) - int64(c.SectorNumber)*SectorSize
+		n += copy(p[n:], r.data[start:])
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package udif
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"howett.net/plist"
+)
+
+// SetResources replaces the resources of the given types in the resource
+// fork of the image at path. The property list is rewritten in place, so it
+// must directly precede the trailer, as in the images of hdiutil and Write.
+func SetResources(path string, resources map[string][]Resource) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	if size < kolySize {
+		return fmt.Errorf("file is too small to be a disk image")
+	}
+	koly := make([]byte, kolySize)
+	if _, err := f.ReadAt(koly, size-kolySize); err != nil {
+		return err
+	}
+	var trailer Trailer
+	if err := trailer.UnmarshalBinary(koly); err != nil {
+		return err
+	}
+	if trailer.XMLLength == 0 || trailer.XMLOffset+trailer.XMLLength != uint64(size-kolySize) {
+		return fmt.Errorf("resource fork is not at the end of the image")
+	}
+	xml := make([]byte, trailer.XMLLength)
+	if _, err := f.ReadAt(xml, int64(trailer.XMLOffset)); err != nil {
+		return err
+	}
+	var doc map[string]any
+	if _, err := plist.Unmarshal(xml, &doc); err != nil {
+		return fmt.Errorf("failed to decode resource fork: %w", err)
+	}
+	fork, ok := doc["resource-fork"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("image has no resource fork")
+	}
+	for typ, res := range resources {
+		fork[typ] = res
+	}
+	if xml, err = plist.MarshalIndent(doc, plist.XMLFormat, "\t"); err != nil {
+		return fmt.Errorf("failed to encode resource fork: %w", err)
+	}
+
+	// only the length of the property list changes, keep the other fields as they are
+	binary.BigEndian.PutUint64(koly[224:], uint64(len(xml)))
+	if _, err := f.WriteAt(append(xml, koly...), int64(trailer.XMLOffset)); err != nil {
+		return err
+	}
+	if err := f.Truncate(int64(trailer.XMLOffset) + int64(len(xml)) + kolySize); err != nil {
+		return err
+	}
+	return f.Close()
+}
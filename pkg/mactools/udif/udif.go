@@ -0,0 +1,194 @@
+// Package udif is a package for reading and writing Universal Disk Image
+// Format (.dmg) files without hdiutil.
+package udif
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const SectorSize = 512
+
+// Format represents the supported image formats
+type Format string
+
+const (
+	UDRO Format = "UDRO" // Read-only, uncompressed
+	UDZO Format = "UDZO" // Compressed (zlib)
+)
+
+// Chunk types of a mish block table.
+const (
+	ChunkZero       = 0x00000000
+	ChunkRaw        = 0x00000001
+	ChunkIgnore     = 0x00000002
+	ChunkADC        = 0x80000004
+	ChunkZlib       = 0x80000005
+	ChunkBzip2      = 0x80000006
+	ChunkLZFSE      = 0x80000007
+	ChunkLZMA       = 0x80000008
+	ChunkComment    = 0x7ffffffe
+	ChunkTerminator = 0xffffffff
+)
+
+const (
+	checksumCRC32 = 2
+	kolySize      = 512
+	mishSize      = 204
+	chunkSize     = 40
+	flagFlattened = 1
+)
+
+// Checksum is the UDIF checksum structure. Only CRC32 checksums are written.
+type Checksum struct {
+	Type uint32
+	Bits uint32
+	Data [32]uint32
+}
+
+func crc32Checksum(crc uint32) Checksum {
+	c := Checksum{Type: checksumCRC32, Bits: 32}
+	c.Data[0] = crc
+	return c
+}
+
+func (c Checksum) put(b []byte) {
+	binary.BigEndian.PutUint32(b[0:], c.Type)
+	binary.BigEndian.PutUint32(b[4:], c.Bits)
+	for i, v := range c.Data {
+		binary.BigEndian.PutUint32(b[8+i*4:], v)
+	}
+}
+
+func readChecksum(b []byte) Checksum {
+	c := Checksum{Type: binary.BigEndian.Uint32(b[0:]), Bits: binary.BigEndian.Uint32(b[4:])}
+	for i := range c.Data {
+		c.Data[i] = binary.BigEndian.Uint32(b[8+i*4:])
+	}
+	return c
+}
+
+// Chunk is a run of sectors stored with a single compression method.
+type Chunk struct {
+	Type             uint32
+	Comment          uint32
+	SectorNumber     uint64
+	SectorCount      uint64
+	CompressedOffset uint64
+	CompressedLength uint64
+}
+
+// BlockTable is the mish table that maps the sectors of a partition to chunks
+// of the data fork.
+type BlockTable struct {
+	SectorNumber  uint64
+	SectorCount   uint64
+	DataOffset    uint64
+	BuffersNeeded uint32
+	Descriptor    uint32
+	Checksum      Checksum
+	Chunks        []Chunk
+}
+
+func (t *BlockTable) MarshalBinary() ([]byte, error) {
+	b := make([]byte, mishSize+len(t.Chunks)*chunkSize)
+	copy(b, "mish")
+	binary.BigEndian.PutUint32(b[4:], 1)
+	binary.BigEndian.PutUint64(b[8:], t.SectorNumber)
+	binary.BigEndian.PutUint64(b[16:], t.SectorCount)
+	binary.BigEndian.PutUint64(b[24:], t.DataOffset)
+	binary.BigEndian.PutUint32(b[32:], t.BuffersNeeded)
+	binary.BigEndian.PutUint32(b[36:], t.Descriptor)
+	t.Checksum.put(b[64:])
+	binary.BigEndian.PutUint32(b[200:], uint32(len(t.Chunks)))
+	for i, c := range t.Chunks {
+		p := b[mishSize+i*chunkSize:]
+		binary.BigEndian.PutUint32(p[0:], c.Type)
+		binary.BigEndian.PutUint32(p[4:], c.Comment)
+		binary.BigEndian.PutUint64(p[8:], c.SectorNumber)
+		binary.BigEndian.PutUint64(p[16:], c.SectorCount)
+		binary.BigEndian.PutUint64(p[24:], c.CompressedOffset)
+		binary.BigEndian.PutUint64(p[32:], c.CompressedLength)
+	}
+	return b, nil
+}
+
+func (t *BlockTable) UnmarshalBinary(b []byte) error {
+	if len(b) < mishSize || string(b[0:4]) != "mish" {
+		return fmt.Errorf("invalid block table signature")
+	}
+	count := int(binary.BigEndian.Uint32(b[200:]))
+	if len(b) < mishSize+count*chunkSize {
+		return fmt.Errorf("block table of %d chunks is truncated", count)
+	}
+	t.SectorNumber = binary.BigEndian.Uint64(b[8:])
+	t.SectorCount = binary.BigEndian.Uint64(b[16:])
+	t.DataOffset = binary.BigEndian.Uint64(b[24:])
+	t.BuffersNeeded = binary.BigEndian.Uint32(b[32:])
+	t.Descriptor = binary.BigEndian.Uint32(b[36:])
+	t.Checksum = readChecksum(b[64:])
+	t.Chunks = make([]Chunk, count)
+	for i := range t.Chunks {
+		p := b[mishSize+i*chunkSize:]
+		t.Chunks[i] = Chunk{
+			Type:             binary.BigEndian.Uint32(p[0:]),
+			Comment:          binary.BigEndian.Uint32(p[4:]),
+			SectorNumber:     binary.BigEndian.Uint64(p[8:]),
+			SectorCount:      binary.BigEndian.Uint64(p[16:]),
+			CompressedOffset: binary.BigEndian.Uint64(p[24:]),
+			CompressedLength: binary.BigEndian.Uint64(p[32:]),
+		}
+	}
+	return nil
+}
+
+// Trailer is the koly block at the end of a UDIF image.
+type Trailer struct {
+	Flags            uint32
+	DataForkOffset   uint64
+	DataForkLength   uint64
+	SegmentID        [16]byte
+	DataForkChecksum Checksum
+	XMLOffset        uint64
+	XMLLength        uint64
+	MasterChecksum   Checksum
+	ImageVariant     uint32
+	SectorCount      uint64
+}
+
+func (k *Trailer) MarshalBinary() ([]byte, error) {
+	b := make([]byte, kolySize)
+	copy(b, "koly")
+	binary.BigEndian.PutUint32(b[4:], 4)
+	binary.BigEndian.PutUint32(b[8:], kolySize)
+	binary.BigEndian.PutUint32(b[12:], k.Flags)
+	binary.BigEndian.PutUint64(b[24:], k.DataForkOffset)
+	binary.BigEndian.PutUint64(b[32:], k.DataForkLength)
+	binary.BigEndian.PutUint32(b[56:], 1) // segment number
+	binary.BigEndian.PutUint32(b[60:], 1) // segment count
+	copy(b[64:80], k.SegmentID[:])
+	k.DataForkChecksum.put(b[80:])
+	binary.BigEndian.PutUint64(b[216:], k.XMLOffset)
+	binary.BigEndian.PutUint64(b[224:], k.XMLLength)
+	k.MasterChecksum.put(b[352:])
+	binary.BigEndian.PutUint32(b[488:], k.ImageVariant)
+	binary.BigEndian.PutUint64(b[492:], k.SectorCount)
+	return b, nil
+}
+
+func (k *Trailer) UnmarshalBinary(b []byte) error {
+	if len(b) < kolySize || string(b[0:4]) != "koly" {
+		return fmt.Errorf("invalid trailer signature")
+	}
+	k.Flags = binary.BigEndian.Uint32(b[12:])
+	k.DataForkOffset = binary.BigEndian.Uint64(b[24:])
+	k.DataForkLength = binary.BigEndian.Uint64(b[32:])
+	copy(k.SegmentID[:], b[64:80])
+	k.DataForkChecksum = readChecksum(b[80:])
+	k.XMLOffset = binary.BigEndian.Uint64(b[216:])
+	k.XMLLength = binary.BigEndian.Uint64(b[224:])
+	k.MasterChecksum = readChecksum(b[352:])
+	k.ImageVariant = binary.BigEndian.Uint32(b[488:])
+	k.SectorCount = binary.BigEndian.Uint64(b[492:])
+	return nil
+}
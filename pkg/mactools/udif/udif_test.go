@@ -0,0 +1,359 @@
+package udif
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"howett.net/plist"
+)
+
+func TestWrite(t *testing.T) {
+	// text compresses well, the zero run must become a zero-fill chunk and the
+	// odd length exercises the sector padding
+	image := bytes.Repeat([]byte("zapp udif test "), 20000)
+	image = append(image, make([]byte, sectorsPerChunk*SectorSize*2)...)
+	image = append(image, []byte("tail")...)
+
+	for _, format := range []Format{UDZO, UDRO} {
+		buf := &bytes.Buffer{}
+		if err := Write(buf, bytes.NewReader(image), Options{Format: format, Level: 9}); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		koly := data[len(data)-kolySize:]
+		if string(koly[:4]) != "koly" {
+			t.Fatalf("%s: missing koly trailer", format)
+		}
+		dataLength := binary.BigEndian.Uint64(koly[32:])
+		if crc := readChecksum(koly[80:]).Data[0]; crc != crc32.ChecksumIEEE(data[:dataLength]) {
+			t.Errorf("%s: data fork checksum mismatch", format)
+		}
+		xmlOffset, xmlLength := binary.BigEndian.Uint64(koly[216:]), binary.BigEndian.Uint64(koly[224:])
+		var rsrc struct {
+			Fork struct {
+				Blkx []Resource `plist:"blkx"`
+			} `plist:"resource-fork"`
+		}
+		if _, err := plist.Unmarshal(data[xmlOffset:xmlOffset+xmlLength], &rsrc); err != nil {
+			t.Fatal(err)
+		}
+		if len(rsrc.Fork.Blkx) != 1 {
+			t.Fatalf("%s: expected one blkx entry, got %d", format, len(rsrc.Fork.Blkx))
+		}
+		mish := rsrc.Fork.Blkx[0].Data
+		sectors := binary.BigEndian.Uint64(mish[16:])
+		if sectors != binary.BigEndian.Uint64(koly[492:]) || sectors != uint64(len(image)+SectorSize-1)/SectorSize {
+			t.Fatalf("%s: unexpected sector count %d", format, sectors)
+		}
+
+		out := make([]byte, sectors*SectorSize)
+		types := map[uint32]int{}
+		for i := uint32(0); i < binary.BigEndian.Uint32(mish[200:]); i++ {
+			c := mish[mishSize+i*chunkSize:]
+			chunkType := binary.BigEndian.Uint32(c)
+			types[chunkType]++
+			start := binary.BigEndian.Uint64(c[8:]) * SectorSize
+			end := start + binary.BigEndian.Uint64(c[16:])*SectorSize
+			offset, length := binary.BigEndian.Uint64(c[24:]), binary.BigEndian.Uint64(c[32:])
+			stored := data[offset : offset+length]
+			switch chunkType {
+			case ChunkRaw:
+				copy(out[start:end], stored)
+			case ChunkZlib:
+				zr, err := zlib.NewReader(bytes.NewReader(stored))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := io.ReadFull(zr, out[start:end]); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if !bytes.Equal(out[:len(image)], image) {
+			t.Errorf("%s: image contents changed", format)
+		}
+		if crc := readChecksum(mish[64:]).Data[0]; crc != crc32.ChecksumIEEE(out) {
+			t.Errorf("%s: partition checksum mismatch", format)
+		}
+		if types[ChunkZero] == 0 || types[ChunkTerminator] != 1 {
+			t.Errorf("%s: unexpected chunk types %v", format, types)
+		}
+		if (format == UDZO) != (types[ChunkZlib] > 0) {
+			t.Errorf("%s: unexpected chunk types %v", format, types)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	image := bytes.Repeat([]byte("zapp udif reader "), 10000)
+	image = append(image, make([]byte, sectorsPerChunk*SectorSize*2)...)
+	buf := &bytes.Buffer{}
+	if err := Write(buf, bytes.NewReader(image), Options{Format: UDZO}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(img.Partitions) != 1 || img.Partitions[0].Name != "whole disk (Apple_HFS : 0)" {
+		t.Fatalf("unexpected partitions %+v", img.Partitions)
+	}
+	out, err := io.ReadAll(img.Open(&img.Partitions[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[:len(image)], image) {
+		t.Error("image contents changed")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Write(buf, bytes.NewReader(bytes.Repeat([]byte("verify "), 5000)), Options{Format: UDZO}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	img, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := img.VerifyDataFork(); err != nil {
+		t.Error(err)
+	}
+	if err := img.VerifyPartition(&img.Partitions[0]); err != nil {
+		t.Error(err)
+	}
+	if err := img.VerifyMaster(); err != nil {
+		t.Error(err)
+	}
+	if !img.Compressed() {
+		t.Error("UDZO image is not compressed")
+	}
+
+	data[10] ^= 0xff
+	if err := img.VerifyDataFork(); err == nil {
+		t.Error("corrupted data fork verified")
+	}
+	img.Partitions[0].Table.Checksum.Data[0]++
+	if err := img.VerifyPartition(&img.Partitions[0]); err == nil {
+		t.Error("wrong partition checksum verified")
+	}
+	if err := img.VerifyMaster(); err == nil {
+		t.Error("wrong master checksum verified")
+	}
+	img.Trailer.MasterChecksum = Checksum{}
+	if err := img.VerifyMaster(); !errors.Is(err, ErrNoChecksum) {
+		t.Errorf("VerifyMaster without checksum = %v", err)
+	}
+}
+
+func TestSetResources(t *testing.T) {
+	buf := &bytes.Buffer{}
+	opts := Options{Format: UDZO, Resources: map[string][]Resource{
+		"plst": {{Attributes: "0x0050", Data: []byte("plst"), ID: "0", Name: ""}},
+		"TEXT": {{Attributes: "0x0000", Data: []byte("old"), ID: "5000", Name: "English"}},
+	}}
+	if err := Write(buf, bytes.NewReader(make([]byte, 4*SectorSize)), opts); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "test.dmg")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := SetResources(path, map[string][]Resource{
+		"TEXT": {{Attributes: "0x0000", Data: []byte("a much longer license text"), ID: "5000", Name: "English"}},
+		"LPic": {{Attributes: "0x0000", Data: []byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0}, ID: "5000", Name: ""}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(img.Partitions) != 1 || string(img.Resources["plst"][0].Data) != "plst" {
+		t.Errorf("existing resources changed: %+v", img.Resources)
+	}
+	if string(img.Resources["TEXT"][0].Data) != "a much longer license text" || len(img.Resources["LPic"]) != 1 {
+		t.Errorf("resources = %+v", img.Resources)
+	}
+	// only the length of the property list may change in the trailer
+	before, after := buf.Bytes()[buf.Len()-kolySize:], data[len(data)-kolySize:]
+	if !bytes.Equal(before[:224], after[:224]) || !bytes.Equal(before[232:], after[232:]) {
+		t.Error("trailer changed")
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	// a literal run of "abc" followed by a match of 6 bytes at distance 3
+	out := make([]byte, 9)
+	if err := decodeADC(out, []byte{0x82, 'a', 'b', 'c', 0x0c, 0x02}); err != nil || string(out) != "abcabcabc" {
+		t.Errorf("ADC = %q, %v", out, err)
+	}
+
+	// a literal run of "abc" with a match of 4 bytes at distance 3, then a
+	// match of 2 more bytes at the same distance
+	lzvn := []byte{0xc8, 0x03, 'a', 'b', 'c', 0xf2, 0x06, 0, 0, 0, 0, 0, 0, 0}
+	var stream []byte
+	stream = binary.LittleEndian.AppendUint32(stream, lzfseCompressedLZVN)
+	stream = binary.LittleEndian.AppendUint32(stream, 9)
+	stream = binary.LittleEndian.AppendUint32(stream, uint32(len(lzvn)))
+	stream = append(stream, lzvn...)
+	stream = binary.LittleEndian.AppendUint32(stream, lzfseUncompressed)
+	stream = binary.LittleEndian.AppendUint32(stream, 3)
+	stream = append(stream, "xyz"...)
+	stream = binary.LittleEndian.AppendUint32(stream, lzfseEndOfStream)
+	out = make([]byte, 12)
+	if err := decodeLZFSE(out, stream); err != nil || string(out) != "abcabcabcxyz" {
+		t.Errorf("LZFSE = %q, %v", out, err)
+	}
+	for _, op := range []byte{0x70, 0x7e, 0xd0, 0xdf, 0x1e} {
+		if _, err := decodeLZVN(make([]byte, 16), 0, []byte{op, 0, 0, 0x06}); err == nil {
+			t.Errorf("LZVN opcode 0x%02x accepted", op)
+		}
+	}
+}
+
+func TestDecodeLZFSE(t *testing.T) {
+	// bvx1 stores the header fields and the frequencies as plain integers:
+	// "abcdefgh " and a match of 17, ", hello" and a match of 18 at distance
+	// 6, then "!\n" and the padding of the literals to a multiple of four
+	v1 := binary.LittleEndian.AppendUint32(nil, lzfseCompressedV1)
+	for _, v := range []int32{53, 21, 20, 3, 10, 11, -5} {
+		v1 = binary.LittleEndian.AppendUint32(v1, uint32(v))
+	}
+	for _, v := range []uint16{361, 414, 467, 519} {
+		v1 = binary.LittleEndian.AppendUint16(v1, v)
+	}
+	v1 = binary.LittleEndian.AppendUint32(v1, uint32(0xfffffffa))
+	for _, v := range []uint16{47, 36, 187} {
+		v1 = binary.LittleEndian.AppendUint16(v1, v)
+	}
+	freq := make([]uint16, lzfseLSymbols+lzfseMSymbols+lzfseDSymbols+lzfseLiteralSymbols)
+	const m, d, lit = lzfseLSymbols, lzfseLSymbols + lzfseMSymbols, lzfseLSymbols + lzfseMSymbols + lzfseDSymbols
+	for i, f := range map[int]uint16{
+		2: 22, 7: 21, 9: 21,
+		m: 21, m + 16: 43,
+		d: 86, d + 5: 85, d + 6: 85,
+		lit + '\n': 157, lit + ' ': 102, lit + '!': 51, lit + ',': 51, lit + 'a': 51, lit + 'b': 51, lit + 'c': 51,
+		lit + 'd': 51, lit + 'e': 102, lit + 'f': 51, lit + 'g': 51, lit + 'h': 102, lit + 'l': 102, lit + 'o': 51,
+	} {
+		freq[i] = f
+	}
+	for _, f := range freq {
+		v1 = binary.LittleEndian.AppendUint16(v1, f)
+	}
+	v1 = append(v1, fromHex(t, "00cc06e816f96f56b6000000000000000000409a02")...)
+
+	// bvx2 packs the header fields and codes the frequencies
+	v2 := fromHex(t, "627678326a0000003c00e00100040050e0b703f3cc0e00309e0000003678800d1c02877008"+
+		"00c02187008700007078088f020000f0683c0a000000000000000000000000000000cf0200000000004f0700"+
+		"007009000000000000000000000000f04e70f90afc0697cf02cf02afc0e5e5b3f00abc135c7e0faf0000af00"+
+		"00000000000000000000000000000000000000000000000000000000000000000000c04cae5f8ce97c3bb341"+
+		"462f570028aaf5058a0ef96e1ed509810092090000000000000000800d24c0e101")
+
+	for _, test := range []struct {
+		name  string
+		block []byte
+		want  string
+	}{
+		{"bvx1", v1, "abcdefgh abcdefgh abcdefgh, hello hello hello hello!\n"},
+		{"bvx2", v2, "zapp packages apps into dmg and pkg files, zapp signs dmg and pkg files, zapp notarizes dmg and pkg files\n"},
+	} {
+		stream := binary.LittleEndian.AppendUint32(bytes.Clone(test.block), lzfseEndOfStream)
+		out := make([]byte, len(test.want))
+		if err := decodeLZFSE(out, stream); err != nil || string(out) != test.want {
+			t.Errorf("%s = %q, %v", test.name, out, err)
+		}
+		// a payload cut short must not decode
+		stream = binary.LittleEndian.AppendUint32(bytes.Clone(test.block[:len(test.block)-4]), lzfseEndOfStream)
+		if err := decodeLZFSE(make([]byte, len(test.want)), stream); err == nil {
+			t.Errorf("%s: truncated block decoded", test.name)
+		}
+	}
+}
+
+func fromHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestOpenULFO(t *testing.T) {
+	if _, err := exec.LookPath("hdiutil"); err != nil {
+		t.Skip("hdiutil not found")
+	}
+	// hdiutil compresses with Apple's LZFSE, the raw copy is the reference
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("zapp lzfse chunk "), 100000)
+	for i := range data[:len(data)/4] {
+		data[i*3] ^= byte(i * 7)
+	}
+	if err := os.WriteFile(filepath.Join(src, "data"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ulfo, udro := filepath.Join(dir, "ulfo.dmg"), filepath.Join(dir, "udro.dmg")
+	for _, args := range [][]string{
+		{"create", "-srcfolder", src, "-format", "ULFO", ulfo},
+		{"convert", ulfo, "-format", "UDRO", "-o", udro},
+	} {
+		if out, err := exec.Command("hdiutil", args...).CombinedOutput(); err != nil {
+			t.Fatalf("hdiutil %s: %v\n%s", args[0], err, out)
+		}
+	}
+	open := func(path string) *Image {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := Open(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	compressed, raw := open(ulfo), open(udro)
+	if len(compressed.Partitions) != len(raw.Partitions) {
+		t.Fatalf("%d partitions, want %d", len(compressed.Partitions), len(raw.Partitions))
+	}
+	lzfse := false
+	for i := range compressed.Partitions {
+		for _, c := range compressed.Partitions[i].Table.Chunks {
+			lzfse = lzfse || c.Type == ChunkLZFSE
+		}
+		got, err := io.ReadAll(compressed.Open(&compressed.Partitions[i]))
+		if err != nil {
+			t.Fatalf("partition %d: %v", i, err)
+		}
+		want, err := io.ReadAll(raw.Open(&raw.Partitions[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("partition %d differs from the raw image", i)
+		}
+	}
+	if !lzfse {
+		t.Error("image has no LZFSE chunks")
+	}
+}
@@ -0,0 +1,89 @@
+package udif
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// ErrNoChecksum is returned when a checksum to verify is not a CRC32 checksum,
+// e.g. of read/write images, which are changed in place.
+var ErrNoChecksum = errors.New("no CRC32 checksum")
+
+func (c Checksum) crc32() (uint32, error) {
+	if c.Type != checksumCRC32 || c.Bits != 32 {
+		return 0, ErrNoChecksum
+	}
+	return c.Data[0], nil
+}
+
+// VerifyDataFork compares the CRC32 of the data fork with the checksum in
+// the trailer.
+func (img *Image) VerifyDataFork() error {
+	want, err := img.Trailer.DataForkChecksum.crc32()
+	if err != nil {
+		return err
+	}
+	h := crc32.NewIEEE()
+	section := io.NewSectionReader(img.r, int64(img.Trailer.DataForkOffset), int64(img.Trailer.DataForkLength))
+	if _, err := io.Copy(h, section); err != nil {
+		return fmt.Errorf("failed to read data fork: %w", err)
+	}
+	if got := h.Sum32(); got != want {
+		return fmt.Errorf("data fork CRC32 is %08x, the trailer says %08x", got, want)
+	}
+	return nil
+}
+
+// VerifyPartition compares the CRC32 of the decompressed sectors of p with
+// the checksum of its block table.
+func (img *Image) VerifyPartition(p *Partition) error {
+	want, err := p.Table.Checksum.crc32()
+	if err != nil {
+		return err
+	}
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, img.Open(p)); err != nil {
+		return fmt.Errorf("partition %q: %w", p.Name, err)
+	}
+	if got := h.Sum32(); got != want {
+		return fmt.Errorf("partition %q: CRC32 is %08x, the block table says %08x", p.Name, got, want)
+	}
+	return nil
+}
+
+// VerifyMaster compares the master checksum of the trailer with the CRC32 of
+// the checksums of the partitions.
+func (img *Image) VerifyMaster() error {
+	want, err := img.Trailer.MasterChecksum.crc32()
+	if err != nil {
+		return err
+	}
+	var sums []byte
+	for _, p := range img.Partitions {
+		c := p.Table.Checksum
+		for i := 0; i < int(c.Bits+31)/32 && i < len(c.Data); i++ {
+			sums = binary.BigEndian.AppendUint32(sums, c.Data[i])
+		}
+	}
+	if got := crc32.ChecksumIEEE(sums); got != want {
+		return fmt.Errorf("master CRC32 is %08x, the trailer says %08x", got, want)
+	}
+	return nil
+}
+
+// Compressed reports whether any chunk of the image is compressed. Images
+// without compressed chunks are UDRO, or UDRW when they have no checksums.
+func (img *Image) Compressed() bool {
+	for _, p := range img.Partitions {
+		for _, c := range p.Table.Chunks {
+			switch c.Type {
+			case ChunkADC, ChunkZlib, ChunkBzip2, ChunkLZFSE, ChunkLZMA:
+				return true
+			}
+		}
+	}
+	return false
+}
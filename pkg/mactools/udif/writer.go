@@ -0,0 +1,208 @@
+package udif
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"howett.net/plist"
+)
+
+// sectorsPerChunk is the number of sectors compressed together, as used by hdiutil.
+const sectorsPerChunk = 0x200
+
+// Options configures how Write lays out the image.
+type Options struct {
+	Format Format
+	// Level is the zlib compression level of UDZO images (1-9).
+	// The zlib default is used when it is 0.
+	Level int
+	// PartitionName names the single partition of the image,
+	// "whole disk (Apple_HFS : 0)" when empty.
+	PartitionName string
+	// Resources are added to the resource fork of the image by type,
+	// e.g. the resources of a license agreement.
+	Resources map[string][]Resource
+	// SegmentID identifies the image, a random one is used when zero.
+	SegmentID [16]byte
+}
+
+// Resource is an entry of the resource fork stored in the XML property list.
+type Resource struct {
+	Attributes string `plist:"Attributes"`
+	CFName     string `plist:"CFName,omitempty"`
+	Data       []byte `plist:"Data"`
+	ID         string `plist:"ID"`
+	Name       string `plist:"Name"`
+}
+
+// countingWriter keeps track of the offset and the CRC32 of everything written.
+type countingWriter struct {
+	w   io.Writer
+	n   uint64
+	crc uint32
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	c.crc = crc32.Update(c.crc, crc32.IEEETable, p[:n])
+	return n, err
+}
+
+// Write reads a raw file system image (e.g. an HFS+ volume) and writes it to
+// w as a single-partition UDIF image.
+func Write(w io.Writer, image io.Reader, opts Options) error {
+	var compress func([]byte) ([]byte, error)
+	switch opts.Format {
+	case UDRO:
+	case UDZO:
+		level := opts.Level
+		if level == 0 {
+			level = zlib.DefaultCompression
+		}
+		if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+			return fmt.Errorf("invalid zlib level: %d", opts.Level)
+		}
+		compress = func(data []byte) ([]byte, error) {
+			buf := &bytes.Buffer{}
+			zw, err := zlib.NewWriterLevel(buf, level)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := zw.Write(data); err != nil {
+				return nil, err
+			}
+			if err := zw.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+	name := opts.PartitionName
+	if name == "" {
+		name = "whole disk (Apple_HFS : 0)"
+	}
+
+	out := &countingWriter{w: w}
+	table := BlockTable{BuffersNeeded: sectorsPerChunk + 8}
+	var partitionCRC uint32
+	buf := make([]byte, sectorsPerChunk*SectorSize)
+	r := bufio.NewReader(image)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n == 0 {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to read image: %w", err)
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to read image: %w", err)
+		}
+		// the last chunk is padded to a whole sector
+		sectors := (n + SectorSize - 1) / SectorSize
+		data := buf[:sectors*SectorSize]
+		clear(data[n:])
+		partitionCRC = crc32.Update(partitionCRC, crc32.IEEETable, data)
+
+		chunk := Chunk{
+			Type:             ChunkRaw,
+			SectorNumber:     table.SectorCount,
+			SectorCount:      uint64(sectors),
+			CompressedOffset: out.n,
+		}
+		stored := data
+		switch {
+		case isZero(data):
+			chunk.Type = ChunkZero
+			stored = nil
+		case compress != nil:
+			compressed, err := compress(data)
+			if err != nil {
+				return fmt.Errorf("failed to compress chunk: %w", err)
+			}
+			if len(compressed) < len(data) {
+				chunk.Type = ChunkZlib
+				stored = compressed
+			}
+		}
+		if _, err := out.Write(stored); err != nil {
+			return err
+		}
+		chunk.CompressedLength = uint64(len(stored))
+		table.Chunks = append(table.Chunks, chunk)
+		table.SectorCount += uint64(sectors)
+	}
+	table.Chunks = append(table.Chunks, Chunk{
+		Type:             ChunkTerminator,
+		SectorNumber:     table.SectorCount,
+		CompressedOffset: out.n,
+	})
+	table.Checksum = crc32Checksum(partitionCRC)
+
+	mish, err := table.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	fork := map[string]any{
+		"blkx": []Resource{{
+			Attributes: "0x0050",
+			CFName:     name,
+			Data:       mish,
+			ID:         "0",
+			Name:       name,
+		}},
+	}
+	for typ, resources := range opts.Resources {
+		fork[typ] = resources
+	}
+	xml, err := plist.MarshalIndent(map[string]any{"resource-fork": fork}, plist.XMLFormat, "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode resource fork: %w", err)
+	}
+
+	trailer := Trailer{
+		Flags:            flagFlattened,
+		DataForkLength:   out.n,
+		DataForkChecksum: crc32Checksum(out.crc),
+		XMLOffset:        out.n,
+		XMLLength:        uint64(len(xml)),
+		// the master checksum covers the checksums of all block tables
+		MasterChecksum: crc32Checksum(crc32.ChecksumIEEE(binary.BigEndian.AppendUint32(nil, partitionCRC))),
+		ImageVariant:   1,
+		SectorCount:    table.SectorCount,
+	}
+	trailer.SegmentID = opts.SegmentID
+	if trailer.SegmentID == [16]byte{} {
+		if _, err := rand.Read(trailer.SegmentID[:]); err != nil {
+			return err
+		}
+	}
+	koly, err := trailer.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(xml); err != nil {
+		return err
+	}
+	_, err = out.Write(koly)
+	return err
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}